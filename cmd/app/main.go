@@ -27,6 +27,7 @@ func Init() {
 	position.InitAtksKnights()
 	castlings.InitCastlings()
 	position.PcSqInit()
+	position.InitEndgames()
 	position.Board.NewGame()
 
 	// run setoption name hash value 32
@@ -0,0 +1,270 @@
+// Command bookgen builds a Polyglot opening book from PGN files.
+//
+// Every game is replayed up to -maxply plies and each move is counted in the position
+// where it was played, together with the result of the game. Moves played in fewer
+// than -mingames games are left out. The weight of a move is the number of games it
+// was played in, or with -weight result the points the side playing it scored,
+// 2 for a win and 1 for a draw.
+//
+//	bookgen -out book.bin -summary book.txt games1.pgn games2.pgn
+//
+// The summary lists every position in the book with the statistics of its moves.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/Tecu23/go-game/pkg/chess/book"
+	"github.com/Tecu23/go-game/pkg/chess/castlings"
+	. "github.com/Tecu23/go-game/pkg/chess/constants"
+	"github.com/Tecu23/go-game/pkg/chess/magic"
+	"github.com/Tecu23/go-game/pkg/chess/position"
+)
+
+// stats of a move, from the pov of the side playing it
+type stats struct {
+	san                        string
+	games, wins, draws, losses int
+	weight                     int
+}
+
+// node is a position seen in the games
+type node struct {
+	key   uint64
+	ply   int
+	line  string // the moves leading to the position the first time it was seen
+	moves map[uint16]*stats
+	order []uint16 // the moves in the order they were first seen
+}
+
+func (n *node) games() int {
+	sum := 0
+	for _, st := range n.moves {
+		sum += st.games
+	}
+	return sum
+}
+
+var nodes = make(map[uint64]*node)
+
+func main() {
+	outFile := flag.String("out", "book.bin", "where to write the book")
+	sumFile := flag.String("summary", "book.txt", "where to write the summary (- for stdout)")
+	maxPly := flag.Int("maxply", 20, "number of plies of each game that go into the book")
+	minGames := flag.Int("mingames", 3, "minimum number of games a move must be played in")
+	weight := flag.String("weight", "games", "weight the moves by number of games or by result")
+	flag.Parse()
+
+	if flag.NArg() == 0 || (*weight != "games" && *weight != "result") {
+		fmt.Fprintln(os.Stderr, "usage: bookgen [options] file.pgn...")
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+
+	Init()
+
+	cntGames, cntSkipped := 0, 0
+	for _, path := range flag.Args() {
+		f, err := os.Open(path)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		err = readGames(f, func(g *game) {
+			cntGames++
+			if err := addGame(g, *maxPly); err != nil {
+				cntSkipped++
+				log.Warnf("%v game %v: %v", path, cntGames, err)
+			}
+		})
+		f.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	log.Infof("read %v games, %v positions", cntGames, len(nodes))
+
+	entries := makeEntries(*minGames, *weight == "result")
+
+	f, err := os.Create(*outFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := book.Write(f, entries); err != nil {
+		log.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		log.Fatal(err)
+	}
+	log.Infof("wrote %v entries to %v", len(entries), *outFile)
+
+	var w io.Writer = os.Stdout
+	if *sumFile != "-" {
+		f, err := os.Create(*sumFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		w = f
+	}
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "%v games (%v with errors), %v entries\n", cntGames, cntSkipped, len(entries))
+	writeSummary(bw)
+	if err := bw.Flush(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func Init() {
+	InitFen2Sq()
+	magic.InitMagic()
+	position.InitKeys()
+	position.InitAtksKings()
+	position.InitAtksKnights()
+	castlings.InitCastlings()
+	position.PcSqInit()
+}
+
+// addGame counts the first maxPly moves of g. The moves before an error are kept
+func addGame(g *game, maxPly int) error {
+	fen := g.fen
+	if fen == "" {
+		fen = Startpos
+	}
+	b, err := position.ParseFEN(fen)
+	if err != nil {
+		return err
+	}
+
+	for ply, san := range g.sans {
+		if ply >= maxPly {
+			break
+		}
+
+		mv, err := b.ParseSAN(san)
+		if err != nil {
+			return fmt.Errorf("ply %v: %v", ply+1, err)
+		}
+
+		key := book.Key(b)
+		n := nodes[key]
+		if n == nil {
+			line := strings.Join(g.sans[:ply], " ")
+			if g.fen != "" {
+				line = strings.TrimSpace("[" + g.fen + "] " + line)
+			}
+			n = &node{key: key, ply: ply, line: line, moves: make(map[uint16]*stats)}
+			nodes[key] = n
+		}
+
+		m := book.EncodeMove(b, mv)
+		st := n.moves[m]
+		if st == nil {
+			st = &stats{san: san}
+			n.moves[m] = st
+			n.order = append(n.order, m)
+		}
+
+		st.games++
+		result := g.result
+		if b.Stm == BLACK && result >= 0 {
+			result = 1 - result
+		}
+		switch result {
+		case 1:
+			st.wins++
+		case 0.5:
+			st.draws++
+		case 0:
+			st.losses++
+		}
+
+		b.Move(mv)
+	}
+	return nil
+}
+
+// makeEntries computes the weights and returns the book entries of all moves played often enough
+func makeEntries(minGames int, byResult bool) []book.Entry {
+	var entries []book.Entry
+	for key, n := range nodes {
+		maxWeight := 0
+		for _, st := range n.moves {
+			if st.games < minGames {
+				continue
+			}
+			st.weight = st.games
+			if byResult {
+				st.weight = 2*st.wins + st.draws
+			}
+			if st.weight > maxWeight {
+				maxWeight = st.weight
+			}
+		}
+
+		for _, m := range n.order {
+			st := n.moves[m]
+			if st.weight == 0 {
+				continue
+			}
+			if maxWeight > 0xffff { // keep the proportions within 16 bits
+				st.weight = max(1, st.weight*0xffff/maxWeight)
+			}
+			entries = append(entries, book.Entry{Key: key, Move: m, Weight: uint16(st.weight)})
+		}
+	}
+	return entries
+}
+
+// writeSummary writes the positions with book moves, in the order of the number of plies
+// and the number of games, with the moves in the order of their weight
+func writeSummary(w io.Writer) {
+	var list []*node
+	for _, n := range nodes {
+		for _, st := range n.moves {
+			if st.weight > 0 {
+				list = append(list, n)
+				break
+			}
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].ply != list[j].ply {
+			return list[i].ply < list[j].ply
+		}
+		if gi, gj := list[i].games(), list[j].games(); gi != gj {
+			return gi > gj
+		}
+		return list[i].key < list[j].key
+	})
+
+	for _, n := range list {
+		line := n.line
+		if line == "" {
+			line = "(start)"
+		}
+		fmt.Fprintf(w, "\n%v\n  key %016x, %v games\n", line, n.key, n.games())
+
+		var sts []*stats
+		for _, m := range n.order {
+			if st := n.moves[m]; st.weight > 0 {
+				sts = append(sts, st)
+			}
+		}
+		sort.SliceStable(sts, func(i, j int) bool { return sts[i].weight > sts[j].weight })
+
+		for _, st := range sts {
+			score := float64(2*st.wins+st.draws) * 50 / float64(st.games)
+			fmt.Fprintf(w, "  %-8v %6v games  +%v =%v -%v  %5.1f%%  weight %v\n",
+				st.san, st.games, st.wins, st.draws, st.losses, score, st.weight)
+		}
+	}
+}
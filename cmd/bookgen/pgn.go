@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// game is what bookgen needs from a PGN game
+type game struct {
+	fen    string   // the FEN tag, empty for the start position
+	result float64  // 1.0 white won, 0.5 draw, 0.0 black won, -1 unknown
+	sans   []string // the moves of the main line
+}
+
+// readGames calls fn for every game in the PGN text read from r
+func readGames(r io.Reader, fn func(g *game)) error {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 1024*1024), 1024*1024)
+
+	g := &game{result: -1}
+	var text strings.Builder
+	inMoves := false
+	flush := func() {
+		if inMoves {
+			g.sans = sanTokens(text.String())
+			fn(g)
+		}
+		g = &game{result: -1}
+		text.Reset()
+		inMoves = false
+	}
+
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if strings.HasPrefix(line, "%") { // escape line
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			if inMoves {
+				flush()
+			}
+			name, val := parseTag(line)
+			switch name {
+			case "FEN":
+				g.fen = val
+			case "Result":
+				g.result = parseResult(val)
+			}
+			continue
+		}
+
+		if line == "" {
+			continue
+		}
+		if i := strings.Index(line, ";"); i >= 0 && !strings.Contains(line[:i], "{") {
+			line = line[:i] // rest of line comment
+		}
+		inMoves = true
+		text.WriteString(line)
+		text.WriteString(" ")
+	}
+	flush()
+
+	return sc.Err()
+}
+
+// parseTag splits a tag pair like [Event "Casual game"]
+func parseTag(line string) (string, string) {
+	line = strings.TrimSuffix(strings.TrimPrefix(line, "["), "]")
+	name, val, _ := strings.Cut(line, " ")
+	return name, strings.Trim(strings.TrimSpace(val), `"`)
+}
+
+func parseResult(s string) float64 {
+	switch s {
+	case "1-0":
+		return 1
+	case "0-1":
+		return 0
+	case "1/2-1/2":
+		return 0.5
+	}
+	return -1
+}
+
+// sanTokens returns the main line moves in the movetext, without comments, variations,
+// move numbers, annotations and the result
+func sanTokens(text string) []string {
+	var clean strings.Builder
+	comment, variation := false, 0
+	for _, c := range text {
+		switch {
+		case comment:
+			comment = c != '}'
+		case c == '{':
+			comment = true
+		case c == '(':
+			variation++
+		case c == ')':
+			if variation > 0 {
+				variation--
+			}
+		case variation == 0:
+			clean.WriteRune(c)
+		}
+	}
+
+	var sans []string
+	for _, tok := range strings.Fields(clean.String()) {
+		if parseResult(tok) != -1 || tok == "*" {
+			continue
+		}
+		if i := strings.LastIndexByte(tok, '.'); i >= 0 { // move numbers, also glued to the move in 1.e4
+			tok = tok[i+1:]
+		}
+		tok = strings.TrimRight(tok, "+#!?")
+		if tok == "" || tok[0] == '$' {
+			continue
+		}
+		sans = append(sans, tok)
+	}
+	return sans
+}
@@ -0,0 +1,284 @@
+// Command epdtest runs the engine on the positions of EPD test suites like WAC, STS or ECM.
+//
+// Each position is searched with a fixed time (-movetime) or node budget (-nodes). The
+// position is solved if the engine plays one of the moves of the bm opcode and none of
+// the moves of the am opcode. The id opcode names the position in the report.
+//
+//	epdtest -movetime 1000 wac.epd
+//
+// A line like
+//
+//	2rr3k/pp3pp1/1nnqbN1p/3pN3/2pP4/2P3Q1/PPB4P/R4RK1 w - - bm Qg6; id "WAC.001";
+//
+// is reported as
+//
+//	WAC.001  pass  Qg6  (bm Qg6)
+//
+// followed by the total score of every file.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/Tecu23/go-game/pkg/chess/castlings"
+	. "github.com/Tecu23/go-game/pkg/chess/constants"
+	"github.com/Tecu23/go-game/pkg/chess/engine"
+	"github.com/Tecu23/go-game/pkg/chess/magic"
+	"github.com/Tecu23/go-game/pkg/chess/moves"
+	"github.com/Tecu23/go-game/pkg/chess/position"
+)
+
+// epd is a test position with its opcodes
+type epd struct {
+	b   *position.BoardStruct
+	id  string
+	bm  []moves.Move
+	am  []moves.Move
+	ops map[string][]string // all opcodes and their operands
+}
+
+func main() {
+	moveTime := flag.Int("movetime", 1000, "milliseconds per position")
+	nodes := flag.Uint64("nodes", 0, "nodes per position instead of a time")
+	hash := flag.Int("hash", 64, "transposition table size in MB")
+	failed := flag.Bool("failed", false, "only report the positions that are not solved")
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "usage: epdtest [options] file.epd...")
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+
+	Init()
+	if err := position.Trans.New(*hash); err != nil {
+		log.Fatal(err)
+	}
+	toEng, frEng := engine.Engine()
+
+	total, solved := 0, 0
+	for _, path := range flag.Args() {
+		tests, err := readEPD(path)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		fileSolved := 0
+		for ix, t := range tests {
+			if t.id == "" {
+				t.id = fmt.Sprintf("%v:%v", path, ix+1)
+			}
+
+			position.Board = *t.b
+			position.Trans.Clear()
+			engine.History.Clear()
+			engine.Limits.Init()
+			if *nodes > 0 {
+				engine.Limits.SetNodes(*nodes)
+			} else {
+				engine.Limits.SetMoveTime(*moveTime)
+			}
+			toEng <- true
+			bm := strings.TrimPrefix(<-frEng, "bestmove ")
+
+			mv, err := t.b.ParseUCI(bm)
+			if err != nil {
+				log.Fatalf("%v: the engine played %v", t.id, err)
+			}
+			ok := t.solved(mv)
+			if ok {
+				fileSolved++
+			}
+			if !ok || !*failed {
+				fmt.Printf("%-12v %v  %-7v (%v)\n", t.id, passFail(ok), t.b.SAN(mv), t.expected())
+			}
+		}
+
+		fmt.Printf("%v: %v of %v solved (%.1f%%)\n\n", path, fileSolved, len(tests), percent(fileSolved, len(tests)))
+		total += len(tests)
+		solved += fileSolved
+	}
+
+	if flag.NArg() > 1 {
+		fmt.Printf("total: %v of %v solved (%.1f%%)\n", solved, total, percent(solved, total))
+	}
+}
+
+func Init() {
+	InitFen2Sq()
+	magic.InitMagic()
+	position.InitKeys()
+	position.InitAtksKings()
+	position.InitAtksKnights()
+	castlings.InitCastlings()
+	position.PcSqInit()
+	position.InitEndgames()
+}
+
+func passFail(ok bool) string {
+	if ok {
+		return "pass"
+	}
+	return "FAIL"
+}
+
+func percent(n, of int) float64 {
+	if of == 0 {
+		return 0
+	}
+	return 100 * float64(n) / float64(of)
+}
+
+// solved tells if mv is one of the best moves and none of the moves to avoid
+func (t *epd) solved(mv moves.Move) bool {
+	for _, am := range t.am {
+		if am == mv {
+			return false
+		}
+	}
+	if len(t.bm) == 0 {
+		return true
+	}
+	for _, bm := range t.bm {
+		if bm == mv {
+			return true
+		}
+	}
+	return false
+}
+
+// expected returns the bm and am opcodes as written in the file
+func (t *epd) expected() string {
+	var s []string
+	for _, op := range []string{"bm", "am"} {
+		if mvs, ok := t.ops[op]; ok {
+			s = append(s, op+" "+strings.Join(mvs, " "))
+		}
+	}
+	return strings.Join(s, ", ")
+}
+
+func readEPD(path string) ([]*epd, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var tests []*epd
+	sc := bufio.NewScanner(f)
+	lineNo := 0
+	for sc.Scan() {
+		lineNo++
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		t, err := parseEPD(line)
+		if err != nil {
+			return nil, fmt.Errorf("%v line %v: %v", path, lineNo, err)
+		}
+		tests = append(tests, t)
+	}
+	return tests, sc.Err()
+}
+
+// parseEPD reads the four position fields and the opcodes of an EPD line
+func parseEPD(line string) (*epd, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 4 {
+		return nil, fmt.Errorf("an EPD line needs 4 position fields")
+	}
+	b, err := position.ParseFEN(strings.Join(fields[:4], " "))
+	if err != nil {
+		return nil, err
+	}
+
+	t := &epd{b: b, ops: make(map[string][]string)}
+	rest := line
+	for i := 0; i < 4; i++ { // skip the position fields, whatever the spacing
+		rest = strings.TrimSpace(rest)
+		rest = rest[strings.IndexAny(rest+" ", " \t"):]
+	}
+
+	for _, op := range splitOps(rest) {
+		operands := splitOperands(op)
+		if len(operands) == 0 {
+			continue
+		}
+		t.ops[operands[0]] = operands[1:]
+	}
+
+	if ids := t.ops["id"]; len(ids) > 0 {
+		t.id = ids[0]
+	}
+	for _, op := range []string{"bm", "am"} {
+		for _, san := range t.ops[op] {
+			mv, err := b.ParseSAN(san)
+			if err != nil {
+				return nil, fmt.Errorf("%v: %v", op, err)
+			}
+			if op == "bm" {
+				t.bm = append(t.bm, mv)
+			} else {
+				t.am = append(t.am, mv)
+			}
+		}
+	}
+	if len(t.bm) == 0 && len(t.am) == 0 {
+		return nil, fmt.Errorf("no bm or am opcode")
+	}
+	return t, nil
+}
+
+// splitOps splits the operations at the semicolons that are not inside quotes
+func splitOps(s string) []string {
+	var ops []string
+	quoted, start := false, 0
+	for ix, c := range s {
+		switch {
+		case c == '"':
+			quoted = !quoted
+		case c == ';' && !quoted:
+			ops = append(ops, s[start:ix])
+			start = ix + 1
+		}
+	}
+	if strings.TrimSpace(s[start:]) != "" {
+		ops = append(ops, s[start:])
+	}
+	return ops
+}
+
+// splitOperands splits an operation in its opcode and operands. Quoted strings are one operand
+func splitOperands(op string) []string {
+	var operands []string
+	var cur strings.Builder
+	quoted, inWord := false, false
+	for _, c := range op {
+		switch {
+		case c == '"':
+			quoted = !quoted
+			inWord = true
+		case (c == ' ' || c == '\t') && !quoted:
+			if inWord {
+				operands = append(operands, cur.String())
+				cur.Reset()
+			}
+			inWord = false
+		default:
+			cur.WriteRune(c)
+			inWord = true
+		}
+	}
+	if inWord {
+		operands = append(operands, cur.String())
+	}
+	return operands
+}
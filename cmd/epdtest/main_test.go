@@ -0,0 +1,145 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Tecu23/go-game/pkg/chess/moves"
+)
+
+func TestMain(m *testing.M) {
+	Init()
+
+	os.Exit(m.Run())
+}
+
+const startEPD = "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq -"
+
+func TestSplitOps(t *testing.T) {
+	tests := []struct {
+		s    string
+		want []string
+	}{
+		{` bm Qg6; id "WAC.001";`, []string{" bm Qg6", ` id "WAC.001"`}},
+		{` bm Qg6; id "WAC.001"`, []string{" bm Qg6", ` id "WAC.001"`}},
+		{` id "a;b"; bm e4;`, []string{` id "a;b"`, " bm e4"}},
+		{` bm e4 d4;  `, []string{" bm e4 d4"}},
+		{"", nil},
+	}
+
+	for _, tt := range tests {
+		if got := splitOps(tt.s); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitOps(%q) = %q, want %q", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestSplitOperands(t *testing.T) {
+	tests := []struct {
+		op   string
+		want []string
+	}{
+		{" bm Qg6", []string{"bm", "Qg6"}},
+		{" bm\tQg6  Rxf7 ", []string{"bm", "Qg6", "Rxf7"}},
+		{` id "WAC 001"`, []string{"id", "WAC 001"}},
+		{` c0 ""`, []string{"c0", ""}},
+		{"  ", nil},
+	}
+
+	for _, tt := range tests {
+		if got := splitOperands(tt.op); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitOperands(%q) = %q, want %q", tt.op, got, tt.want)
+		}
+	}
+}
+
+// sans returns mvs in SAN on the board of e
+func sans(e *epd, mvs []moves.Move) []string {
+	s := []string{}
+	for _, mv := range mvs {
+		s = append(s, e.b.SAN(mv))
+	}
+	return s
+}
+
+func TestParseEPD(t *testing.T) {
+	tests := []struct {
+		line   string
+		id     string
+		bm, am []string
+		err    string // a part of the error, "" if none
+	}{
+		{
+			line: `2rr3k/pp3pp1/1nnqbN1p/3pN3/2pP4/2P3Q1/PPB4P/R4RK1 w - - bm Qg6; id "WAC.001";`,
+			id:   "WAC.001", bm: []string{"Qg6"}, am: []string{},
+		},
+		{
+			line: startEPD + ` bm e4 d4 Nf3; id "start";`,
+			id:   "start", bm: []string{"e4", "d4", "Nf3"}, am: []string{},
+		},
+		{
+			line: startEPD + "\tam f3 g4; bm e4",
+			bm:   []string{"e4"}, am: []string{"f3", "g4"},
+		},
+		{
+			line: startEPD + ` id "a; b c"; c0 "bm Qxh7"; am Na3`,
+			id:   "a; b c", bm: []string{}, am: []string{"Na3"},
+		},
+		{line: startEPD + ` id "no moves";`, err: "no bm or am"},
+		{line: startEPD + ` bm e5;`, err: "bm"},
+		{line: startEPD + ` am Qxh7;`, err: "am"},
+		{line: "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq", err: "4 position fields"},
+	}
+
+	for _, tt := range tests {
+		e, err := parseEPD(tt.line)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%v: got error %v, want one about %q", tt.line, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: %v", tt.line, err)
+			continue
+		}
+		if e.id != tt.id {
+			t.Errorf("%v: got id %q, want %q", tt.line, e.id, tt.id)
+		}
+		if got := sans(e, e.bm); !reflect.DeepEqual(got, tt.bm) {
+			t.Errorf("%v: got bm %v, want %v", tt.line, got, tt.bm)
+		}
+		if got := sans(e, e.am); !reflect.DeepEqual(got, tt.am) {
+			t.Errorf("%v: got am %v, want %v", tt.line, got, tt.am)
+		}
+	}
+}
+
+func TestSolved(t *testing.T) {
+	tests := []struct {
+		line   string
+		solved map[string]bool // by the move played
+	}{
+		{startEPD + " bm e4 d4;", map[string]bool{"e4": true, "d4": true, "Nf3": false}},
+		{startEPD + " am f3 g4;", map[string]bool{"f3": false, "g4": false, "e4": true}},
+		{startEPD + " bm e4 f3; am f3;", map[string]bool{"e4": true, "f3": false, "d4": false}},
+	}
+
+	for _, tt := range tests {
+		e, err := parseEPD(tt.line)
+		if err != nil {
+			t.Fatalf("%v: %v", tt.line, err)
+		}
+		for san, want := range tt.solved {
+			mv, err := e.b.ParseSAN(san)
+			if err != nil {
+				t.Fatalf("%v: %v", san, err)
+			}
+			if got := e.solved(mv); got != want {
+				t.Errorf("%v: %v solves it %v, want %v", tt.line, san, got, want)
+			}
+		}
+	}
+}
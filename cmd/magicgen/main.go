@@ -0,0 +1,168 @@
+// Command magicgen searches magic numbers for the bishop and rook attack tables of
+// pkg/chess/magic and writes them as Go source.
+//
+// It starts from the shipped tables NBBits, MagicB, NRBits and MagicR (with the rook
+// magics of FillOptimalMagicsR, like InitMagic) and verifies every magic against
+// ComputeAtks for every occupancy of the inner squares. With -fresh new magics are
+// searched for every square with one index bit per inner square. With -shrink n the
+// search goes on with up to n bits less per square, keeping the smallest table found.
+//
+//	magicgen -verify
+//	magicgen -fresh -seed 7 -out tables.go
+//	magicgen -shrink 1 -tries 10000000 -out tables.go
+//
+// The total memory of the attack tables is reported at the end.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"io"
+	"math/bits"
+	"math/rand"
+	"os"
+
+	log "github.com/sirupsen/logrus"
+
+	. "github.com/Tecu23/go-game/pkg/chess/constants"
+	"github.com/Tecu23/go-game/pkg/chess/magic"
+)
+
+// entryBytes is the size of a table entry, one bitboard
+const entryBytes = 8
+
+// table is the magic of every square for bishops or rooks
+type table struct {
+	name   string
+	bishop bool
+	nBits  [64]int
+	magic  [64]uint64
+	size   [64]int // the number of entries of the attack table
+}
+
+func (t *table) bytes() int {
+	sum := 0
+	for _, s := range t.size {
+		sum += s * entryBytes
+	}
+	return sum
+}
+
+func main() {
+	out := flag.String("out", "-", "where to write the tables (- for stdout)")
+	pkg := flag.String("pkg", "magic", "package name of the written file")
+	seed := flag.Int64("seed", 1, "seed of the random magics")
+	tries := flag.Int("tries", 100000000, "random magics to try per square and table size")
+	fresh := flag.Bool("fresh", false, "search new magics instead of starting from the shipped ones")
+	shrink := flag.Int("shrink", 0, "try up to this many index bits less per square")
+	verify := flag.Bool("verify", false, "only verify the shipped tables and report their size")
+	flag.Parse()
+
+	if flag.NArg() != 0 || *shrink < 0 || *tries <= 0 {
+		fmt.Fprintln(os.Stderr, "usage: magicgen [options]")
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+
+	InitFen2Sq()
+	magic.FillOptimalMagicsR()
+	tabs := []*table{
+		{name: "bishop", bishop: true, nBits: magic.NBBits, magic: magic.MagicB},
+		{name: "rook", nBits: magic.NRBits, magic: magic.MagicR},
+	}
+
+	rnd := rand.New(rand.NewSource(*seed))
+	failed := 0
+	for _, t := range tabs {
+		for sq := A1; sq <= H8; sq++ {
+			if *fresh && !*verify {
+				nBits := bits.OnesCount64(magic.InnerAtks(sq, t.bishop))
+				m, size, ok := magic.FindMagic(sq, t.bishop, nBits, rnd, *tries)
+				if !ok {
+					log.Fatalf("no %v magic found for %v with %v bits", t.name, Sq2Fen[sq], nBits)
+				}
+				t.nBits[sq], t.magic[sq], t.size[sq] = nBits, m, size
+			} else {
+				size, ok := magic.Verify(sq, t.bishop, t.magic[sq], t.nBits[sq])
+				if !ok {
+					log.Errorf("the %v magic %#x for %v with %v bits is wrong", t.name, t.magic[sq], Sq2Fen[sq], t.nBits[sq])
+					failed++
+					continue
+				}
+				t.size[sq] = size
+			}
+			if *verify {
+				continue
+			}
+
+			for nBits := t.nBits[sq] - 1; nBits >= t.nBits[sq]-*shrink && nBits > 0; nBits-- {
+				m, size, ok := magic.FindMagic(sq, t.bishop, nBits, rnd, *tries)
+				if !ok {
+					break
+				}
+				log.Infof("%v %v: %v bits with %#x", t.name, Sq2Fen[sq], nBits, m)
+				t.nBits[sq], t.magic[sq], t.size[sq] = nBits, m, size
+			}
+		}
+	}
+	if failed > 0 {
+		log.Fatalf("%v magics are wrong", failed)
+	}
+
+	report := os.Stdout
+	if !*verify {
+		src, err := source(*pkg, tabs)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if *out == "-" {
+			os.Stdout.Write(src)
+			report = os.Stderr
+		} else if err := os.WriteFile(*out, src, 0o644); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	total := 0
+	for _, t := range tabs {
+		fmt.Fprintf(report, "%-7v %7d entries %8d bytes\n", t.name, t.bytes()/entryBytes, t.bytes())
+		total += t.bytes()
+	}
+	fmt.Fprintf(report, "%-7v %7d entries %8d bytes (%.1f KB)\n", "total", total/entryBytes, total, float64(total)/1024)
+}
+
+// source returns the Go source of the tables, formatted like magic.go
+func source(pkg string, tabs []*table) ([]byte, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by magicgen; DO NOT EDIT.\n\npackage %v\n", pkg)
+	for _, t := range tabs {
+		prefix := "R"
+		if t.bishop {
+			prefix = "B"
+		}
+		writeBits(&buf, "N"+prefix+"Bits", &t.nBits)
+		writeMagics(&buf, "Magic"+prefix, &t.magic)
+	}
+	return format.Source(buf.Bytes())
+}
+
+func writeBits(w io.Writer, name string, nBits *[64]int) {
+	fmt.Fprintf(w, "\nvar %v = [64]int{\n", name)
+	for rank := 0; rank < 8; rank++ {
+		for file := 0; file < 8; file++ {
+			fmt.Fprintf(w, "%d, ", nBits[rank*8+file])
+		}
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintln(w, "}")
+}
+
+func writeMagics(w io.Writer, name string, magics *[64]uint64) {
+	fmt.Fprintf(w, "\nvar %v = [64]uint64{\n", name)
+	for _, m := range magics {
+		fmt.Fprintf(w, "%#x,\n", m)
+	}
+	fmt.Fprintln(w, "}")
+}
@@ -0,0 +1,45 @@
+// Command tbgen generates Syzygy tables of the endings with one piece against the bare king.
+//
+// The tables are solved from scratch and written in the Syzygy format, e.g.
+//
+//	tbgen -dir pkg/chess/syzygy/testdata -tables KQvK,KRvK,KBvK,KNvK,KPvK
+//
+// They are meant for tests, the published tables are compressed much better.
+package main
+
+import (
+	"flag"
+	"os"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/Tecu23/go-game/pkg/chess/castlings"
+	. "github.com/Tecu23/go-game/pkg/chess/constants"
+	"github.com/Tecu23/go-game/pkg/chess/magic"
+	"github.com/Tecu23/go-game/pkg/chess/position"
+	"github.com/Tecu23/go-game/pkg/chess/syzygy"
+)
+
+func main() {
+	dir := flag.String("dir", ".", "where to write the tables")
+	tables := flag.String("tables", "KQvK,KRvK,KBvK,KNvK,KPvK", "comma separated endings to generate")
+	flag.Parse()
+
+	InitFen2Sq()
+	magic.InitMagic()
+	position.InitKeys()
+	position.InitAtksKings()
+	position.InitAtksKnights()
+	castlings.InitCastlings()
+
+	if err := os.MkdirAll(*dir, 0o755); err != nil {
+		log.Fatal(err)
+	}
+	for _, code := range strings.Split(*tables, ",") {
+		if err := syzygy.Generate(*dir, strings.TrimSpace(code)); err != nil {
+			log.Fatal(err)
+		}
+		log.Infof("wrote %v", code)
+	}
+}
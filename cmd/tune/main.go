@@ -0,0 +1,239 @@
+// Command tune optimises the evaluation parameters with the Texel method.
+//
+// It reads quiet EPD positions labelled with the game result, e.g.
+//
+//	rnbqkb1r/pp2pppp/5n2/2pp4/3P4/2P1PN2/PP3PPP/RNBQKB1R b KQkq - c9 "1/2-1/2";
+//
+// and minimises the squared error between the result and a sigmoid of the
+// quiescence score. The tuned parameters are written in the format read by
+// position.LoadParams.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/Tecu23/go-game/pkg/chess/castlings"
+	. "github.com/Tecu23/go-game/pkg/chess/constants"
+	"github.com/Tecu23/go-game/pkg/chess/engine"
+	"github.com/Tecu23/go-game/pkg/chess/magic"
+	"github.com/Tecu23/go-game/pkg/chess/position"
+)
+
+type entry struct {
+	b      position.BoardStruct
+	result float64 // 1.0 white won, 0.5 draw, 0.0 black won
+}
+
+func main() {
+	epdFile := flag.String("epd", "", "file with result labelled EPD positions")
+	outFile := flag.String("out", "params.txt", "where to write the tuned parameters")
+	inFile := flag.String("params", "", "parameter file to start from (default: the built in values)")
+	k := flag.Float64("k", 0, "sigmoid scaling constant (0 = fit it to the data)")
+	iters := flag.Int("iters", 100, "maximum number of passes over all parameters")
+	threads := flag.Int("threads", 4, "number of goroutines computing the error")
+	flag.Parse()
+
+	if *epdFile == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	Init()
+
+	if *inFile != "" {
+		if err := position.LoadParamsFile(*inFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	entries, err := readEPD(*epdFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Infof("read %v positions from %v", len(entries), *epdFile)
+
+	if *k == 0 {
+		*k = fitK(entries, *threads)
+	}
+	log.Infof("K = %.4f", *k)
+
+	tune(entries, *k, *iters, *threads, *outFile)
+}
+
+func Init() {
+	InitFen2Sq()
+	magic.InitMagic()
+	position.InitKeys()
+	position.InitAtksKings()
+	position.InitAtksKnights()
+	castlings.InitCastlings()
+	position.PcSqInit()
+	position.InitEndgames()
+}
+
+// readEPD reads all positions and their results from an EPD file
+func readEPD(path string) ([]entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []entry
+	scanner := bufio.NewScanner(f)
+	line := 0
+	for scanner.Scan() {
+		line++
+		txt := strings.TrimSpace(scanner.Text())
+		if txt == "" {
+			continue
+		}
+
+		fen, result, err := parseLine(txt)
+		if err != nil {
+			return nil, fmt.Errorf("%v:%v: %v", path, line, err)
+		}
+
+		b, err := position.ParseFEN(fen)
+		if err != nil {
+			return nil, fmt.Errorf("%v:%v: %v", path, line, err)
+		}
+		entries = append(entries, entry{b: *b, result: result})
+	}
+
+	return entries, scanner.Err()
+}
+
+// parseLine splits an EPD line into the FEN part and the game result.
+// The result can be given as c9 "1-0", as [1.0] or as a trailing 1-0 token
+func parseLine(line string) (string, float64, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 5 {
+		return "", 0, fmt.Errorf("too few fields in %q", line)
+	}
+	fen := strings.Join(fields[:4], " ")
+
+	rest := strings.Join(fields[4:], " ")
+	if ix := strings.Index(rest, "c9"); ix >= 0 {
+		rest = rest[ix+2:]
+	}
+	rest = strings.Trim(rest, " \t\";[]")
+
+	switch {
+	case strings.HasPrefix(rest, "1-0"), strings.HasPrefix(rest, "1.0"):
+		return fen, 1.0, nil
+	case strings.HasPrefix(rest, "0-1"), strings.HasPrefix(rest, "0.0"):
+		return fen, 0.0, nil
+	case strings.HasPrefix(rest, "1/2-1/2"), strings.HasPrefix(rest, "0.5"):
+		return fen, 0.5, nil
+	}
+
+	return "", 0, fmt.Errorf("no game result in %q", line)
+}
+
+// sigmoid maps a centipawn score to an expected result between 0 and 1
+func sigmoid(k float64, sc int) float64 {
+	return 1.0 / (1.0 + math.Pow(10, -k*float64(sc)/400))
+}
+
+// evalError is the mean squared difference between the results and the
+// sigmoid of the quiescence scores with the current parameters
+func evalError(entries []entry, k float64, threads int) float64 {
+	sums := make([]float64, threads)
+	var wg sync.WaitGroup
+	for t := 0; t < threads; t++ {
+		wg.Add(1)
+		go func(t int) {
+			defer wg.Done()
+			for ix := t; ix < len(entries); ix += threads {
+				e := &entries[ix]
+				// qs gives the score from stm pov. We want it from white pov
+				sc := engine.SignEval(e.b.Stm, engine.Qs(MaxEval, &e.b))
+				diff := e.result - sigmoid(k, sc)
+				sums[t] += diff * diff
+			}
+		}(t)
+	}
+	wg.Wait()
+
+	sum := 0.0
+	for _, s := range sums {
+		sum += s
+	}
+	return sum / float64(len(entries))
+}
+
+// fitK finds the scaling constant that gives the lowest error with the current parameters
+func fitK(entries []entry, threads int) float64 {
+	start, end, step := 0.0, 10.0, 1.0
+	best, bestErr := 0.0, math.MaxFloat64
+
+	for precision := 0; precision < 10; precision++ {
+		for k := start; k <= end; k += step {
+			e := evalError(entries, k, threads)
+			if e < bestErr {
+				best, bestErr = k, e
+			}
+		}
+		start, end, step = math.Max(best-step, 0), best+step, step/10
+	}
+
+	return best
+}
+
+// tune runs a local search over all parameters until no single step improves the error.
+// The parameters are saved after every pass so that a long run can be stopped at any time
+func tune(entries []entry, k float64, iters, threads int, outFile string) {
+	params := position.Params()
+	bestErr := evalError(entries, k, threads)
+	log.Infof("start error %.8f", bestErr)
+
+	for it := 1; it <= iters; it++ {
+		improved := false
+		for _, p := range params {
+			orig := *p.Val
+			for _, step := range []int{+1, -1} {
+				*p.Val = orig + step
+				position.InitParams()
+
+				if e := evalError(entries, k, threads); e < bestErr {
+					bestErr = e
+					improved = true
+					break
+				}
+				*p.Val = orig
+			}
+		}
+		position.InitParams()
+
+		log.Infof("pass %v error %.8f", it, bestErr)
+		if err := save(outFile); err != nil {
+			log.Fatal(err)
+		}
+
+		if !improved {
+			break
+		}
+	}
+}
+
+func save(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := position.SaveParams(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"math"
+	"os"
+	"testing"
+
+	"github.com/Tecu23/go-game/pkg/chess/position"
+)
+
+func TestMain(m *testing.M) {
+	Init()
+
+	os.Exit(m.Run())
+}
+
+// fixture are quiet positions where the side a piece up mostly won, and an equal one drawn.
+// The drawn rook ending keeps the best K finite
+var fixture = []string{
+	`4k3/8/8/8/8/8/8/3QK3 w - - c9 "1-0";`,
+	`4k3/8/8/8/8/8/8/3RK3 b - - c9 "1-0";`,
+	`3qk3/8/8/8/8/8/8/4K3 w - - c9 "0-1";`,
+	`3rk3/8/8/8/8/8/8/4K3 b - - [0.0]`,
+	`4k3/4p3/8/8/8/8/4P3/4K3 w - - 1/2-1/2`,
+	`3rk3/8/8/8/8/8/8/4K3 w - - 1/2-1/2`,
+}
+
+func readFixture(t *testing.T) []entry {
+	var entries []entry
+	for _, line := range fixture {
+		fen, result, err := parseLine(line)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := position.ParseFEN(fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, entry{b: *b, result: result})
+	}
+	return entries
+}
+
+func TestParseLine(t *testing.T) {
+	want := []float64{1, 1, 0, 0, 0.5, 0.5}
+	for ix, line := range fixture {
+		if _, got, err := parseLine(line); err != nil || got != want[ix] {
+			t.Errorf("%q: got %v, %v, want %v", line, got, err, want[ix])
+		}
+	}
+	if _, _, err := parseLine("4k3/8/8/8/8/8/8/4K3 w - - c9 \"*\";"); err == nil {
+		t.Errorf("a line without a result parses")
+	}
+}
+
+func TestFitK(t *testing.T) {
+	entries := readFixture(t)
+
+	// with K = 0 every position is predicted as a draw
+	if got := evalError(entries, 0, 2); math.Abs(got-1.0/6) > 1e-9 {
+		t.Errorf("the error with K = 0 is %v, want 1/6", got)
+	}
+
+	k := fitK(entries, 2)
+	if k <= 0 || k > 10 {
+		t.Fatalf("fitK = %v, want a K between 0 and 10", k)
+	}
+	e := evalError(entries, k, 3)
+	if e >= 1.0/6 || math.Abs(e-evalError(entries, k, 1)) > 1e-9 {
+		t.Errorf("the error with the fitted K %v is %v, want below 1/6 and the same for any number of threads", k, e)
+	}
+	for _, other := range []float64{k / 2, k * 2} {
+		if evalError(entries, other, 2) < e {
+			t.Errorf("K %v gives a lower error than the fitted K %v", other, k)
+		}
+	}
+}
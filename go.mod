@@ -3,9 +3,12 @@ module github.com/Tecu23/go-game
 go 1.22.0
 
 require (
-	github.com/gorilla/websocket v1.5.1 // indirect
+	github.com/gorilla/websocket v1.5.1
+	github.com/sirupsen/logrus v1.9.3
+)
+
+require (
 	github.com/julienschmidt/httprouter v1.3.0 // indirect
-	github.com/sirupsen/logrus v1.9.3 // indirect
 	golang.org/x/net v0.17.0 // indirect
 	golang.org/x/sys v0.13.0 // indirect
 )
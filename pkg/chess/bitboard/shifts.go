@@ -0,0 +1,127 @@
+package bitboard
+
+import "math/bits"
+
+// the files a and h, a step east or west must not land on them from the other side
+const (
+	fileA = BitBoard(0x0101010101010101)
+	fileH = BitBoard(0x8080808080808080)
+	rank1 = BitBoard(0x00000000000000FF)
+	diag  = BitBoard(0x8040201008040201) // a1-h8
+	anti  = BitBoard(0x0102040810204080) // h1-a8
+)
+
+// FileMask returns the squares of file f, 0 for the a file
+func FileMask(f int) BitBoard {
+	return fileA << uint(f)
+}
+
+// RankMask returns the squares of rank r, 0 for the first rank
+func RankMask(r int) BitBoard {
+	return rank1 << uint(8*r)
+}
+
+// DiagMask returns the squares of the diagonal through sq that runs like a1-h8
+func DiagMask(sq int) BitBoard {
+	d := 8*(sq&7) - (sq &^ 7)
+	if d >= 0 {
+		return diag >> uint(d)
+	}
+	return diag << uint(-d)
+}
+
+// AntiDiagMask returns the squares of the diagonal through sq that runs like h1-a8
+func AntiDiagMask(sq int) BitBoard {
+	d := 56 - 8*(sq&7) - (sq &^ 7)
+	if d >= 0 {
+		return anti >> uint(d)
+	}
+	return anti << uint(-d)
+}
+
+// North returns b one rank up
+func (b BitBoard) North() BitBoard { return b << 8 }
+
+// South returns b one rank down
+func (b BitBoard) South() BitBoard { return b >> 8 }
+
+// East returns b one file to the right, the h file drops off
+func (b BitBoard) East() BitBoard { return (b &^ fileH) << 1 }
+
+// West returns b one file to the left, the a file drops off
+func (b BitBoard) West() BitBoard { return (b &^ fileA) >> 1 }
+
+// NorthEast returns b one square up and to the right
+func (b BitBoard) NorthEast() BitBoard { return (b &^ fileH) << 9 }
+
+// NorthWest returns b one square up and to the left
+func (b BitBoard) NorthWest() BitBoard { return (b &^ fileA) << 7 }
+
+// SouthEast returns b one square down and to the right
+func (b BitBoard) SouthEast() BitBoard { return (b &^ fileH) >> 7 }
+
+// SouthWest returns b one square down and to the left
+func (b BitBoard) SouthWest() BitBoard { return (b &^ fileA) >> 9 }
+
+// NorthFill returns b with every square above a set square set
+func (b BitBoard) NorthFill() BitBoard {
+	b |= b << 8
+	b |= b << 16
+	b |= b << 32
+	return b
+}
+
+// SouthFill returns b with every square below a set square set
+func (b BitBoard) SouthFill() BitBoard {
+	b |= b >> 8
+	b |= b >> 16
+	b |= b >> 32
+	return b
+}
+
+// FileFill returns the files with a set square
+func (b BitBoard) FileFill() BitBoard {
+	return b.NorthFill() | b.SouthFill()
+}
+
+// NorthSpan returns the squares above the set squares, the set squares not included
+func (b BitBoard) NorthSpan() BitBoard {
+	return b.NorthFill().North()
+}
+
+// SouthSpan returns the squares below the set squares, the set squares not included
+func (b BitBoard) SouthSpan() BitBoard {
+	return b.SouthFill().South()
+}
+
+// FrontSpan returns the squares in front of pawns of white, or of black if white is false.
+// A pawn is passed if the front spans of the enemy pawns on its file and beside it are clear
+func (b BitBoard) FrontSpan(white bool) BitBoard {
+	if white {
+		return b.NorthSpan()
+	}
+	return b.SouthSpan()
+}
+
+// RearSpan returns the squares behind pawns of white, or of black if white is false
+func (b BitBoard) RearSpan(white bool) BitBoard {
+	return b.FrontSpan(!white)
+}
+
+// Squares returns an iterator over the set squares from a1 to h8. With range over func
+// (Go 1.23, or 1.22 with GOEXPERIMENT=rangefunc)
+//
+//	for sq := range bb.Squares() {
+//
+// and with older compilers by calling it with a yield function
+func (b BitBoard) Squares() func(yield func(int) bool) {
+	return func(yield func(int) bool) {
+		for b != 0 {
+			sq := bits.TrailingZeros64(uint64(b))
+			b &= b - 1
+			if !yield(sq) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package bitboard
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// bb returns a bitboard of the squares given like "a1 e4 h8"
+func bb(sqs string) BitBoard {
+	var b BitBoard
+	for _, s := range strings.Fields(sqs) {
+		b.SetBit(sq(s))
+	}
+	return b
+}
+
+func sq(s string) int {
+	return int(s[1]-'1')*8 + int(s[0]-'a')
+}
+
+func TestMasks(t *testing.T) {
+	tests := []struct {
+		name string
+		got  BitBoard
+		want BitBoard
+	}{
+		{"file a", FileMask(0), bb("a1 a2 a3 a4 a5 a6 a7 a8")},
+		{"file e", FileMask(4), bb("e1 e2 e3 e4 e5 e6 e7 e8")},
+		{"rank 1", RankMask(0), bb("a1 b1 c1 d1 e1 f1 g1 h1")},
+		{"rank 8", RankMask(7), bb("a8 b8 c8 d8 e8 f8 g8 h8")},
+		{"diag a1", DiagMask(sq("a1")), bb("a1 b2 c3 d4 e5 f6 g7 h8")},
+		{"diag e4", DiagMask(sq("e4")), bb("b1 c2 d3 e4 f5 g6 h7")},
+		{"diag c6", DiagMask(sq("c6")), bb("a4 b5 c6 d7 e8")},
+		{"diag h1", DiagMask(sq("h1")), bb("h1")},
+		{"anti h1", AntiDiagMask(sq("h1")), bb("h1 g2 f3 e4 d5 c6 b7 a8")},
+		{"anti e4", AntiDiagMask(sq("e4")), bb("h1 g2 f3 e4 d5 c6 b7 a8")},
+		{"anti b2", AntiDiagMask(sq("b2")), bb("c1 b2 a3")},
+		{"anti g7", AntiDiagMask(sq("g7")), bb("h6 g7 f8")},
+		{"anti a1", AntiDiagMask(sq("a1")), bb("a1")},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%v: got\n%vwant\n%v", tt.name, tt.got.Stringln(), tt.want.Stringln())
+		}
+	}
+}
+
+func TestShifts(t *testing.T) {
+	b := bb("a1 h1 d4 a8 h8")
+	tests := []struct {
+		name string
+		got  BitBoard
+		want BitBoard
+	}{
+		{"North", b.North(), bb("a2 h2 d5")},
+		{"South", b.South(), bb("d3 a7 h7")},
+		{"East", b.East(), bb("b1 e4 b8")},
+		{"West", b.West(), bb("g1 c4 g8")},
+		{"NorthEast", b.NorthEast(), bb("b2 e5")},
+		{"NorthWest", b.NorthWest(), bb("g2 c5")},
+		{"SouthEast", b.SouthEast(), bb("e3 b7")},
+		{"SouthWest", b.SouthWest(), bb("c3 g7")},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%v: got\n%vwant\n%v", tt.name, tt.got.Stringln(), tt.want.Stringln())
+		}
+	}
+}
+
+func TestFillsAndSpans(t *testing.T) {
+	b := bb("b3 e6")
+	tests := []struct {
+		name string
+		got  BitBoard
+		want BitBoard
+	}{
+		{"NorthFill", b.NorthFill(), bb("b3 b4 b5 b6 b7 b8 e6 e7 e8")},
+		{"SouthFill", b.SouthFill(), bb("b1 b2 b3 e1 e2 e3 e4 e5 e6")},
+		{"FileFill", b.FileFill(), FileMask(1) | FileMask(4)},
+		{"NorthSpan", b.NorthSpan(), bb("b4 b5 b6 b7 b8 e7 e8")},
+		{"SouthSpan", b.SouthSpan(), bb("b1 b2 e1 e2 e3 e4 e5")},
+		{"FrontSpan white", b.FrontSpan(true), bb("b4 b5 b6 b7 b8 e7 e8")},
+		{"FrontSpan black", b.FrontSpan(false), bb("b1 b2 e1 e2 e3 e4 e5")},
+		{"RearSpan white", b.RearSpan(true), bb("b1 b2 e1 e2 e3 e4 e5")},
+		{"RearSpan black", b.RearSpan(false), bb("b4 b5 b6 b7 b8 e7 e8")},
+		{"empty", BitBoard(0).FileFill(), 0},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%v: got\n%vwant\n%v", tt.name, tt.got.Stringln(), tt.want.Stringln())
+		}
+	}
+
+	// a passed pawn: no black pawn in front of it on its file or beside it
+	white, black := bb("d5 g4"), bb("a7 h6")
+	blocked := (black.FrontSpan(false) | black.FrontSpan(false).East() | black.FrontSpan(false).West()) & white
+	if want := bb("g4"); blocked != want {
+		t.Errorf("got the pawns %x stopped, want %x", blocked, want)
+	}
+}
+
+func TestSquares(t *testing.T) {
+	tests := []struct {
+		b    BitBoard
+		stop int // stop after this many squares, 0 for all
+		want []int
+	}{
+		{0, 0, nil},
+		{bb("a1"), 0, []int{0}},
+		{bb("h8 a1 e4"), 0, []int{0, 28, 63}},
+		{bb("h8 a1 e4"), 2, []int{0, 28}},
+		{^BitBoard(0), 1, []int{0}},
+	}
+	for _, tt := range tests {
+		var got []int
+		tt.b.Squares()(func(sq int) bool {
+			got = append(got, sq)
+			return tt.stop == 0 || len(got) < tt.stop
+		})
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%x: got %v, want %v", uint64(tt.b), got, tt.want)
+		}
+	}
+
+	cnt, full := 0, ^BitBoard(0)
+	full.Squares()(func(int) bool { cnt++; return true })
+	if cnt != 64 {
+		t.Errorf("got %v squares of the full board, want 64", cnt)
+	}
+}
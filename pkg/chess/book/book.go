@@ -0,0 +1,207 @@
+// Package book reads and writes opening books in the Polyglot .bin format.
+//
+// A book is a list of 16 byte big-endian entries sorted by key:
+//
+//	key    uint64 // Polyglot key of the position, see Key
+//	move   uint16 // to file, to row, from file, from row (3 bits each) and the promotion piece
+//	weight uint16 // how often the move should be played, relative to the other moves
+//	learn  uint32 // not used
+//
+// Castling is stored as the king taking its own rook, e1h1 for e1g1.
+package book
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"sort"
+
+	. "github.com/Tecu23/go-game/pkg/chess/constants"
+	"github.com/Tecu23/go-game/pkg/chess/moves"
+	"github.com/Tecu23/go-game/pkg/chess/position"
+)
+
+const entrySize = 16
+
+// Entry is one move of the book
+type Entry struct {
+	Key    uint64
+	Move   uint16
+	Weight uint16
+	Learn  uint32
+}
+
+// Book holds all entries of a book file sorted by key
+type Book struct {
+	entries []Entry
+}
+
+var (
+	// OwnBook tells the engine to play moves from the active book
+	OwnBook bool
+	// Active is the book used by the engine. nil means that no book is loaded
+	Active *Book
+)
+
+// Read reads a book in the Polyglot format
+func Read(r io.Reader) (*Book, error) {
+	br := bufio.NewReader(r)
+	bk := &Book{}
+	var buf [entrySize]byte
+	for {
+		if _, err := io.ReadFull(br, buf[:]); err != nil {
+			if err == io.EOF {
+				break
+			}
+			if err == io.ErrUnexpectedEOF {
+				return nil, fmt.Errorf("book: the size is not a multiple of %v bytes", entrySize)
+			}
+			return nil, err
+		}
+
+		bk.entries = append(bk.entries, Entry{
+			Key:    binary.BigEndian.Uint64(buf[0:]),
+			Move:   binary.BigEndian.Uint16(buf[8:]),
+			Weight: binary.BigEndian.Uint16(buf[10:]),
+			Learn:  binary.BigEndian.Uint32(buf[12:]),
+		})
+	}
+
+	// the format requires sorted entries, but don't trust every book generator
+	sort.SliceStable(bk.entries, func(i, j int) bool { return bk.entries[i].Key < bk.entries[j].Key })
+	return bk, nil
+}
+
+// Write writes the entries as a book, sorted by key
+func Write(w io.Writer, entries []Entry) error {
+	sorted := append([]Entry(nil), entries...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Key < sorted[j].Key })
+
+	bw := bufio.NewWriter(w)
+	var buf [entrySize]byte
+	for _, e := range sorted {
+		binary.BigEndian.PutUint64(buf[0:], e.Key)
+		binary.BigEndian.PutUint16(buf[8:], e.Move)
+		binary.BigEndian.PutUint16(buf[10:], e.Weight)
+		binary.BigEndian.PutUint32(buf[12:], e.Learn)
+		if _, err := bw.Write(buf[:]); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// Load reads the book in the file at path and makes it the active book
+func Load(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	bk, err := Read(f)
+	if err != nil {
+		return err
+	}
+
+	Active = bk
+	return nil
+}
+
+// Unload removes the active book
+func Unload() {
+	Active = nil
+}
+
+// Len returns the number of entries in the book
+func (bk *Book) Len() int {
+	return len(bk.entries)
+}
+
+// Entries returns all entries with the key
+func (bk *Book) Entries(key uint64) []Entry {
+	lo := sort.Search(len(bk.entries), func(i int) bool { return bk.entries[i].Key >= key })
+	hi := lo
+	for hi < len(bk.entries) && bk.entries[hi].Key == key {
+		hi++
+	}
+	return bk.entries[lo:hi]
+}
+
+// Moves returns the legal book moves in the position on b together with their weights
+func (bk *Book) Moves(b *position.BoardStruct) ([]moves.Move, []int) {
+	var ml moves.MoveList
+	b.GenAllLegals(&ml)
+
+	var mvs []moves.Move
+	var weights []int
+	for _, e := range bk.Entries(Key(b)) {
+		if mv, ok := DecodeMove(b, e.Move, ml); ok {
+			mvs = append(mvs, mv)
+			weights = append(weights, int(e.Weight))
+		}
+	}
+	return mvs, weights
+}
+
+// Pick chooses one of the book moves at random, each move with a chance proportional to its weight.
+// It returns false if there is no book move with a weight
+func (bk *Book) Pick(b *position.BoardStruct) (moves.Move, bool) {
+	mvs, weights := bk.Moves(b)
+	total := 0
+	for _, w := range weights {
+		total += w
+	}
+	if total == 0 {
+		return moves.NoMove, false
+	}
+
+	r := rand.Intn(total)
+	for ix, w := range weights {
+		if r < w {
+			return mvs[ix], true
+		}
+		r -= w
+	}
+	return moves.NoMove, false // not reached
+}
+
+// Probe picks a move from the active book if OwnBook is on
+func Probe(b *position.BoardStruct) (moves.Move, bool) {
+	if !OwnBook || Active == nil {
+		return moves.NoMove, false
+	}
+	return Active.Pick(b)
+}
+
+// EncodeMove packs mv, a move on b, the way it is stored in a book. The engine encodes
+// castlings as the king taking its own rook too
+func EncodeMove(b *position.BoardStruct, mv moves.Move) uint16 {
+	fr, to := mv.Fr(), mv.To()
+
+	pr := 0
+	if mv.Pr() != Empty {
+		pr = mv.Pr() / 2
+	}
+	return uint16(to | fr<<6 | pr<<12)
+}
+
+// DecodeMove finds the move in ml (the legal moves on b) that is encoded as m in a book
+func DecodeMove(b *position.BoardStruct, m uint16, ml moves.MoveList) (moves.Move, bool) {
+	to := int(m & 0x3f)
+	fr := int(m>>6) & 0x3f
+	pr := int(m>>12) & 0x7 // 1 knight, 2 bishop, 3 rook, 4 queen
+
+	for _, mv := range ml {
+		if mv.Fr() != fr || mv.To() != to {
+			continue
+		}
+		if mv.Pr() == Empty && pr == 0 || mv.Pr() != Empty && mv.Pr()/2 == pr {
+			return mv, true
+		}
+	}
+	return moves.NoMove, false
+}
@@ -0,0 +1,155 @@
+package book
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"testing"
+
+	"github.com/Tecu23/go-game/pkg/chess/castlings"
+	. "github.com/Tecu23/go-game/pkg/chess/constants"
+	"github.com/Tecu23/go-game/pkg/chess/magic"
+	"github.com/Tecu23/go-game/pkg/chess/moves"
+	"github.com/Tecu23/go-game/pkg/chess/position"
+)
+
+func TestMain(m *testing.M) {
+	InitFen2Sq()
+	magic.InitMagic()
+	position.InitKeys()
+	position.InitAtksKings()
+	position.InitAtksKnights()
+	castlings.InitCastlings()
+	position.PcSqInit()
+
+	os.Exit(m.Run())
+}
+
+// the keys published with the Polyglot book format
+func TestKey(t *testing.T) {
+	tests := []struct {
+		mvs string
+		key uint64
+	}{
+		{"", 0x463b96181691fc9c},
+		{"e2e4", 0x823c9b50fd114196},
+		{"e2e4 d7d5", 0x0756b94461c50fb0},
+		{"e2e4 d7d5 e4e5", 0x662fafb965db29d4},
+		{"e2e4 d7d5 e4e5 f7f5", 0x22a48b5a8e47ff78},
+		{"e2e4 d7d5 e4e5 f7f5 e1e2", 0x652a607ca3f242c1},
+		{"e2e4 d7d5 e4e5 f7f5 e1e2 e8f7", 0x00fdd303c946bdd9},
+		{"a2a4 b7b5 h2h4 b5b4 c2c4", 0x3c8123ea7b067637},
+		{"a2a4 b7b5 h2h4 b5b4 c2c4 b4c3 a1a3", 0x5c3f9b829b279560},
+	}
+
+	for _, tt := range tests {
+		position.Board.NewGame()
+		if err := position.ParseMvs(tt.mvs); err != nil {
+			t.Fatalf("%v: %v", tt.mvs, err)
+		}
+		if got := Key(&position.Board); got != tt.key {
+			t.Errorf("%q: got %016x, want %016x", tt.mvs, got, tt.key)
+		}
+	}
+}
+
+func encode(entries []Entry) []byte {
+	var buf bytes.Buffer
+	for _, e := range entries {
+		binary.Write(&buf, binary.BigEndian, e)
+	}
+	return buf.Bytes()
+}
+
+// bookMove packs a move the way it is stored in a book
+func bookMove(fr, to string, pr int) uint16 {
+	return uint16(Fen2Sq[to] | Fen2Sq[fr]<<6 | pr<<12)
+}
+
+func TestMoves(t *testing.T) {
+	b, err := position.ParseFEN("r3k2r/1P6/8/8/8/8/8/R3K2R w KQkq - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := Key(b)
+
+	bk, err := Read(bytes.NewReader(encode([]Entry{
+		{Key: 1, Move: bookMove("a1", "a2", 0), Weight: 1},
+		{Key: key, Move: bookMove("e1", "h1", 0), Weight: 3},  // castle short
+		{Key: key, Move: bookMove("e1", "a1", 0), Weight: 0},  // castle long
+		{Key: key, Move: bookMove("b7", "a8", 2), Weight: 1},  // promote to a bishop
+		{Key: key, Move: bookMove("e1", "e3", 0), Weight: 10}, // illegal
+	})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bk.Len() != 5 {
+		t.Fatalf("got %v entries, want 5", bk.Len())
+	}
+
+	mvs, weights := bk.Moves(b)
+	want := []string{"e1g1", "e1c1", "b7a8b"}
+	if len(mvs) != len(want) {
+		t.Fatalf("got %v moves, want %v", len(mvs), len(want))
+	}
+	for ix, mv := range mvs {
+		if got := b.UCI(mv); got != want[ix] {
+			t.Errorf("move %v: got %v, want %v", ix, got, want[ix])
+		}
+	}
+	if mvs[2].Pr() != WB {
+		t.Errorf("got promotion to %v, want a bishop", mvs[2].Pr())
+	}
+	if weights[0] != 3 || weights[1] != 0 {
+		t.Errorf("got weights %v", weights)
+	}
+
+	// the long castling has no weight and is never picked
+	for i := 0; i < 100; i++ {
+		mv, ok := bk.Pick(b)
+		if !ok || b.UCI(mv) == "e1c1" {
+			t.Fatalf("picked %v %v", mv, ok)
+		}
+	}
+}
+
+func TestRead(t *testing.T) {
+	if _, err := Read(bytes.NewReader(make([]byte, 20))); err == nil {
+		t.Error("a truncated book should give an error")
+	}
+}
+
+func TestWrite(t *testing.T) {
+	b, err := position.ParseFEN("r3k2r/1P6/8/8/8/8/8/R3K2R w KQkq - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ml moves.MoveList
+	b.GenAllLegals(&ml)
+
+	key := Key(b)
+	var entries []Entry
+	for ix, mv := range ml {
+		entries = append(entries, Entry{Key: key, Move: EncodeMove(b, mv), Weight: uint16(ix + 1)})
+	}
+	entries = append(entries, Entry{Key: key - 1, Move: 1, Weight: 1})
+
+	var buf bytes.Buffer
+	if err := Write(&buf, entries); err != nil {
+		t.Fatal(err)
+	}
+	bk, err := Read(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mvs, weights := bk.Moves(b)
+	if len(mvs) != len(ml) {
+		t.Fatalf("got %v moves back, want %v", len(mvs), len(ml))
+	}
+	for ix, mv := range mvs {
+		if mv != ml[ix] || weights[ix] != ix+1 {
+			t.Errorf("got %v (weight %v), want %v", mv, weights[ix], ml[ix])
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package book
+
+import (
+	"github.com/Tecu23/go-game/pkg/chess/bitboard"
+	. "github.com/Tecu23/go-game/pkg/chess/constants"
+	"github.com/Tecu23/go-game/pkg/chess/position"
+)
+
+// offsets in random64
+const (
+	castleOffset = 768
+	epOffset     = 772
+	turnOffset   = 780
+)
+
+// Key returns the Polyglot key of the position on b. It differs from the engine's own
+// zobrist key, which uses other random numbers and a different piece order
+func Key(b *position.BoardStruct) uint64 {
+	var key uint64
+
+	all := b.AllBB()
+	for all != 0 {
+		sq := all.FirstOne()
+		// polyglot orders the pieces black pawn, white pawn, black knight..., we have WP=0, BP=1, WN=2...
+		key ^= random64[64*(b.Squares[sq]^1)+sq]
+	}
+
+	// the castling flags are in the same order: white short, white long, black short, black long
+	for i := uint(0); i < 4; i++ {
+		if uint(b.Castlings)&(1<<i) != 0 {
+			key ^= random64[castleOffset+int(i)]
+		}
+	}
+
+	if b.Ep != 0 && epCapture(b) {
+		key ^= random64[epOffset+b.Ep%8]
+	}
+
+	if b.Stm == WHITE {
+		key ^= random64[turnOffset]
+	}
+	return key
+}
+
+// epCapture returns true if a pawn of the side to move stands next to the pawn that just moved two squares.
+// Polyglot only hashes the en passant file then, even if the capture is illegal
+func epCapture(b *position.BoardStruct) bool {
+	pawnSq := b.Ep - 8 // the pawn that moved, white to move
+	if b.Stm == BLACK {
+		pawnSq = b.Ep + 8
+	}
+
+	var sides bitboard.BitBoard
+	if pawnSq%8 != 0 {
+		sides |= bitboard.BitBoard(1) << uint(pawnSq-1)
+	}
+	if pawnSq%8 != 7 {
+		sides |= bitboard.BitBoard(1) << uint(pawnSq+1)
+	}
+	return sides&b.PieceBB[Pawn]&b.WbBB[b.Stm] != 0
+}
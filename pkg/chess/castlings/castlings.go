@@ -16,21 +16,63 @@ const (
 	LongB  = uint(0x8) // black can castle short
 )
 
-type castlOptions struct {
-	Short                                uint // flag
-	Long                                 uint // flag
-	Rook                                 int  // rook pc (wR/bR)
-	KingPos                              int  // king pos
-	RookSh                               uint // rook pos short
-	RookL                                uint // rook pos long
-	BetweenSh                            bitboard.BitBoard
-	BetweenL                             bitboard.BitBoard
-	PawnsSh, PawnsL, KnightsSh, KnightsL bitboard.BitBoard
+// Options are the squares of the castlings of one side. In chess the king starts on e1 and
+// the rooks on h1 and a1, in Chess960 they can start anywhere on the first rank with the
+// king between the rooks. The king and the rook always end on g1 and f1 (short) or c1 and
+// d1 (long)
+type Options struct {
+	Short     uint              // flag
+	Long      uint              // flag
+	Rook      int               // rook pc (wR/bR)
+	KingPos   int               // king pos
+	RookSh    int               // rook pos short
+	RookL     int               // rook pos long
+	KingToSh  int               // king pos after short castling
+	KingToL   int               // king pos after long castling
+	RookToSh  int               // rook pos after short castling
+	RookToL   int               // rook pos after long castling
+	BetweenSh bitboard.BitBoard // squares that must be empty, the king and the rook left out
+	BetweenL  bitboard.BitBoard
+	PathSh    bitboard.BitBoard // squares the king is on or goes over, they must not be attacked
+	PathL     bitboard.BitBoard
 }
 
-var Castl = [2]castlOptions{
-	{ShortW, LongW, WR, E1, H1, A1, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0},
-	{ShortB, LongB, BR, E8, H8, A8, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0},
+// Castl are the castlings of chess
+var Castl = [2]Options{
+	{Short: ShortW, Long: LongW, Rook: WR, KingPos: E1, RookSh: H1, RookL: A1},
+	{Short: ShortB, Long: LongB, Rook: BR, KingPos: E8, RookSh: H8, RookL: A8},
+}
+
+// NewOptions returns the castlings of side sd with the king starting on king and the
+// rooks on rookSh and rookL
+func NewOptions(sd Color, king, rookSh, rookL int) Options {
+	o := Castl[sd]
+	rank := A1
+	if sd == BLACK {
+		rank = A8
+	}
+
+	o.KingPos, o.RookSh, o.RookL = king, rookSh, rookL
+	o.KingToSh, o.RookToSh = rank+G1, rank+F1
+	o.KingToL, o.RookToL = rank+C1, rank+D1
+
+	o.PathSh = span(king, o.KingToSh)
+	o.PathL = span(king, o.KingToL)
+	o.BetweenSh = (o.PathSh | span(rookSh, o.RookToSh)) &^ (span(king, king) | span(rookSh, rookSh))
+	o.BetweenL = (o.PathL | span(rookL, o.RookToL)) &^ (span(king, king) | span(rookL, rookL))
+	return o
+}
+
+// span returns the squares from a to b on a rank, both included
+func span(a, b int) bitboard.BitBoard {
+	if a > b {
+		a, b = b, a
+	}
+	var bb bitboard.BitBoard
+	for sq := a; sq <= b; sq++ {
+		bb.SetBit(sq)
+	}
+	return bb
 }
 
 func (c Castlings) Flags(sd Color) bool {
@@ -53,7 +95,8 @@ func (c *Castlings) Off(val uint) {
 	(*c) &= Castlings(^val)
 }
 
-// ParseCastlings should parse the castlings part of a fen string
+// ParseCastlings should parse the castlings part of a fen string. Only KQkq are read, see
+// position.ParseFEN for the files of Shredder-FEN
 func ParseCastlings(fenCastl string) Castlings {
 	c := uint(0)
 
@@ -97,92 +140,8 @@ func (c Castlings) String() string {
 	return flags
 }
 
+// InitCastlings sets up the castlings of chess, the king on e1 and the rooks on h1 and a1
 func InitCastlings() {
-	// squares between K and R short castling
-	Castl[WHITE].BetweenSh.SetBit(F1)
-	Castl[WHITE].BetweenSh.SetBit(G1)
-	Castl[BLACK].BetweenSh.SetBit(F8)
-	Castl[BLACK].BetweenSh.SetBit(G8)
-
-	// squares between K and R long castling
-	Castl[WHITE].BetweenL.SetBit(B1)
-	Castl[WHITE].BetweenL.SetBit(C1)
-	Castl[WHITE].BetweenL.SetBit(D1)
-	Castl[BLACK].BetweenL.SetBit(B8)
-	Castl[BLACK].BetweenL.SetBit(C8)
-	Castl[BLACK].BetweenL.SetBit(D8)
-
-	// pawns stop short castling W
-	Castl[WHITE].PawnsSh.SetBit(D2)
-	Castl[WHITE].PawnsSh.SetBit(E2)
-	Castl[WHITE].PawnsSh.SetBit(F2)
-	Castl[WHITE].PawnsSh.SetBit(G2)
-	Castl[WHITE].PawnsSh.SetBit(H2)
-	// pawns stop long castling W
-	Castl[WHITE].PawnsL.SetBit(B2)
-	Castl[WHITE].PawnsL.SetBit(C2)
-	Castl[WHITE].PawnsL.SetBit(D2)
-	Castl[WHITE].PawnsL.SetBit(E2)
-	Castl[WHITE].PawnsL.SetBit(F2)
-
-	// pawns stop short castling B
-	Castl[BLACK].PawnsSh.SetBit(D7)
-	Castl[BLACK].PawnsSh.SetBit(E7)
-	Castl[BLACK].PawnsSh.SetBit(F7)
-	Castl[BLACK].PawnsSh.SetBit(G7)
-	Castl[BLACK].PawnsSh.SetBit(H7)
-	// pawns stop long castling B
-	Castl[BLACK].PawnsL.SetBit(B7)
-	Castl[BLACK].PawnsL.SetBit(C7)
-	Castl[BLACK].PawnsL.SetBit(D7)
-	Castl[BLACK].PawnsL.SetBit(E7)
-	Castl[BLACK].PawnsL.SetBit(F7)
-
-	// knights stop short castling W
-	Castl[WHITE].KnightsSh.SetBit(C2)
-	Castl[WHITE].KnightsSh.SetBit(D2)
-	Castl[WHITE].KnightsSh.SetBit(E2)
-	Castl[WHITE].KnightsSh.SetBit(G2)
-	Castl[WHITE].KnightsSh.SetBit(H2)
-	Castl[WHITE].KnightsSh.SetBit(D3)
-	Castl[WHITE].KnightsSh.SetBit(E3)
-	Castl[WHITE].KnightsSh.SetBit(F3)
-	Castl[WHITE].KnightsSh.SetBit(G3)
-	Castl[WHITE].KnightsSh.SetBit(H3)
-	// knights stop long castling W
-	Castl[WHITE].KnightsL.SetBit(A2)
-	Castl[WHITE].KnightsL.SetBit(B2)
-	Castl[WHITE].KnightsL.SetBit(C2)
-	Castl[WHITE].KnightsL.SetBit(E2)
-	Castl[WHITE].KnightsL.SetBit(F2)
-	Castl[WHITE].KnightsL.SetBit(G2)
-	Castl[WHITE].KnightsL.SetBit(B3)
-	Castl[WHITE].KnightsL.SetBit(C3)
-	Castl[WHITE].KnightsL.SetBit(D3)
-	Castl[WHITE].KnightsL.SetBit(E3)
-	Castl[WHITE].KnightsL.SetBit(F3)
-
-	// knights stop short castling B
-	Castl[BLACK].KnightsSh.SetBit(C7)
-	Castl[BLACK].KnightsSh.SetBit(D7)
-	Castl[BLACK].KnightsSh.SetBit(E7)
-	Castl[BLACK].KnightsSh.SetBit(G7)
-	Castl[BLACK].KnightsSh.SetBit(H7)
-	Castl[BLACK].KnightsSh.SetBit(D6)
-	Castl[BLACK].KnightsSh.SetBit(E6)
-	Castl[BLACK].KnightsSh.SetBit(F6)
-	Castl[BLACK].KnightsSh.SetBit(G6)
-	Castl[BLACK].KnightsSh.SetBit(H6)
-	// knights stop long castling B
-	Castl[BLACK].KnightsL.SetBit(A7)
-	Castl[BLACK].KnightsL.SetBit(B7)
-	Castl[BLACK].KnightsL.SetBit(C7)
-	Castl[BLACK].KnightsL.SetBit(E7)
-	Castl[BLACK].KnightsL.SetBit(F7)
-	Castl[BLACK].KnightsL.SetBit(G7)
-	Castl[BLACK].KnightsL.SetBit(B6)
-	Castl[BLACK].KnightsL.SetBit(C6)
-	Castl[BLACK].KnightsL.SetBit(D6)
-	Castl[BLACK].KnightsL.SetBit(E6)
-	Castl[BLACK].KnightsL.SetBit(F6)
+	Castl[WHITE] = NewOptions(WHITE, E1, H1, A1)
+	Castl[BLACK] = NewOptions(BLACK, E8, H8, A8)
 }
@@ -42,8 +42,6 @@ var (
 	PawnFile   = [8]int{0, 0, +1, +10, +10, +8, +10, +8}
 )
 
-const LongDiag = 10
-
 // Piece Square Table
 var PSqTab [12][64]int
 
@@ -124,21 +122,19 @@ const (
 	SW = -NE
 	SE = -NW
 
-	FrMask     = 0x0000003f                 // 0000 0000  0000 0000  0000 0000  0011 1111
-	ToMask     = 0x00000fd0                 // 0000 0000  0000 0000  0000 1111  1100 0000
-	PcMask     = 0x0000f000                 // 0000 0000  0000 0000  1111 0000  0000 0000
-	CpMask     = 0x000f0000                 // 0000 0000  0000 1111  0000 0000  0000 0000
-	PrMask     = 0x00f00000                 // 0000 0000  1111 0000  0000 0000  0000 0000
-	EpMask     = 0x0f000000                 // 0000 1111  0000 0000  0000 0000  0000 0000
-	CastlMask  = 0xf0000000                 // 1111 0000  0000 0000  0000 0000  0000 0000
-	EvalMask   = uint64(0xffff000000000000) // The 16 first bits in uint64
+	FrMask     = 0x0000003f // 0000 0000  0000 0000  0000 0000  0011 1111
+	ToMask     = 0x00000fd0 // 0000 0000  0000 0000  0000 1111  1100 0000
+	PcMask     = 0x0000f000 // 0000 0000  0000 0000  1111 0000  0000 0000
+	CpMask     = 0x000f0000 // 0000 0000  0000 1111  0000 0000  0000 0000
+	PrMask     = 0x00f00000 // 0000 0000  1111 0000  0000 0000  0000 0000
+	EpMask     = 0x0f000000 // 0000 1111  0000 0000  0000 0000  0000 0000
+	CastlMask  = 0xf0000000 // 1111 0000  0000 0000  0000 0000  0000 0000
 	ToShift    = 6
 	PcShift    = 12 // 6+6
 	CpShift    = 16 // 6+6+4
 	PrShift    = 20 // 6+6+4+4
 	EpShift    = 24 // 6+6+4+4+4
 	CastlShift = 28 // 6+6+4+4+4+4
-	EvalShift  = 64 - 16
 )
 
 // Each square is assignes a particular number
@@ -0,0 +1,100 @@
+package engine
+
+import (
+	"math/rand"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/Tecu23/go-game/pkg/chess/castlings"
+	. "github.com/Tecu23/go-game/pkg/chess/constants"
+	"github.com/Tecu23/go-game/pkg/chess/magic"
+	"github.com/Tecu23/go-game/pkg/chess/moves"
+	"github.com/Tecu23/go-game/pkg/chess/position"
+)
+
+func TestMain(m *testing.M) {
+	InitFen2Sq()
+	magic.InitMagic()
+	position.InitKeys()
+	position.InitAtksKings()
+	position.InitAtksKnights()
+	castlings.InitCastlings()
+	position.PcSqInit()
+	position.InitEndgames()
+	position.Trans.New(16)
+
+	os.Exit(m.Run())
+}
+
+// randomEnding sets up position.Board with the white pieces in pcs against a lone black king.
+// White is to move and the position is legal
+func randomEnding(rnd *rand.Rand, pcs []int) {
+	b := &position.Board
+	for {
+		b.Clear()
+		b.SetSq(WK, rnd.Intn(64))
+		for _, pc := range append(pcs, BK) {
+			sq := rnd.Intn(64)
+			for b.Squares[sq] != Empty {
+				sq = rnd.Intn(64)
+			}
+			b.SetSq(pc, sq)
+		}
+
+		if position.SqDistance(b.King[WHITE], b.King[BLACK]) > 1 && !b.IsAttacked(b.King[BLACK], WHITE) {
+			return
+		}
+	}
+}
+
+// playOut lets the engine play both sides from position.Board and returns true if white mates
+// within maxMoves moves
+func playOut(t *testing.T, toEng chan bool, frEng chan string, depth, maxMoves int) bool {
+	b := &position.Board
+	History.Clear()
+	position.Trans.Clear()
+
+	for ply := 0; ply < 2*maxMoves; ply++ {
+		var ml moves.MoveList
+		b.GenAllLegals(&ml)
+		if len(ml) == 0 {
+			return b.Stm == BLACK && b.IsAttacked(b.King[BLACK], WHITE)
+		}
+		if b.Count[WQ]+b.Count[WR]+b.Count[WB]+b.Count[WN] == 0 {
+			return false // white's material has been captured
+		}
+
+		Limits.Init()
+		Limits.SetDepth(depth)
+		toEng <- true
+		bm := strings.TrimPrefix(<-frEng, "bestmove ")
+		if err := position.ParseMvs(bm); err != nil {
+			t.Fatalf("engine played an illegal move %v: %v", bm, err)
+		}
+	}
+	return false
+}
+
+func testConvert(t *testing.T, name string, pcs []int, games, depth, maxMoves int) {
+	if testing.Short() {
+		t.Skip("skipping conversion games in short mode")
+	}
+
+	toEng, frEng := Engine()
+	rnd := rand.New(rand.NewSource(1))
+	for g := 0; g < games; g++ {
+		randomEnding(rnd, pcs)
+		if !playOut(t, toEng, frEng, depth, maxMoves) {
+			t.Errorf("%v game %v: white didn't mate within %v moves", name, g+1, maxMoves)
+		}
+	}
+}
+
+func TestConvertKQK(t *testing.T) {
+	testConvert(t, "KQK", []int{WQ}, 10, 7, 30)
+}
+
+func TestConvertKBNK(t *testing.T) {
+	testConvert(t, "KBNK", []int{WB, WN}, 6, 8, 60)
+}
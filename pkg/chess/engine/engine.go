@@ -2,21 +2,21 @@
 package engine
 
 import (
-	"fmt"
 	"math"
 	"strconv"
 	"time"
 
 	"github.com/Tecu23/go-game/pkg/chess/bitboard"
+	"github.com/Tecu23/go-game/pkg/chess/book"
 	. "github.com/Tecu23/go-game/pkg/chess/constants"
 	"github.com/Tecu23/go-game/pkg/chess/magic"
 	"github.com/Tecu23/go-game/pkg/chess/moves"
 	"github.com/Tecu23/go-game/pkg/chess/position"
+	"github.com/Tecu23/go-game/pkg/chess/syzygy"
 )
 
 var CntNodes uint64
 
-// TODO search limits: count nodes and test for limit.nodes
 // TODO search limits: limit.depth
 
 // TODO search limits: time per game w/wo increments
@@ -52,6 +52,10 @@ func (s *SearchLimits) SetDepth(d int) {
 	s.Depth = d
 }
 
+func (s *SearchLimits) SetNodes(n uint64) {
+	s.Nodes = n
+}
+
 func (s *SearchLimits) SetMoveTime(m int) {
 	s.MoveTime = m
 }
@@ -76,12 +80,20 @@ func root(toEngine chan bool, frEngine chan string) {
 	var pv PvList
 	var childPV PvList
 	var ml moves.MoveList
+	var rootMoves moves.ScoredList
 	childPV.New()
 	pv.New()
 	ml.New(60)
 	ebfTab.New()
 	b := &position.Board
 	for range toEngine {
+		if b.Variant == position.Standard && book.OwnBook { // the books are for chess only
+			if mv, ok := book.Probe(b); ok { // no need to search
+				frEngine <- "bestmove " + b.UCI(mv)
+				continue
+			}
+		}
+
 		Limits.StartTime, Limits.LastTime = time.Now(), time.Now()
 		CntNodes = 0
 		ebfTab.Clear()
@@ -90,19 +102,25 @@ func root(toEngine chan bool, frEngine chan string) {
 		pv.Clear()
 
 		position.Trans.InitSearch() // incr age coounters=0
+		rootStm = b.Stm
 
-		genAndSort(0, b, &ml)
+		b.GenAllLegals(&ml)
+		if syzygy.MaxPieces > 0 && b.Variant == position.Standard {
+			syzygy.RootProbe(b, &ml) // only keep the moves with the best dtz
+		}
+		scoreAndSort(0, b, ml, &rootMoves)
 		depth = 0
 
 		transDepth := 0
-		inCheck := b.IsAttacked(b.King[b.Stm], b.Stm.Opposite())
-		bm := ml[0]
+		inCheck := b.InCheck()
+		bm := rootMoves[0].Mv
 		bs := NoScore // bm keeps the best from prev iteration in case of immediate stop before first is done in this iteration
 		for depth = 1; depth <= Limits.Depth && !Limits.Stop; depth++ {
-			ml.Sort()
+			rootMoves.Sort()
 			bs = NoScore // bm keeps the best from prev iteration in case of immediate stop before first is done in this iterastion
 			alpha, beta = MinEval, MaxEval
-			for ix, mv := range ml { // root move loop
+			for ix := range rootMoves { // root move loop
+				mv := rootMoves[ix].Mv
 				childPV.Clear()
 
 				b.Move(mv)
@@ -134,30 +152,17 @@ func root(toEngine chan bool, frEngine chan string) {
 				if Limits.Stop {
 					break
 				}
-				ml[ix].PackEval(score)
+				rootMoves[ix].Score = score
 				if score > bs {
 					bs = score
 					pv.Catenate(mv, &childPV)
 
-					bm = ml[ix]
+					bm = mv
 					alpha = score
 					transDepth = depth
 					if depth >= 0 {
-						position.Trans.Store(b.FullKey(), mv, transDepth, 0, score, ScoreTypeLower)
+						position.Trans.Store(b.FullKey(), b.Compact(mv), transDepth, 0, score, ScoreTypeLower)
 					}
-
-					t1 := time.Since(Limits.StartTime)
-
-					// Tell(
-					fmt.Sprintf(
-						"info score cp %v depth %v nodes %v time %v pv ",
-						bm.Eval(),
-						depth,
-						CntNodes,
-						int(t1.Seconds()*1000),
-					)
-					// 	pv.String(),
-					// )
 				}
 			}
 			if !Limits.Stop {
@@ -165,36 +170,20 @@ func root(toEngine chan bool, frEngine chan string) {
 			}
 
 		} // end ID
-		ml.Sort()
+		rootMoves.Sort()
 
 		position.Trans.Store(
 			b.FullKey(),
-			bm,
+			b.Compact(bm),
 			transDepth,
 			0,
 			bs,
 			position.ScoreType(bs, alpha, beta),
 		)
 
-		// time, nps, ebf
-		t1 := time.Since(Limits.StartTime)
-		Nps := float64(0)
-		if t1.Seconds() != 0 {
-			Nps = float64(CntNodes) / t1.Seconds()
-		}
+		// ebf
 		ebfTab.Ebf(transDepth)
-		// Tell(
-		fmt.Sprintf(
-			"info score cp %v depth %v nodes %v  time %v nps %v pv %v",
-			bm.Eval(),
-			transDepth,
-			CntNodes,
-			int(t1.Seconds()*1000),
-			uint(Nps),
-			pv.String(),
-		)
-		// )
-		frEngine <- fmt.Sprintf("bestmove %v%v", Sq2Fen[bm.Fr()], Sq2Fen[bm.To()])
+		frEngine <- "bestmove " + b.UCI(bm)
 	}
 }
 
@@ -206,6 +195,15 @@ func root(toEngine chan bool, frEngine chan string) {
 // TODO search: other reductions and extensions
 func Search(alpha, beta, depth, ply int, pv *PvList, b *position.BoardStruct) int {
 	CntNodes++
+	if CntNodes >= Limits.Nodes {
+		Limits.Stop = true
+	}
+	if b.IsRepetition() {
+		return drawScore(b.Stm)
+	}
+	if res, over := b.Variant.Result(b); over {
+		return resultScore(res, ply, b.Stm)
+	}
 	if depth <= 0 {
 		// return signEval(b.stm, evaluate(b))
 		return Qs(beta, b)
@@ -227,7 +225,7 @@ func Search(alpha, beta, depth, ply int, pv *PvList, b *position.BoardStruct) in
 	transMove := moves.NoMove
 	useTT := depth >= 0
 	transDepth := depth
-	inCheck := b.IsAttacked(b.King[b.Stm], b.Stm.Opposite())
+	inCheck := b.InCheck()
 
 	if depth < 0 && inCheck {
 		useTT = true
@@ -238,8 +236,10 @@ func Search(alpha, beta, depth, ply int, pv *PvList, b *position.BoardStruct) in
 		var transSc, scType int
 		ok := false
 
-		if transMove, transSc, scType, ok = position.Trans.Retrieve(b.FullKey(), transDepth, ply); ok &&
-			!pvNode {
+		var tm16 moves.Move16
+		tm16, transSc, scType, ok = position.Trans.Retrieve(b.FullKey(), transDepth, ply)
+		transMove = b.Expand(tm16)
+		if ok && !pvNode {
 			switch {
 			case scType == ScoreTypeLower && transSc >= beta:
 				position.Trans.CPrune++
@@ -254,6 +254,16 @@ func Search(alpha, beta, depth, ply int, pv *PvList, b *position.BoardStruct) in
 		}
 	}
 
+	// tablebases. Only right after a capture or a pawn move: the WDL tables assume a fresh
+	// 50 move counter, a win with fewer plies left than the distance to zeroing is a draw
+	if syzygy.MaxPieces > 0 && depth > 0 && b.Rule50 == 0 && b.Variant == position.Standard {
+		if wdl, ok := syzygy.ProbeWDL(b); ok {
+			sc := tbScore(wdl, ply)
+			position.Trans.Store(b.FullKey(), moves.NoMove16, transDepth, ply, sc, ScoreTypeBetween)
+			return sc
+		}
+	}
+
 	var childPV PvList
 	childPV.New() // TODO? make it smaller for each depth maxDepth-ply
 	/////////////////////////////////////// NULL MOVE /////////////////////////////////////////
@@ -261,7 +271,7 @@ func Search(alpha, beta, depth, ply int, pv *PvList, b *position.BoardStruct) in
 	// null-move pruning
 	if !pvNode && depth > 0 && !position.IsMateScore(beta) && !inCheck && !b.IsAntiNullMove() &&
 		ev >= beta {
-		nullMv := b.MoveNull()
+		b.MoveNull()
 		sc := MinEval
 		if depth <= 3 { // static
 			// if you don't beat me with 100 points,
@@ -271,11 +281,11 @@ func Search(alpha, beta, depth, ply int, pv *PvList, b *position.BoardStruct) in
 			sc = -Search(-beta, -beta+1, depth-3-1, ply, &childPV, b)
 		}
 
-		b.UndoNull(nullMv)
+		b.UndoNull()
 
 		if sc >= beta {
 			if useTT {
-				position.Trans.Store(b.FullKey(), moves.NoMove, transDepth, ply, sc, ScoreTypeLower)
+				position.Trans.Store(b.FullKey(), moves.NoMove16, transDepth, ply, sc, ScoreTypeLower)
 			}
 			return sc
 		}
@@ -287,7 +297,7 @@ func Search(alpha, beta, depth, ply int, pv *PvList, b *position.BoardStruct) in
 
 	genInfo := GenInfoStruct{Sv: 0, Ply: ply, TransMove: transMove}
 	cntMoves := 0
-	Next = NextNormal
+	Next = nextFor(b)
 	for mv, msg := Next(&genInfo, b); mv != moves.NoMove; mv, msg = Next(&genInfo, b) {
 		_ = msg
 
@@ -322,7 +332,7 @@ func Search(alpha, beta, depth, ply int, pv *PvList, b *position.BoardStruct) in
 				if useTT {
 					position.Trans.Store(
 						b.FullKey(),
-						mv,
+						b.Compact(mv),
 						transDepth,
 						ply,
 						score,
@@ -332,9 +342,9 @@ func Search(alpha, beta, depth, ply int, pv *PvList, b *position.BoardStruct) in
 			}
 
 			if score >= beta { // beta cutoff
-				// add killer and update history
-				if mv.Cp() == Empty && mv.Pr() == Empty {
-					Killers.Add(mv, ply)
+				// add killer and update history, not for drops that have no from square
+				if mv.Cp() == Empty && mv.Pr() == Empty && !mv.IsDrop() {
+					Killers.Add(b.Compact(mv), ply)
 					History.Inc(mv.Fr(), mv.To(), b.Stm, depth)
 				}
 				if mv.Cmp(transMove) {
@@ -349,20 +359,7 @@ func Search(alpha, beta, depth, ply int, pv *PvList, b *position.BoardStruct) in
 			Limits.LastTime = time.Now().Add(-time.Duration(tStep))
 			t1 := time.Since(Limits.StartTime)
 			ms := uint64(t1.Nanoseconds() / 1000000)
-			if t1.Seconds() > 1 {
-				if ms%1000 <= 5 {
-					// Tell(
-					fmt.Sprintf(
-						"info time %v nodes %v nps %v",
-						ms,
-						CntNodes,
-						CntNodes/uint64(t1.Seconds()),
-					)
-					// )
-				}
-			}
-
-			if ms >= uint64(Limits.MoveTime)-200 {
+			if int(ms)+MoveOverhead >= Limits.MoveTime {
 				//				fmt.Println("t1", uint64(t1.Nanoseconds()/1000000)-100, "limit", uint64(limits.moveTime))
 				Limits.Stop = true
 			}
@@ -373,14 +370,11 @@ func Search(alpha, beta, depth, ply int, pv *PvList, b *position.BoardStruct) in
 		}
 	}
 
-	if cntMoves == 0 { // we didn't find any legal moves - either mate or stalemate
-		sc := 0      // we could have a contempt value here instead
-		if inCheck { // must be a mate
-			sc = -MateEval + ply + 1
-		}
+	if cntMoves == 0 { // we didn't find any legal moves - in chess either mate or stalemate
+		sc := resultScore(b.Variant.NoMoves(b), ply, b.Stm)
 
 		if useTT {
-			position.Trans.Store(b.FullKey(), moves.NoMove, transDepth, ply, sc, ScoreTypeBetween)
+			position.Trans.Store(b.FullKey(), moves.NoMove16, transDepth, ply, sc, ScoreTypeBetween)
 		}
 		return sc
 	}
@@ -394,7 +388,7 @@ func Search(alpha, beta, depth, ply int, pv *PvList, b *position.BoardStruct) in
 // compute late move reduction
 func lmr(mv moves.Move, inCheck bool, depth, sv, CntMoves int, b *position.BoardStruct) int {
 	interesting := inCheck || mv.Cp() != Empty || mv.Pr() != Empty ||
-		b.IsAttacked(b.King[b.Stm], b.Stm.Opposite()) ||
+		b.InCheck() ||
 		(b.Stm == WHITE && mv.Pc() == WP && mv.To() >= A6) ||
 		(b.Stm == BLACK && mv.Pc() == BP && mv.To() <= H3) // even big threats? castling?
 	red := 0
@@ -488,8 +482,8 @@ func See(fr, to int, b *position.BoardStruct) int {
 	// All the attackers to the to-sq, but first remove the moving piece and use X-ray to the to-sq
 	occ := b.AllBB()
 	occ.Clear(fr)
-	attackingBB := magic.MRookTab[to].Atks(occ)&(b.PieceBB[Rook]|b.PieceBB[Queen]) |
-		magic.MBishopTab[to].Atks(occ)&(b.PieceBB[Bishop]|b.PieceBB[Queen]) |
+	attackingBB := magic.RookAtks(to, occ)&(b.PieceBB[Rook]|b.PieceBB[Queen]) |
+		magic.BishopAtks(to, occ)&(b.PieceBB[Bishop]|b.PieceBB[Queen]) |
 		(position.AtksKnights[to] & b.PieceBB[Knight]) |
 		(position.AtksKings[to] & b.PieceBB[King]) |
 		(b.WPawnAtksFr(to) & b.PieceBB[Pawn] & b.WbBB[BLACK]) |
@@ -536,8 +530,8 @@ func See(fr, to int, b *position.BoardStruct) int {
 		occ ^= (BB & -BB) // turn off the rightmost bit from BB in occ
 
 		//  pick sliding attacks again (do it from to-sq)
-		attackingBB |= magic.MRookTab[to].Atks(occ)&(b.PieceBB[Rook]|b.PieceBB[Queen]) |
-			magic.MBishopTab[to].Atks(occ)&(b.PieceBB[Bishop]|b.PieceBB[Queen])
+		attackingBB |= magic.RookAtks(to, occ)&(b.PieceBB[Rook]|b.PieceBB[Queen]) |
+			magic.BishopAtks(to, occ)&(b.PieceBB[Bishop]|b.PieceBB[Queen])
 		attackingBB &= occ // but only attacking pieces
 
 		captureList[n] = -captureList[n-1] + lastAtkVal
@@ -584,30 +578,31 @@ func See(fr, to int, b *position.BoardStruct) int {
 		ml.sort()
 	}
 */
-func genAndSort(ply int, b *position.BoardStruct, ml *moves.MoveList) {
+
+// scoreAndSort puts the moves of ml in sl scored by the evaluation after the move and the
+// killers, the best first
+func scoreAndSort(ply int, b *position.BoardStruct, ml moves.MoveList, sl *moves.ScoredList) {
 	if ply > MaxPly {
 		panic("wtf maxply")
 	}
 
-	ml.Clear()
-	b.GenAllLegals(ml)
-
-	for ix, mv := range *ml {
+	*sl = (*sl)[:0]
+	for _, mv := range ml {
 		b.Move(mv)
 		v := position.Evaluate(b)
 		b.Unmove(mv)
-		if Killers[ply].K1.Cmp(mv) {
+		if Killers[ply].K1.Is(mv) {
 			v += 1000
-		} else if Killers[ply].K2.Cmp(mv) {
+		} else if Killers[ply].K2.Is(mv) {
 			v += 900
 		}
 
 		v = SignEval(b.Stm, v)
 
-		(*ml)[ix].PackEval(v)
+		sl.Add(mv, v)
 	}
 
-	ml.Sort()
+	sl.Sort()
 }
 
 // generate capture moves first, then killers, then non captures
@@ -638,30 +633,10 @@ func genInOrder(b *position.BoardStruct, ml *moves.MoveList, ply int, transMove
 		for ix := noCaptIx; ix < len(*ml); ix++ {
 			mv := (*ml)[ix]
 			switch {
-			case Killers[ply].K1.CmpFrTo(mv) && !mv.CmpFrTo(transMove) && b.Squares[mv.To()] == Empty:
-				mv.PackMove(
-					mv.Fr(),
-					mv.To(),
-					b.Squares[mv.Fr()],
-					b.Squares[mv.To()],
-					mv.Pr(),
-					b.Ep,
-					b.Castlings,
-				)
-				(*ml)[ix] = mv
+			case Killers[ply].K1.Is(mv) && !mv.CmpFrTo(transMove) && b.Squares[mv.To()] == Empty:
 				(*ml)[ix], (*ml)[pos1] = (*ml)[pos1], (*ml)[ix]
 				cnt++
-			case Killers[ply].K2.CmpFrTo(mv) && !mv.CmpFrTo(transMove) && b.Squares[mv.To()] == Empty:
-				mv.PackMove(
-					mv.Fr(),
-					mv.To(),
-					b.Squares[mv.Fr()],
-					b.Squares[mv.To()],
-					mv.Pr(),
-					b.Ep,
-					b.Castlings,
-				)
-				(*ml)[ix] = mv
+			case Killers[ply].K2.Is(mv) && !mv.CmpFrTo(transMove) && b.Squares[mv.To()] == Empty:
 				(*ml)[ix], (*ml)[pos2] = (*ml)[pos2], (*ml)[ix]
 				cnt++
 			}
@@ -672,6 +647,34 @@ func genInOrder(b *position.BoardStruct, ml *moves.MoveList, ply int, transMove
 	}
 }
 
+// TbWin is the score for a tablebase win. It is below the mate scores so that a mate is preferred
+const TbWin = MaxEval - 2*MaxPly
+
+// tbScore converts a tablebase result to a score from stm pov. Cursed wins and blessed
+// losses are draws with the 50 move rule
+func tbScore(wdl syzygy.WDL, ply int) int {
+	switch wdl {
+	case syzygy.Win:
+		return TbWin - ply
+	case syzygy.Loss:
+		return -TbWin + ply
+	}
+	return 0
+}
+
+// resultScore converts the result of a game that ended ply plies from the root to a score
+// from the pov of stm, the side to move. A loss is scored like being mated and a draw by
+// the Contempt
+func resultScore(res, ply int, stm Color) int {
+	switch res {
+	case position.Win:
+		return MateEval - ply - 1
+	case position.Loss:
+		return -MateEval + ply + 1
+	}
+	return drawScore(stm)
+}
+
 func SignEval(stm Color, ev int) int {
 	if stm == BLACK {
 		return -ev
@@ -702,8 +705,41 @@ type GenInfoStruct struct {
 	TransMove moves.Move
 
 	// handle by the next-function
-	Captures, NonCapt moves.MoveList
-	CounterMv         moves.Move
+	Captures  moves.ScoredList // scored by see
+	NonCapt   moves.MoveList
+	CounterMv moves.Move
+}
+
+// nextFor returns the move generator for b. The stages of NextNormal can't tell the moves a
+// variant filters out, those variants get NextLegal
+func nextFor(b *position.BoardStruct) func(*GenInfoStruct, *position.BoardStruct) (moves.Move, string) {
+	if _, ok := b.Variant.(position.MoveFilter); ok {
+		return NextLegal
+	}
+	return NextNormal
+}
+
+// NextLegal returns the legal moves of b from GenAllLegals, the trans move first
+func NextLegal(genInfo *GenInfoStruct, b *position.BoardStruct) (moves.Move, string) {
+	ml := &genInfo.NonCapt
+	if genInfo.Sv == InitNext {
+		genInfo.Sv = NextEnd
+		ml.New(60)
+		b.GenAllLegals(ml)
+		for ix, mv := range *ml {
+			if mv.Cmp(genInfo.TransMove) {
+				(*ml)[ix], (*ml)[0] = (*ml)[0], (*ml)[ix]
+				break
+			}
+		}
+	}
+
+	if len(*ml) == 0 {
+		return moves.NoMove, "end"
+	}
+	mv := (*ml)[0]
+	*ml = (*ml)[1:]
+	return mv, "legal"
 }
 
 func NextNormal(genInfo *GenInfoStruct, b *position.BoardStruct) (moves.Move, string) {
@@ -721,94 +757,43 @@ func NextNormal(genInfo *GenInfoStruct, b *position.BoardStruct) (moves.Move, st
 		}
 		fallthrough
 	case NextFirstGoodCp:
-		genInfo.Captures.New(20)
-		b.GenAllCaptures(&genInfo.Captures)
-		// pick a good capt - use see - not transMove
-		bs := -1
-		bIx := 0
-		ml := &genInfo.Captures
-		for ix := 0; ix < len(*ml); ix++ {
-			if (*ml)[ix].Cmp(genInfo.TransMove) {
-				continue
-			}
-			sc := See((*ml)[ix].Fr(), (*ml)[ix].To(), b)
-			(*ml)[ix].PackEval(sc)
-			if sc > bs {
-				bs = sc
-				bIx = ix
+		var ml moves.MoveList
+		ml.New(20)
+		b.GenAllCaptures(&ml)
+		// score the captures with see - not transMove
+		genInfo.Captures = make(moves.ScoredList, 0, len(ml))
+		for _, mv := range ml {
+			if !mv.Cmp(genInfo.TransMove) {
+				genInfo.Captures.Add(mv, See(mv.Fr(), mv.To(), b))
 			}
 		}
-		if bs >= 0 {
-			mv := (*ml)[bIx]
-			(*ml)[bIx], (*ml)[len(*ml)-1] = (*ml)[len(*ml)-1], (*ml)[bIx]
-			*ml = (*ml)[:len(*ml)-1]
-			genInfo.Sv = NextGoodCp
-			return mv, "first good capt"
-		}
-
-		genInfo.Sv = NextK1
+		genInfo.Sv = NextGoodCp
 		fallthrough
 	case NextGoodCp:
-		// pick a good capt - use see - not transMove
-		bs := -1
-		bIx := 0
-		ml := &genInfo.Captures
-		for ix := 0; ix < len(*ml); ix++ {
-			if (*ml)[ix].Cmp(genInfo.TransMove) {
-				continue
-			}
-			sc := (*ml)[ix].Eval()
-			if sc > bs {
-				bs = sc
-				bIx = ix
-			}
-		}
-		if bs >= 0 {
-			mv := (*ml)[bIx]
-			(*ml)[bIx], (*ml)[len(*ml)-1] = (*ml)[len(*ml)-1], (*ml)[bIx]
-			*ml = (*ml)[:len(*ml)-1]
-			bs, bIx = MinEval, -1
+		// pick the best good capt
+		sl := &genInfo.Captures
+		if bIx := sl.Best(); bIx >= 0 && (*sl)[bIx].Score >= 0 {
+			mv := (*sl)[bIx].Mv
+			sl.Remove(bIx)
 			return mv, "good capt"
 		}
 		genInfo.Sv = NextK1
 		fallthrough
 	case NextK1: // not transMove
 		genInfo.Sv = NextK2
-		if Killers[genInfo.Ply].K1 != moves.NoMove &&
-			!genInfo.TransMove.CmpFrToP(Killers[genInfo.Ply].K1) {
-			if b.IsLegal(Killers[genInfo.Ply].K1) {
-				var mv moves.Move
-				mv.PackMove(
-					Killers[genInfo.Ply].K1.Fr(),
-					Killers[genInfo.Ply].K1.To(),
-					b.Squares[Killers[genInfo.Ply].K1.Fr()],
-					b.Squares[Killers[genInfo.Ply].K1.To()],
-					Killers[genInfo.Ply].K1.Pr(),
-					b.Ep,
-					b.Castlings,
-				)
-				return mv, "K1"
-			}
+		// the killer is expanded on b, it is only tried as a non capture
+		if mv := b.Expand(Killers[genInfo.Ply].K1); mv != moves.NoMove && mv.Cp() == Empty &&
+			!genInfo.TransMove.CmpFrToP(mv) && b.IsLegal(mv) {
+			return mv, "K1"
 		}
 
 		fallthrough
 	case NextK2: // not transMove
 		genInfo.Sv = NextCounterMv
-		if Killers[genInfo.Ply].K2 != moves.NoMove &&
-			!genInfo.TransMove.CmpFrToP(Killers[genInfo.Ply].K2) {
-			if b.IsLegal(Killers[genInfo.Ply].K2) {
-				var mv moves.Move
-				mv.PackMove(
-					Killers[genInfo.Ply].K2.Fr(),
-					Killers[genInfo.Ply].K2.To(),
-					b.Squares[Killers[genInfo.Ply].K2.Fr()],
-					b.Squares[Killers[genInfo.Ply].K2.To()],
-					Killers[genInfo.Ply].K2.Pr(),
-					b.Ep,
-					b.Castlings,
-				)
-				return mv, "K2"
-			}
+		// the killer is expanded on b, it is only tried as a non capture
+		if mv := b.Expand(Killers[genInfo.Ply].K2); mv != moves.NoMove && mv.Cp() == Empty &&
+			!genInfo.TransMove.CmpFrToP(mv) && b.IsLegal(mv) {
+			return mv, "K2"
 		}
 
 		fallthrough
@@ -832,9 +817,7 @@ func NextNormal(genInfo *GenInfoStruct, b *position.BoardStruct) (moves.Move, st
 		bIx := -1
 		for ix := 0; ix < len(*ml); ix++ {
 			if (*ml)[ix].CmpFrToP(genInfo.TransMove) || (*ml)[ix].CmpFrToP(genInfo.CounterMv) ||
-				(*ml)[ix].CmpFrToP(
-					Killers[genInfo.Ply].K1,
-				) || (*ml)[ix].CmpFrToP(Killers[genInfo.Ply].K2) {
+				Killers[genInfo.Ply].K1.Is((*ml)[ix]) || Killers[genInfo.Ply].K2.Is((*ml)[ix]) {
 				continue
 			}
 			sc := int(History.Get((*ml)[ix].Fr(), (*ml)[ix].To(), b.Stm))
@@ -863,9 +846,7 @@ func NextNormal(genInfo *GenInfoStruct, b *position.BoardStruct) (moves.Move, st
 		ml := &genInfo.NonCapt
 		for ix := 0; ix < len(*ml); ix++ {
 			if (*ml)[ix].CmpFrToP(genInfo.TransMove) || (*ml)[ix].CmpFrToP(genInfo.CounterMv) ||
-				(*ml)[ix].CmpFrToP(
-					Killers[genInfo.Ply].K1,
-				) || (*ml)[ix].CmpFrToP(Killers[genInfo.Ply].K2) {
+				Killers[genInfo.Ply].K1.Is((*ml)[ix]) || Killers[genInfo.Ply].K2.Is((*ml)[ix]) {
 				continue
 			}
 			sc := int(History.Get((*ml)[ix].Fr(), (*ml)[ix].To(), b.Stm))
@@ -886,109 +867,14 @@ func NextNormal(genInfo *GenInfoStruct, b *position.BoardStruct) (moves.Move, st
 		fallthrough
 	case NextBadCp: // not transMove
 		// pick a bad capt  - use see?
-		mv := moves.NoMove
-		ml := &genInfo.Captures
-		for ix := len(*ml) - 1; ix >= 0; ix-- {
-			if (*ml)[ix].Cmp(genInfo.TransMove) {
-				*ml = (*ml)[:len(*ml)-1]
-				continue
-			}
-
-			mv = (*ml)[ix]
-			//		(*ml)[ix], (*ml)[len(*ml)-1] = (*ml)[len(*ml)-1], (*ml)[ix]
-			*ml = (*ml)[:len(*ml)-1]
-			break
+		sl := &genInfo.Captures
+		if len(*sl) == 0 {
+			return moves.NoMove, "bad capt"
 		}
-
+		mv := (*sl)[len(*sl)-1].Mv
+		sl.Remove(len(*sl) - 1)
 		return mv, "bad capt"
 	default: // shouldn't happen
 		panic("never come here! nextNormal sv=" + strconv.Itoa(genInfo.Sv))
 	}
 }
-
-// StartPerft starts the Perft command that generates all moves until the given depth.
-// It counts the leafs only taht is printed out for each possible move from current pos
-func StartPerft(depth int, bd *position.BoardStruct) uint64 {
-	if depth <= 0 {
-		fmt.Printf("Total:\t%v\n", 1)
-		return 0
-	}
-
-	transMove := moves.NoMove
-	transMove, _, _, _ = position.Trans.Retrieve(bd.FullKey(), depth, 0)
-
-	totCount := uint64(0)
-	genInfo := GenInfoStruct{Sv: 0, Ply: 0, TransMove: transMove}
-	Next = NextNormal
-	ix := 0
-	for mv, msg := Next(&genInfo, bd); mv != moves.NoMove; mv, msg = Next(&genInfo, bd) {
-		if !bd.Move(mv) {
-			continue
-		}
-		dbg := false
-		/*
-			/////////////////////////////////////////////////////////////
-			if mv.fr() == D4 && mv.to() == F4 {
-				dbg = true
-			}
-			/////////////////////////////////////////////////////////////
-		*/
-		count := perft(dbg, depth-1, 1, bd)
-		totCount += count
-		fmt.Printf("%2d: %v \t%v \t%v\n", ix+1, mv.String(), count, msg)
-
-		bd.Unmove(mv)
-		ix++
-	}
-	fmt.Println("------------------")
-	fmt.Println()
-	fmt.Printf("Total:\t%v\n", totCount)
-	return totCount
-}
-
-func perft(dbg bool, depth, ply int, bd *position.BoardStruct) uint64 {
-	if depth == 0 {
-		return 1
-	}
-
-	transMove := moves.NoMove
-	transMove, _, _, _ = position.Trans.Retrieve(bd.FullKey(), depth, ply)
-	ix := 0
-	count := uint64(0)
-	genInfo := GenInfoStruct{Sv: 0, Ply: ply, TransMove: transMove}
-	Next = NextNormal
-	for mv, msg := Next(&genInfo, bd); mv != moves.NoMove; mv, msg = Next(&genInfo, bd) {
-		if !bd.Move(mv) {
-			continue
-		}
-		_ = msg
-		deb := false
-		/*
-			////////////////////////////////////////////////////////////////
-			if dbg && mv.fr() == F5 && mv.to() == F4 {
-				deb = true
-			}
-			if dbg && mv.fr() == E2 && mv.to() == E4 {
-				deb = true
-			}
-			////////////////////////////////////////////////////////////////
-		*/
-		cnt := perft(deb, depth-1, ply+1, bd)
-		count += cnt
-		/*
-			/////////////////////////////////////////////
-			if dbg && !deb  {
-				fmt.Println(ix+1, ":(e4)     ", mv.String(), msg, "\t", cnt)
-				if ix==1{
-					fmt.Println("K1",killers[ply].k1.StringFull())
-					fmt.Println("K2",killers[ply].k2.StringFull())
-				}
-			}
-			////////////////////////////////////////////
-		*/
-		bd.Unmove(mv)
-		ix++
-	}
-
-	return count
-}
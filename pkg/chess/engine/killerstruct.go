@@ -6,23 +6,24 @@ import (
 )
 
 // ///////////////  Killers ///////////////////////////////////////////////
-// killerStruct holds the killer moves per ply
+// killerStruct holds the killer moves per ply, in 16 bits. They are expanded on the board
+// of the ply, see BoardStruct.Expand
 type KillerStruct [MaxPly]struct {
-	K1 moves.Move
-	K2 moves.Move
+	K1 moves.Move16
+	K2 moves.Move16
 }
 
 // Clear killer moves
 func (k *KillerStruct) Clear() {
 	for ply := 0; ply < MaxPly; ply++ {
-		k[ply].K1 = moves.NoMove
-		k[ply].K2 = moves.NoMove
+		k[ply].K1 = moves.NoMove16
+		k[ply].K2 = moves.NoMove16
 	}
 }
 
 // add killer 1 and 2 (Not inCheck, caaptures and promotions)
-func (k *KillerStruct) Add(mv moves.Move, ply int) {
-	if !k[ply].K1.CmpFrTo(mv) {
+func (k *KillerStruct) Add(mv moves.Move16, ply int) {
+	if k[ply].K1 != mv {
 		k[ply].K2 = k[ply].K1
 		k[ply].K1 = mv
 	}
@@ -0,0 +1,37 @@
+package engine
+
+import . "github.com/Tecu23/go-game/pkg/chess/constants"
+
+// the engine settings the uci setoption command sets, see the option registry in websocket
+
+// Threads is the number of search threads. The search runs on one thread for now, so the
+// option only takes 1
+var Threads = 1
+
+// MultiPV is the number of best lines to report. Only the best line is searched for now,
+// so the option only takes 1
+var MultiPV = 1
+
+// Ponder tells that the GUI may send go ponder. The search doesn't ponder yet, the flag
+// is only kept for the GUIs that set it
+var Ponder = false
+
+// MoveOverhead is the time in milliseconds kept back from the movetime for the GUI and
+// the connection
+var MoveOverhead = 200
+
+// Contempt is what a draw costs the engine in centipawns. With a positive contempt the
+// engine avoids draws, with a negative one it looks for them
+var Contempt = 0
+
+// rootStm is the side the engine plays, the side to move at the root of the search
+var rootStm = WHITE
+
+// drawScore is the score of a draw from stm pov. The side is compared with rootStm rather
+// than told by the ply, a null move passes the move without a ply
+func drawScore(stm Color) int {
+	if stm == rootStm {
+		return -Contempt
+	}
+	return Contempt
+}
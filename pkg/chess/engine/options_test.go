@@ -0,0 +1,61 @@
+package engine
+
+import (
+	"testing"
+
+	. "github.com/Tecu23/go-game/pkg/chess/constants"
+	"github.com/Tecu23/go-game/pkg/chess/position"
+)
+
+func TestContempt(t *testing.T) {
+	defer func(c int, stm Color) { Contempt, rootStm = c, stm }(Contempt, rootStm)
+
+	Contempt = 0
+	if got := resultScore(position.Draw, 3, BLACK); got != 0 {
+		t.Errorf("a draw without contempt scores %v, want 0", got)
+	}
+
+	Contempt = 25
+	for _, tt := range []struct {
+		root, stm Color
+		want      int
+	}{{WHITE, WHITE, -25}, {WHITE, BLACK, 25}, {BLACK, BLACK, -25}, {BLACK, WHITE, 25}} {
+		rootStm = tt.root
+		if got := resultScore(position.Draw, 7, tt.stm); got != tt.want {
+			t.Errorf("a draw with %v to move scores %v for %v, want %v", tt.stm, got, tt.root, tt.want)
+		}
+	}
+	if got := resultScore(position.Win, 1, BLACK); got != MateEval-2 {
+		t.Errorf("a win at ply 1 scores %v with contempt, want %v", got, MateEval-2)
+	}
+}
+
+// TestContemptNullMove checks the draw score of a repetition after a null move, where the
+// ply no longer tells the side to move
+func TestContemptNullMove(t *testing.T) {
+	defer func(c int, stm Color) { Contempt, rootStm = c, stm }(Contempt, rootStm)
+	Contempt, rootStm = 25, WHITE
+
+	b, err := position.ParseFEN(Startpos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.MoveNull()
+	for _, s := range []string{"b8c6", "g1f3", "c6b8", "f3g1"} {
+		mv, err := b.ParseUCI(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b.Move(mv)
+	}
+	if !b.IsRepetition() {
+		t.Fatal("the position after the null move is not repeated")
+	}
+
+	// the null move search passes the ply on, so black is to move at an even ply
+	var pv PvList
+	pv.New()
+	if got := Search(MinEval, MaxEval, 1, 4, &pv, b); got != 25 {
+		t.Errorf("the repetition scores %v for black, want 25", got)
+	}
+}
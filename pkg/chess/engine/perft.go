@@ -0,0 +1,157 @@
+package engine
+
+import (
+	"sync"
+
+	"github.com/Tecu23/go-game/pkg/chess/moves"
+	"github.com/Tecu23/go-game/pkg/chess/position"
+)
+
+// Divide returns the number of leaf nodes depth plies below the current position for
+// each legal move, the moves in long algebraic notation. The moves come from NextNormal,
+// so perft tests the move ordering generator too. A game that is over by the rules of the
+// variant has no moves
+func Divide(depth int, bd *position.BoardStruct) map[string]uint64 {
+	div := make(map[string]uint64)
+	if _, over := bd.Variant.Result(bd); depth <= 0 || over {
+		return div
+	}
+
+	transMove, _, _, _ := position.Trans.Retrieve(bd.FullKey(), depth, 0)
+
+	genInfo := GenInfoStruct{Sv: 0, Ply: 0, TransMove: bd.Expand(transMove)}
+	Next = nextFor(bd)
+	for mv, _ := Next(&genInfo, bd); mv != moves.NoMove; mv, _ = Next(&genInfo, bd) {
+		if !bd.Move(mv) {
+			continue
+		}
+		div[bd.UCI(mv)] = perft(depth-1, 1, bd)
+		bd.Unmove(mv)
+	}
+	return div
+}
+
+// Perft returns the number of leaf nodes depth plies below the current position
+func Perft(depth int, bd *position.BoardStruct) uint64 {
+	if depth <= 0 {
+		return 1
+	}
+
+	total := uint64(0)
+	for _, cnt := range Divide(depth, bd) {
+		total += cnt
+	}
+	return total
+}
+
+// DivideParallel is Divide for benchmarking the move generator. The root moves are split
+// over threads goroutines, each on its own copy of bd, and the counts of the subtrees are
+// cached in tt, which may be nil. A game that is over has no moves, as in Divide
+func DivideParallel(depth, threads int, tt *PerftTT, bd *position.BoardStruct) map[string]uint64 {
+	div := make(map[string]uint64)
+	if _, over := bd.Variant.Result(bd); depth <= 0 || over {
+		return div
+	}
+	if threads < 1 {
+		threads = 1
+	}
+
+	var ml moves.MoveList
+	bd.GenAllLegals(&ml)
+	jobs := make(chan moves.Move, len(ml))
+	for _, mv := range ml {
+		jobs <- mv
+	}
+	close(jobs)
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < threads; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b := bd.Clone()
+			lists := make([]moves.MoveList, depth)
+			for ix := range lists {
+				lists[ix] = make(moves.MoveList, 0, 256)
+			}
+
+			for mv := range jobs {
+				b.Move(mv)
+				cnt := hashedPerft(depth-1, b, tt, lists)
+				b.Unmove(mv)
+
+				mu.Lock()
+				div[b.UCI(mv)] = cnt
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return div
+}
+
+// hashedPerft counts the leaf nodes depth plies below b. lists[depth-1] is the move list for depth
+func hashedPerft(depth int, b *position.BoardStruct, tt *PerftTT, lists []moves.MoveList) uint64 {
+	if depth == 0 {
+		return 1
+	}
+
+	if _, over := b.Variant.Result(b); over {
+		return 0
+	}
+
+	key := b.FullKey()
+	if tt != nil && depth > 1 {
+		if cnt, ok := tt.probe(key, depth); ok {
+			return cnt
+		}
+	}
+
+	ml := lists[depth-1][:0]
+	if _, ok := b.Variant.(position.MoveFilter); ok {
+		b.GenAllLegals(&ml)
+	} else {
+		b.GenAllMoves(&ml)
+	}
+	cnt := uint64(0)
+	for _, mv := range ml {
+		if !b.Move(mv) {
+			continue
+		}
+		if depth == 1 {
+			cnt++
+		} else {
+			cnt += hashedPerft(depth-1, b, tt, lists)
+		}
+		b.Unmove(mv)
+	}
+
+	if tt != nil && depth > 1 {
+		tt.store(key, depth, cnt)
+	}
+	return cnt
+}
+
+func perft(depth, ply int, bd *position.BoardStruct) uint64 {
+	if depth == 0 {
+		return 1
+	}
+	if _, over := bd.Variant.Result(bd); over {
+		return 0
+	}
+
+	transMove, _, _, _ := position.Trans.Retrieve(bd.FullKey(), depth, ply)
+	count := uint64(0)
+	genInfo := GenInfoStruct{Sv: 0, Ply: ply, TransMove: bd.Expand(transMove)}
+	Next = nextFor(bd)
+	for mv, _ := Next(&genInfo, bd); mv != moves.NoMove; mv, _ = Next(&genInfo, bd) {
+		if !bd.Move(mv) {
+			continue
+		}
+		count += perft(depth-1, ply+1, bd)
+		bd.Unmove(mv)
+	}
+
+	return count
+}
@@ -0,0 +1,296 @@
+package engine
+
+import (
+	"testing"
+
+	. "github.com/Tecu23/go-game/pkg/chess/constants"
+	"github.com/Tecu23/go-game/pkg/chess/moves"
+	"github.com/Tecu23/go-game/pkg/chess/position"
+)
+
+// the published perft positions with their node counts by depth, from depth 1
+var perftTests = []struct {
+	name   string
+	fen    string
+	counts []uint64
+}{
+	{"start", "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+		[]uint64{20, 400, 8902, 197281, 4865609, 119060324}},
+	{"kiwipete", "r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+		[]uint64{48, 2039, 97862, 4085603, 193690690}},
+	{"position 3", "8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1",
+		[]uint64{14, 191, 2812, 43238, 674624, 11030083}},
+	{"position 4", "r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1",
+		[]uint64{6, 264, 9467, 422333, 15833292}},
+	{"position 5", "rnbq1k1r/pp1Pbppp/2p5/8/2B5/8/PPP1NnPP/RNBQK2R w KQ - 1 8",
+		[]uint64{44, 1486, 62379, 2103487, 89941194}},
+	{"position 6", "r4rk1/1pp1qppp/p1np1n2/2b1p1B1/2B1P1b1/P1NP1N2/1PP1QPPP/R4RK1 w - - 0 10",
+		[]uint64{46, 2079, 89890, 3894594, 164075551}},
+}
+
+// the Chess960 perft positions, with the castling rights in Shredder-FEN
+var perft960Tests = []struct {
+	name   string
+	fen    string
+	counts []uint64
+}{
+	{"960 1", "bqnb1rkr/pp3ppp/3ppn2/2p5/5P2/P2P4/NPP1P1PP/BQ1BNRKR w HFhf - 2 9",
+		[]uint64{21, 528, 12189, 326672, 8146062}},
+	{"960 2", "2nnrbkr/p1qppppp/8/1ppb4/6PP/3PP3/PPP2P2/BQNNRBKR w HEhe - 1 9",
+		[]uint64{21, 807, 18002, 667366, 16253601}},
+	{"960 3", "b1q1rrkb/pppppppp/3nn3/8/P7/1PPP4/4PPPP/BQNNRKRB w GE - 1 9",
+		[]uint64{20, 479, 10471, 273318, 6417013}},
+	{"960 4", "qbbnnrkr/2pp2pp/p7/1p2pp2/8/P3PP2/1PPP1KPP/QBBNNR1R w hf - 0 9",
+		[]uint64{22, 593, 13440, 382958, 9183776}},
+	{"960 5", "1nbbnrkr/p1p1ppp1/3p4/1p3P1p/3Pq2P/8/PPP1P1P1/QNBBNRKR w HFhf - 0 9",
+		[]uint64{28, 1120, 31058, 1171749, 34030312}},
+	{"960 6", "qnbnr1kr/ppp1b1pp/4p3/3p1p2/8/2NPP3/PPP1BPPP/QNB1R1KR w HEhe - 1 9",
+		[]uint64{29, 899, 26578, 824055, 24851983}},
+	{"960 7", "q1bnrkr1/ppppp2p/2n2p2/4b1p1/2NP4/8/PPP1PPPP/QNB1RRKB w ge - 1 9",
+		[]uint64{30, 860, 24566, 732757, 21093346}},
+	{"960 8", "qbn1brkr/ppp1p1p1/2n4p/3p1p2/P7/6PP/QPPPPP2/1BNNBRKR w HFhf - 0 9",
+		[]uint64{25, 635, 17054, 465806, 13203304}},
+}
+
+// the node limits for a depth to be tested, deeper counts take minutes
+const (
+	perftShort = 500_000
+	perftLong  = 20_000_000
+)
+
+func TestPerft(t *testing.T) {
+	limit := uint64(perftLong)
+	if testing.Short() {
+		limit = perftShort
+	}
+
+	for _, tt := range perftTests {
+		b, err := position.ParseFEN(tt.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for ix, want := range tt.counts {
+			if want > limit {
+				break
+			}
+			if got := Perft(ix+1, b); got != want {
+				t.Errorf("%v depth %v: got %v, want %v", tt.name, ix+1, got, want)
+			}
+		}
+		if got := b.FEN(); got != tt.fen {
+			t.Errorf("%v: the board is %v after perft", tt.name, got)
+		}
+	}
+}
+
+func TestPerft960(t *testing.T) {
+	limit := uint64(perftLong)
+	if testing.Short() {
+		limit = perftShort
+	}
+
+	for _, tt := range perft960Tests {
+		b, err := position.ParseFEN(tt.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fen := b.FEN()
+		for ix, want := range tt.counts {
+			if want > limit {
+				break
+			}
+			if got := Perft(ix+1, b); got != want {
+				t.Errorf("%v depth %v: got %v, want %v", tt.name, ix+1, got, want)
+			}
+			if want <= limit/10 {
+				if got := keyPerft(t, ix+1, b); got != want {
+					t.Errorf("%v depth %v: got %v with the key checks, want %v", tt.name, ix+1, got, want)
+				}
+			}
+		}
+		if got := b.FEN(); got != fen {
+			t.Errorf("%v: the board is %v after perft", tt.name, got)
+		}
+	}
+}
+
+func TestDivide(t *testing.T) {
+	b, err := position.ParseFEN(Startpos)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]uint64{
+		"a2a3": 380, "b2b3": 420, "c2c3": 420, "d2d3": 539, "e2e3": 599, "f2f3": 380, "g2g3": 420, "h2h3": 380,
+		"a2a4": 420, "b2b4": 421, "c2c4": 441, "d2d4": 560, "e2e4": 600, "f2f4": 401, "g2g4": 421, "h2h4": 420,
+		"b1a3": 400, "b1c3": 440, "g1f3": 440, "g1h3": 400,
+	}
+	got := Divide(3, b)
+	if len(got) != len(want) {
+		t.Errorf("got %v moves, want %v", len(got), len(want))
+	}
+	for mv, cnt := range want {
+		if got[mv] != cnt {
+			t.Errorf("%v: got %v, want %v", mv, got[mv], cnt)
+		}
+	}
+
+	// promotions are told apart
+	b, err = position.ParseFEN("4k3/1P6/8/8/8/8/8/4K3 w - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = Divide(1, b)
+	for _, mv := range []string{"b7b8q", "b7b8r", "b7b8b", "b7b8n"} {
+		if got[mv] != 1 {
+			t.Errorf("%v: got %v, want 1", mv, got[mv])
+		}
+	}
+
+	// castlings are the king moving two squares, or taking its rook with UCI_Chess960
+	b, err = position.ParseFEN(perftTests[1].fen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { position.Chess960 = false }()
+	for _, chess960 := range []bool{false, true} {
+		position.Chess960 = chess960
+		got = Divide(2, b)
+		want := map[string]uint64{"e1g1": 43, "e1c1": 43}
+		if chess960 {
+			want = map[string]uint64{"e1h1": 43, "e1a1": 43}
+		}
+		for mv, cnt := range want {
+			if got[mv] != cnt {
+				t.Errorf("chess960 %v, %v: got %v, want %v", chess960, mv, got[mv], cnt)
+			}
+		}
+	}
+}
+
+// freshKey computes the full key of b from scratch
+func freshKey(b *position.BoardStruct) uint64 {
+	key := uint64(0)
+	for sq, pc := range b.Squares {
+		if pc != Empty {
+			key ^= position.PcSqKey(pc, sq)
+		}
+	}
+	if b.Stm == BLACK {
+		key = position.FlipSide(key)
+	}
+	key ^= position.ChecksKey(WHITE, b.Checks[WHITE]) ^ position.ChecksKey(BLACK, b.Checks[BLACK])
+	for pc, n := range b.Pockets {
+		key ^= position.PocketKey(pc, n)
+	}
+	return key ^ position.EpKey(b.Ep) ^ position.CastlKey(uint(b.Castlings))
+}
+
+// keyPerft counts the leaf nodes like perft and checks at every node that the key kept up
+// by Move is the one computed from scratch and that Unmove gives the old key back
+func keyPerft(t *testing.T, depth int, b *position.BoardStruct) uint64 {
+	if got, want := b.FullKey(), freshKey(b); got != want {
+		t.Fatalf("%v: got key %016x, want %016x", b.FEN(), got, want)
+	}
+	if depth == 0 {
+		return 1
+	}
+
+	var ml moves.MoveList
+	b.GenAllLegals(&ml)
+	cnt := uint64(0)
+	for _, mv := range ml {
+		key := b.FullKey()
+		b.Move(mv)
+		cnt += keyPerft(t, depth-1, b)
+		b.Unmove(mv)
+		if b.FullKey() != key {
+			t.Fatalf("%v: the key after Unmove of %v is %016x, want %016x", b.FEN(), mv, b.FullKey(), key)
+		}
+	}
+	return cnt
+}
+
+func TestPerftKeys(t *testing.T) {
+	limit := uint64(perftLong / 10)
+	if testing.Short() {
+		limit = perftShort / 10
+	}
+
+	for _, tt := range perftTests {
+		b, err := position.ParseFEN(tt.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for ix, want := range tt.counts {
+			if want > limit {
+				break
+			}
+			if got := keyPerft(t, ix+1, b); got != want {
+				t.Errorf("%v depth %v: got %v, want %v", tt.name, ix+1, got, want)
+			}
+		}
+	}
+}
+
+func TestDivideParallel(t *testing.T) {
+	limit := uint64(perftLong * 5)
+	if testing.Short() {
+		limit = perftShort * 10
+	}
+	tt := NewPerftTT(16)
+
+	for _, table := range []*PerftTT{nil, tt} {
+		for _, test := range perftTests {
+			b, err := position.ParseFEN(test.fen)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for ix, want := range test.counts {
+				if want > limit || (table == nil && want > limit/10) {
+					break
+				}
+				got := uint64(0)
+				for _, cnt := range DivideParallel(ix+1, 4, table, b) {
+					got += cnt
+				}
+				if got != want {
+					t.Errorf("%v depth %v: got %v, want %v", test.name, ix+1, got, want)
+				}
+			}
+			if got := b.FEN(); got != test.fen {
+				t.Errorf("%v: the board is %v after perft", test.name, got)
+			}
+		}
+	}
+
+	// the same divide as the ordered move generator
+	b, err := position.ParseFEN(perftTests[1].fen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Divide(3, b)
+	got := DivideParallel(3, 3, tt, b)
+	if len(got) != len(want) {
+		t.Fatalf("got %v moves, want %v", len(got), len(want))
+	}
+	for mv, cnt := range want {
+		if got[mv] != cnt {
+			t.Errorf("%v: got %v, want %v", mv, got[mv], cnt)
+		}
+	}
+}
+
+func BenchmarkPerft(b *testing.B) {
+	bd, _ := position.ParseFEN(perftTests[1].fen)
+	for i := 0; i < b.N; i++ {
+		Perft(3, bd)
+	}
+}
+
+func BenchmarkDivideParallel(b *testing.B) {
+	bd, _ := position.ParseFEN(perftTests[1].fen)
+	for i := 0; i < b.N; i++ {
+		DivideParallel(4, 4, NewPerftTT(16), bd)
+	}
+}
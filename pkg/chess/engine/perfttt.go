@@ -0,0 +1,43 @@
+package engine
+
+import (
+	"sync/atomic"
+)
+
+// PerftTT caches the leaf node counts of perft subtrees by key and depth. It is shared by
+// the goroutines of a parallel perft without locks: an entry is stored as the key xor'ed
+// with the data and the data, so an entry torn by two writers is not found again
+type PerftTT struct {
+	entries []perftEntry
+	mask    uint64
+}
+
+type perftEntry struct {
+	check uint64 // key ^ data
+	data  uint64 // count<<8 | depth
+}
+
+// NewPerftTT returns a table of at most mb megabytes
+func NewPerftTT(mb int) *PerftTT {
+	n := uint64(1)
+	for (n*2)*16 <= uint64(mb)<<20 {
+		n *= 2
+	}
+	return &PerftTT{entries: make([]perftEntry, n), mask: n - 1}
+}
+
+func (t *PerftTT) probe(key uint64, depth int) (uint64, bool) {
+	e := &t.entries[key&t.mask]
+	data := atomic.LoadUint64(&e.data)
+	if atomic.LoadUint64(&e.check)^data != key || int(data&0xff) != depth {
+		return 0, false
+	}
+	return data >> 8, true
+}
+
+func (t *PerftTT) store(key uint64, depth int, cnt uint64) {
+	e := &t.entries[key&t.mask]
+	data := cnt<<8 | uint64(depth)
+	atomic.StoreUint64(&e.check, key^data)
+	atomic.StoreUint64(&e.data, data)
+}
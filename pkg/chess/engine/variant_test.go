@@ -0,0 +1,138 @@
+package engine
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/Tecu23/go-game/pkg/chess/constants"
+	"github.com/Tecu23/go-game/pkg/chess/moves"
+	"github.com/Tecu23/go-game/pkg/chess/position"
+)
+
+// the variant perft positions with their node counts by depth, from depth 1. A game that is
+// over has no moves
+var variantPerftTests = []struct {
+	name    string
+	variant position.Variant
+	fen     string
+	counts  []uint64
+}{
+	{"antichess start", position.Antichess, "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w - - 0 1",
+		[]uint64{20, 400, 8067, 153299, 2732672}},
+	{"antichess promotions", position.Antichess, "8/2P5/8/8/8/8/5p2/8 w - - 0 1",
+		[]uint64{5, 25, 255}},
+	{"koth center", position.KingOfTheHill, "rnbq1bnr/pppp1ppp/4k3/4p3/4P3/4K3/PPPP1PPP/RNBQ1BNR w - - 0 1",
+		[]uint64{32, 965, 28599, 835858, 24416623}},
+	{"koth kings", position.KingOfTheHill, "8/8/4k3/8/8/2K5/8/8 w - - 0 1",
+		[]uint64{8, 55, 332, 2453, 15159, 102350}},
+	{"koth over", position.KingOfTheHill, "8/8/4k3/8/4K3/8/8/8 b - - 0 1",
+		[]uint64{0, 0}},
+	{"3check kiwipete", position.ThreeCheck, "r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1 +2+2",
+		[]uint64{48, 2039, 97848, 4081798}},
+	{"3check kiwipete +1+1", position.ThreeCheck, "r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1 +1+1",
+		[]uint64{48, 2039, 97862, 4085603}},
+	{"crazyhouse start", position.Crazyhouse, "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR[] w KQkq - 0 1",
+		[]uint64{20, 400, 8902, 197281, 4888832}},
+	{"crazyhouse middlegame", position.Crazyhouse, "r1bqk2r/pppp1ppp/2n1p3/4P3/1b1Pn3/2NB1N2/PPP2PPP/R1BQK2R[] b KQkq - 0 1",
+		[]uint64{42, 1347, 58057, 2083382}},
+	{"crazyhouse pockets", position.Crazyhouse, "2k5/8/8/8/8/8/8/4K3[QRBNPqrbnp] w - - 0 1",
+		[]uint64{301, 75353}},
+}
+
+func TestVariantPerft(t *testing.T) {
+	limit := uint64(perftLong)
+	if testing.Short() {
+		limit = perftShort
+	}
+
+	for _, tt := range variantPerftTests {
+		b, err := position.ParseFENVariant(tt.fen, tt.variant, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for ix, want := range tt.counts {
+			if want > limit {
+				break
+			}
+			if got := Perft(ix+1, b); got != want {
+				t.Errorf("%v depth %v: got %v, want %v", tt.name, ix+1, got, want)
+			}
+			if want > limit/10 {
+				continue
+			}
+			if got := keyPerft(t, ix+1, b); got != want {
+				t.Errorf("%v depth %v: got %v with the key checks, want %v", tt.name, ix+1, got, want)
+			}
+			got := uint64(0)
+			for _, cnt := range DivideParallel(ix+1, 2, NewPerftTT(1), b) {
+				got += cnt
+			}
+			if got != want {
+				t.Errorf("%v depth %v: got %v with DivideParallel, want %v", tt.name, ix+1, got, want)
+			}
+		}
+		if got := b.FEN(); got != tt.fen {
+			t.Errorf("%v: the board is %v after perft", tt.name, got)
+		}
+	}
+}
+
+// refPerft counts the leaf nodes with the rules of King of the Hill and Three-check written
+// out on a board of chess, checks are the checks given
+func refPerft(v position.Variant, depth int, b *position.BoardStruct, checks [2]int) uint64 {
+	if depth == 0 {
+		return 1
+	}
+	moved := b.Stm.Opposite()
+	if king := b.King[moved]; v == position.KingOfTheHill && (king == D4 || king == E4 || king == D5 || king == E5) {
+		return 0
+	}
+	if v == position.ThreeCheck && checks[moved] >= 3 {
+		return 0
+	}
+
+	var ml moves.MoveList
+	b.GenAllMoves(&ml)
+	cnt := uint64(0)
+	for _, mv := range ml {
+		if !b.Move(mv) {
+			continue
+		}
+		c := checks
+		if b.IsAttacked(b.King[b.Stm], b.Stm.Opposite()) {
+			c[b.Stm.Opposite()]++
+		}
+		cnt += refPerft(v, depth-1, b, c)
+		b.Unmove(mv)
+	}
+	return cnt
+}
+
+func TestVariantPerftRef(t *testing.T) {
+	limit := uint64(perftLong / 10)
+	if testing.Short() {
+		limit = perftShort / 10
+	}
+
+	for _, tt := range variantPerftTests {
+		if tt.variant != position.KingOfTheHill && tt.variant != position.ThreeCheck {
+			continue
+		}
+		vb, err := position.ParseFENVariant(tt.fen, tt.variant, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := position.ParseFEN(strings.Join(strings.Fields(tt.fen)[:6], " "))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for ix, want := range tt.counts {
+			if want > limit {
+				break
+			}
+			if got := refPerft(tt.variant, ix+1, b, vb.Checks); got != want {
+				t.Errorf("%v depth %v: got %v from the rules, want %v", tt.name, ix+1, got, want)
+			}
+		}
+	}
+}
@@ -54,9 +54,14 @@ type dirstr struct {
 	fDir int
 }
 
+// the directions of the bishops and the rooks as rank and file steps
+var (
+	dirsB = []dirstr{{+1, +1}, {-1, +1}, {+1, -1}, {-1, -1}}
+	dirsR = []dirstr{{+1, 0}, {-1, 0}, {0, +1}, {0, -1}}
+)
+
 // create move bitboard.BitBoards for bishops on all squares
 func PrepareMagicB() {
-	dirsB := []dirstr{{+1, +1}, {-1, +1}, {+1, -1}, {-1, -1}}
 	for fr := A1; fr <= H8; fr++ {
 		maxM := -1
 		// all bit combinations for fr and all possible blockers
@@ -68,7 +73,6 @@ func PrepareMagicB() {
 
 // create move bitboard.BitBoards for rooks on all squares
 func PrepareMagicR() {
-	dirsR := []dirstr{{+1, 0}, {-1, 0}, {0, +1}, {0, -1}}
 	for fr := A1; fr <= H8; fr++ {
 		maxM := -1
 		// all bit combinations for fr and all possible moves (toSqBB)
@@ -0,0 +1,133 @@
+package magic
+
+import (
+	"math/bits"
+	"math/rand"
+	"os"
+	"testing"
+
+	"github.com/Tecu23/go-game/pkg/chess/bitboard"
+	. "github.com/Tecu23/go-game/pkg/chess/constants"
+)
+
+func TestMain(m *testing.M) {
+	InitFen2Sq()
+	InitMagic()
+
+	os.Exit(m.Run())
+}
+
+func TestOccupancies(t *testing.T) {
+	mask := InnerRAtks(D4)
+	occs := Occupancies(mask)
+	if len(occs) != 1<<bits.OnesCount64(mask) {
+		t.Fatalf("got %v occupancies, want %v", len(occs), 1<<bits.OnesCount64(mask))
+	}
+	seen := map[uint64]bool{}
+	for _, occ := range occs {
+		if occ&^mask != 0 || seen[occ] {
+			t.Fatalf("%x is outside %x or seen twice", occ, mask)
+		}
+		seen[occ] = true
+	}
+}
+
+// TestShippedMagics verifies the magics InitMagic uses and the tables it builds
+func TestShippedMagics(t *testing.T) {
+	for _, bishop := range []bool{true, false} {
+		for sq := A1; sq <= H8; sq++ {
+			tab, magic, nBits := &MRookTab[sq], MagicR[sq], NRBits[sq]
+			if bishop {
+				tab, magic, nBits = &MBishopTab[sq], MagicB[sq], NBBits[sq]
+			}
+			size, ok := Verify(sq, bishop, magic, nBits)
+			if !ok || size != len(tab.ToSqBB) {
+				t.Errorf("%v (bishop %v): Verify = %v, %v, the table has %v entries", Sq2Fen[sq], bishop, size, ok, len(tab.ToSqBB))
+			}
+			for _, occ := range Occupancies(InnerAtks(sq, bishop)) {
+				occ |= 0x8100000000000081 // the edges make no difference
+				if got, want := tab.Atks(bitboard.BitBoard(occ)), SliderAtks(sq, bishop, occ); uint64(got) != want {
+					t.Fatalf("%v (bishop %v): Atks(%x) = %x, want %x", Sq2Fen[sq], bishop, occ, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestFindMagic(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, tt := range []struct {
+		sq     int
+		bishop bool
+	}{{A1, false}, {D4, true}, {E5, false}, {H8, true}} {
+		nBits := bits.OnesCount64(InnerAtks(tt.sq, tt.bishop))
+		magic, size, ok := FindMagic(tt.sq, tt.bishop, nBits, rnd, 100000000)
+		if !ok {
+			t.Fatalf("no magic found for %v (bishop %v)", Sq2Fen[tt.sq], tt.bishop)
+		}
+		if got, ok := Verify(tt.sq, tt.bishop, magic, nBits); !ok || got != size || size > 1<<nBits {
+			t.Errorf("%v (bishop %v): Verify(%#x) = %v, %v, want %v", Sq2Fen[tt.sq], tt.bishop, magic, got, ok, size)
+		}
+	}
+
+	// a bishop on d4 needs more than 3 bits
+	if _, ok := Verify(D4, true, MagicB[D4], 3); ok {
+		t.Errorf("the d4 bishop magic works with 3 bits")
+	}
+}
+
+// randomOcc returns random occupancies with about a quarter of the squares occupied
+func randomOcc(n int) []bitboard.BitBoard {
+	rnd := rand.New(rand.NewSource(1))
+	occs := make([]bitboard.BitBoard, n)
+	for i := range occs {
+		occs[i] = bitboard.BitBoard(rnd.Uint64() & rnd.Uint64())
+	}
+	return occs
+}
+
+func TestSliders(t *testing.T) {
+	occs := append(randomOcc(200), 0, ^bitboard.BitBoard(0))
+	for _, name := range SliderNames() {
+		s := Sliders[name]
+		s.Init()
+		for sq := A1; sq <= H8; sq++ {
+			for _, occ := range occs {
+				if got, want := s.BishopAtks(sq, occ), SliderAtks(sq, true, uint64(occ)); uint64(got) != want {
+					t.Fatalf("%v: BishopAtks(%v, %x) = %x, want %x", name, Sq2Fen[sq], occ, got, want)
+				}
+				if got, want := s.RookAtks(sq, occ), SliderAtks(sq, false, uint64(occ)); uint64(got) != want {
+					t.Fatalf("%v: RookAtks(%v, %x) = %x, want %x", name, Sq2Fen[sq], occ, got, want)
+				}
+			}
+		}
+	}
+
+	defer Use(Plain)
+	if err := UseName("koggestone"); err != nil || CurrentSlider() != KoggeStone {
+		t.Errorf("UseName(koggestone) = %v, the backend is %v", err, CurrentSlider().Name())
+	}
+	if err := UseName("pext"); err == nil {
+		t.Errorf("UseName(pext) works")
+	}
+}
+
+func BenchmarkSliders(b *testing.B) {
+	occs := randomOcc(1024)
+	for _, name := range SliderNames() {
+		s := Sliders[name]
+		s.Init()
+		b.Run(name, func(b *testing.B) {
+			var sum bitboard.BitBoard
+			for i := 0; i < b.N; i++ {
+				occ := occs[i&1023]
+				sq := i & 63
+				sum ^= s.BishopAtks(sq, occ) | s.RookAtks(sq, occ)
+			}
+			sink = sum
+		})
+	}
+}
+
+// sink keeps the benchmarks from being optimized away
+var sink bitboard.BitBoard
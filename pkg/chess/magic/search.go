@@ -0,0 +1,106 @@
+package magic
+
+import (
+	"math/bits"
+	"math/rand"
+)
+
+// Occupancies returns every subset of mask, the empty one first
+func Occupancies(mask uint64) []uint64 {
+	occs := make([]uint64, 0, 1<<bits.OnesCount64(mask))
+	for sub := uint64(0); ; {
+		occs = append(occs, sub)
+		sub = (sub - mask) & mask // carry-rippler, the next subset
+		if sub == 0 {
+			return occs
+		}
+	}
+}
+
+// InnerAtks returns the squares a bishop or a rook on sq attacks on an empty board without
+// the edges, the squares whose occupancy makes a difference
+func InnerAtks(sq int, bishop bool) uint64 {
+	if bishop {
+		return InnerBAtks(sq)
+	}
+	return InnerRAtks(sq)
+}
+
+// SliderAtks returns the squares a bishop or a rook on sq attacks with the squares in occ
+// occupied
+func SliderAtks(sq int, bishop bool, occ uint64) uint64 {
+	if bishop {
+		return ComputeAtks(sq, dirsB, occ)
+	}
+	return ComputeAtks(sq, dirsR, occ)
+}
+
+// checker tries magics for one square. It keeps the occupancies, their attacks and the table
+// between the tries
+type checker struct {
+	occs  []uint64
+	atks  []uint64
+	table []uint64
+	tried []int // the try an entry of table was filled in, the table is not cleared
+	try   int
+}
+
+func newChecker(sq int, bishop bool) *checker {
+	c := &checker{occs: Occupancies(InnerAtks(sq, bishop))}
+	c.atks = make([]uint64, len(c.occs))
+	for i, occ := range c.occs {
+		c.atks[i] = SliderAtks(sq, bishop, occ)
+	}
+	return c
+}
+
+// check returns the number of entries magic needs with a table of 1<<nBits entries, the
+// highest index plus one, and false if two occupancies with different attacks share an entry
+func (c *checker) check(magic uint64, nBits int) (int, bool) {
+	if nBits < 1 || nBits > 32 {
+		return 0, false
+	}
+	if len(c.table) < 1<<nBits {
+		c.table = make([]uint64, 1<<nBits)
+		c.tried = make([]int, 1<<nBits)
+	}
+	c.try++
+	shift := uint(64 - nBits)
+	size := 0
+	for i, occ := range c.occs {
+		ix := int((occ * magic) >> shift)
+		if c.tried[ix] != c.try {
+			c.tried[ix] = c.try
+			c.table[ix] = c.atks[i]
+		} else if c.table[ix] != c.atks[i] {
+			return 0, false
+		}
+		size = max(size, ix+1)
+	}
+	return size, true
+}
+
+// Verify checks magic for a bishop or a rook on sq with a table of 1<<nBits entries against
+// ComputeAtks for every occupancy of the inner squares. It returns the number of entries the
+// table needs and false if two occupancies with different attacks share an entry
+func Verify(sq int, bishop bool, magic uint64, nBits int) (int, bool) {
+	return newChecker(sq, bishop).check(magic, nBits)
+}
+
+// FindMagic tries up to tries random sparse magics for a bishop or a rook on sq with a table
+// of 1<<nBits entries. It returns the first magic that works and the number of entries it
+// needs, false if none was found
+func FindMagic(sq int, bishop bool, nBits int, rnd *rand.Rand, tries int) (uint64, int, bool) {
+	c := newChecker(sq, bishop)
+	mask := InnerAtks(sq, bishop)
+	for i := 0; i < tries; i++ {
+		magic := rnd.Uint64() & rnd.Uint64() & rnd.Uint64()
+		if bits.OnesCount64((mask*magic)>>56) < 6 { // too few bits in the index, skip it
+			continue
+		}
+		if size, ok := c.check(magic, nBits); ok {
+			return magic, size, true
+		}
+	}
+	return 0, 0, false
+}
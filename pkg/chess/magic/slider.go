@@ -0,0 +1,192 @@
+package magic
+
+import (
+	"fmt"
+	"math/bits"
+	"sort"
+
+	"github.com/Tecu23/go-game/pkg/chess/bitboard"
+	. "github.com/Tecu23/go-game/pkg/chess/constants"
+)
+
+// Slider computes the attacks of bishops and rooks. The backends give the same attacks and
+// differ in speed and memory only
+type Slider interface {
+	Name() string
+	// Init builds the tables of the backend, after InitMagic
+	Init()
+	BishopAtks(sq int, occ bitboard.BitBoard) bitboard.BitBoard
+	RookAtks(sq int, occ bitboard.BitBoard) bitboard.BitBoard
+}
+
+// the slider backends
+var (
+	// Plain looks the attacks up in MBishopTab and MRookTab, one table per square
+	Plain Slider = plain{}
+	// Fancy uses the same magics with the tables of all squares in one slice
+	Fancy Slider = &fancy{}
+	// KoggeStone computes the attacks with occluded fills, without tables
+	KoggeStone Slider = koggeStone{}
+)
+
+// Sliders are the slider backends by name
+var Sliders = map[string]Slider{
+	Plain.Name():      Plain,
+	Fancy.Name():      Fancy,
+	KoggeStone.Name(): KoggeStone,
+}
+
+// SliderNames returns the names of the slider backends sorted
+func SliderNames() []string {
+	names := make([]string, 0, len(Sliders))
+	for name := range Sliders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// slider is the backend in use
+var slider = Plain
+
+// Use inits s and makes it the backend of BishopAtks and RookAtks
+func Use(s Slider) {
+	s.Init()
+	slider = s
+}
+
+// UseName is Use by the name of the backend
+func UseName(name string) error {
+	s, ok := Sliders[name]
+	if !ok {
+		return fmt.Errorf("unknown slider backend %q", name)
+	}
+	Use(s)
+	return nil
+}
+
+// CurrentSlider returns the backend in use
+func CurrentSlider() Slider {
+	return slider
+}
+
+// BishopAtks returns the squares a bishop on sq attacks with the squares in occ occupied
+func BishopAtks(sq int, occ bitboard.BitBoard) bitboard.BitBoard {
+	return slider.BishopAtks(sq, occ)
+}
+
+// RookAtks returns the squares a rook on sq attacks with the squares in occ occupied
+func RookAtks(sq int, occ bitboard.BitBoard) bitboard.BitBoard {
+	return slider.RookAtks(sq, occ)
+}
+
+// plain magics, the tables of InitMagic
+type plain struct{}
+
+func (plain) Name() string { return "plain" }
+
+func (plain) Init() {}
+
+func (plain) BishopAtks(sq int, occ bitboard.BitBoard) bitboard.BitBoard {
+	return MBishopTab[sq].Atks(occ)
+}
+
+func (plain) RookAtks(sq int, occ bitboard.BitBoard) bitboard.BitBoard {
+	return MRookTab[sq].Atks(occ)
+}
+
+// fancyEntry is the magic of a square with where its attacks start in the shared table
+type fancyEntry struct {
+	innerBB bitboard.BitBoard
+	magic   uint64
+	shift   uint
+	offset  int
+}
+
+// fancy magics, the attacks of all squares in one table
+type fancy struct {
+	bishops [64]fancyEntry
+	rooks   [64]fancyEntry
+	table   []bitboard.BitBoard // the attacks of all squares, bishops first
+}
+
+func (f *fancy) Name() string { return "fancy" }
+
+// Init builds the shared table from the magics of MBishopTab and MRookTab
+func (f *fancy) Init() {
+	if f.table != nil {
+		return
+	}
+	for _, bishop := range []bool{true, false} {
+		for sq := A1; sq <= H8; sq++ {
+			tab, e := &MRookTab[sq], &f.rooks[sq]
+			if bishop {
+				tab, e = &MBishopTab[sq], &f.bishops[sq]
+			}
+			*e = fancyEntry{tab.InnerBB, tab.Magic, tab.Shift, len(f.table)}
+			size, ok := Verify(sq, bishop, tab.Magic, int(64-tab.Shift))
+			if !ok {
+				panic(fmt.Sprintf("wrong magic for %v", Sq2Fen[sq]))
+			}
+			f.table = append(f.table, make([]bitboard.BitBoard, size)...)
+			for _, occ := range Occupancies(uint64(tab.InnerBB)) {
+				f.table[e.index(bitboard.BitBoard(occ))] = bitboard.BitBoard(SliderAtks(sq, bishop, occ))
+			}
+		}
+	}
+}
+
+func (e *fancyEntry) index(occ bitboard.BitBoard) int {
+	return e.offset + int(((occ&e.innerBB)*bitboard.BitBoard(e.magic))>>e.shift)
+}
+
+func (f *fancy) BishopAtks(sq int, occ bitboard.BitBoard) bitboard.BitBoard {
+	return f.table[f.bishops[sq].index(occ)]
+}
+
+func (f *fancy) RookAtks(sq int, occ bitboard.BitBoard) bitboard.BitBoard {
+	return f.table[f.rooks[sq].index(occ)]
+}
+
+// ray is a direction as a rotation of the board and the squares a step can land on without
+// wrapping around it
+type ray struct {
+	rot  int
+	onBB bitboard.BitBoard
+}
+
+var (
+	diagRays = [4]ray{{9, ^FileA &^ Row1}, {7, ^FileH &^ Row1}, {-7, ^FileA &^ Row8}, {-9, ^FileH &^ Row8}}
+	lineRays = [4]ray{{8, ^Row1}, {-8, ^Row8}, {1, ^FileA}, {-1, ^FileH}}
+)
+
+// atks fills from sq along r through the empty squares and returns the squares reached,
+// the first occupied one included
+func (r ray) atks(sq int, occ bitboard.BitBoard) bitboard.BitBoard {
+	rotl := func(bb bitboard.BitBoard, n int) bitboard.BitBoard {
+		return bitboard.BitBoard(bits.RotateLeft64(uint64(bb), n))
+	}
+	gen := bitboard.BitBoard(1) << uint(sq)
+	pro := ^occ & r.onBB
+	gen |= pro & rotl(gen, r.rot)
+	pro &= rotl(pro, r.rot)
+	gen |= pro & rotl(gen, 2*r.rot)
+	pro &= rotl(pro, 2*r.rot)
+	gen |= pro & rotl(gen, 4*r.rot)
+	return rotl(gen, r.rot) & r.onBB
+}
+
+// Kogge-Stone occluded fills along the four rays of the piece
+type koggeStone struct{}
+
+func (koggeStone) Name() string { return "koggestone" }
+
+func (koggeStone) Init() {}
+
+func (koggeStone) BishopAtks(sq int, occ bitboard.BitBoard) bitboard.BitBoard {
+	return diagRays[0].atks(sq, occ) | diagRays[1].atks(sq, occ) | diagRays[2].atks(sq, occ) | diagRays[3].atks(sq, occ)
+}
+
+func (koggeStone) RookAtks(sq int, occ bitboard.BitBoard) bitboard.BitBoard {
+	return lineRays[0].atks(sq, occ) | lineRays[1].atks(sq, occ) | lineRays[2].atks(sq, occ) | lineRays[3].atks(sq, occ)
+}
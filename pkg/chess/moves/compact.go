@@ -0,0 +1,126 @@
+package moves
+
+import (
+	"sort"
+	"strings"
+
+	. "github.com/Tecu23/go-game/pkg/chess/constants"
+)
+
+// Move16 is a move in 16 bits, 6 bits fr, 6 bits to and a 4 bit flag, for the transposition
+// table and the killers. It has no piece, captured piece, ep or castling rights, the board
+// fills them in, see BoardStruct.Compact and BoardStruct.Expand
+type Move16 uint16
+
+// NoMove16 is no move, a1a1 is not a move
+const NoMove16 = Move16(0)
+
+// the flags of a Move16. A promotion has the piece type promoted to as flag, 1-5 for
+// Knight-King, and a drop FlagDrop plus the piece type dropped, 8-12 for Pawn-Queen
+const (
+	FlagNormal   = 0
+	FlagCastling = 6
+	FlagEp       = 7
+	FlagDrop     = 8
+)
+
+// NewMove16 packs fr, to and flag into a Move16
+func NewMove16(fr, to, flag int) Move16 {
+	return Move16(fr | to<<6 | flag<<12)
+}
+
+func (m Move16) Fr() int {
+	return int(m & 0x3f)
+}
+
+func (m Move16) To() int {
+	return int(m>>6) & 0x3f
+}
+
+func (m Move16) Flag() int {
+	return int(m >> 12)
+}
+
+// Promo returns the piece type m promotes to, Empty if it is not a promotion
+func (m Move16) Promo() int {
+	if f := m.Flag(); f > FlagNormal && f < FlagCastling {
+		return f
+	}
+	return Empty
+}
+
+// IsDrop tells if m drops a piece from the pocket
+func (m Move16) IsDrop() bool {
+	return m.Flag() >= FlagDrop
+}
+
+// Drop returns the piece type m drops, Empty if it is not a drop
+func (m Move16) Drop() int {
+	if m.IsDrop() {
+		return m.Flag() - FlagDrop
+	}
+	return Empty
+}
+
+// Is tells if mv is the move m, by the from and to squares and the promotion or dropped
+// piece. In a given position that is enough to tell the moves apart
+func (m Move16) Is(mv Move) bool {
+	if m == NoMove16 || mv == NoMove {
+		return false
+	}
+	if mv.IsDrop() {
+		return m.IsDrop() && m.To() == mv.To() && m.Drop() == mv.Pc()>>1
+	}
+	pr := Empty
+	if mv.Pr() != Empty {
+		pr = mv.Pr() >> 1
+	}
+	return !m.IsDrop() && m.Fr() == mv.Fr() && m.To() == mv.To() && m.Promo() == pr
+}
+
+// String returns m in coordinate notation, a castling as the king taking its rook
+func (m Move16) String() string {
+	if m.IsDrop() {
+		return string(PtFen[m.Drop()]) + "@" + Sq2Fen[m.To()]
+	}
+	s := Sq2Fen[m.Fr()] + Sq2Fen[m.To()]
+	if pt := m.Promo(); pt != Empty {
+		s += strings.ToLower(string(PtFen[pt]))
+	}
+	return s
+}
+
+// ScoredMove is a move with its score for the move ordering
+type ScoredMove struct {
+	Mv    Move
+	Score int
+}
+
+type ScoredList []ScoredMove
+
+// Add adds mv with the score sc
+func (sl *ScoredList) Add(mv Move, sc int) {
+	*sl = append(*sl, ScoredMove{mv, sc})
+}
+
+// Sort sorts the list by score, the best first. Moves with the same score keep their order
+func (sl ScoredList) Sort() {
+	sort.SliceStable(sl, func(i, j int) bool { return sl[i].Score > sl[j].Score })
+}
+
+// Best returns the index of the move with the best score, -1 for an empty list
+func (sl ScoredList) Best() int {
+	bIx := -1
+	for ix := range sl {
+		if bIx < 0 || sl[ix].Score > sl[bIx].Score {
+			bIx = ix
+		}
+	}
+	return bIx
+}
+
+// Remove removes the move at ix, the last move takes its place
+func (sl *ScoredList) Remove(ix int) {
+	(*sl)[ix] = (*sl)[len(*sl)-1]
+	*sl = (*sl)[:len(*sl)-1]
+}
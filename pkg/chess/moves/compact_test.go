@@ -0,0 +1,116 @@
+package moves
+
+import (
+	"os"
+	"testing"
+
+	. "github.com/Tecu23/go-game/pkg/chess/constants"
+)
+
+func TestMain(m *testing.M) {
+	InitFen2Sq()
+
+	os.Exit(m.Run())
+}
+
+func TestNewMove16(t *testing.T) {
+	for fr := A1; fr <= H8; fr++ {
+		for to := A1; to <= H8; to++ {
+			for flag := 0; flag < 16; flag++ {
+				m := NewMove16(fr, to, flag)
+				if m.Fr() != fr || m.To() != to || m.Flag() != flag {
+					t.Fatalf("NewMove16(%v, %v, %v) gives %v %v %v", fr, to, flag, m.Fr(), m.To(), m.Flag())
+				}
+			}
+		}
+	}
+}
+
+func TestMove16PromoDrop(t *testing.T) {
+	tests := []struct {
+		flag   int
+		promo  int
+		isDrop bool
+		drop   int
+	}{
+		{FlagNormal, Empty, false, Empty},
+		{Knight, Knight, false, Empty},
+		{Queen, Queen, false, Empty},
+		{King, King, false, Empty}, // Antichess
+		{FlagCastling, Empty, false, Empty},
+		{FlagEp, Empty, false, Empty},
+		{FlagDrop + Pawn, Empty, true, Pawn},
+		{FlagDrop + Queen, Empty, true, Queen},
+	}
+
+	for _, tt := range tests {
+		m := NewMove16(E7, E8, tt.flag)
+		if m.Promo() != tt.promo || m.IsDrop() != tt.isDrop || m.Drop() != tt.drop {
+			t.Errorf("flag %v: got promo %v, drop %v %v, want %v, %v %v", tt.flag, m.Promo(), m.IsDrop(), m.Drop(),
+				tt.promo, tt.isDrop, tt.drop)
+		}
+	}
+}
+
+func TestMove16Is(t *testing.T) {
+	var push, promo, castle, drop, pawnDrop Move
+	push.PackMove(E2, E4, WP, Empty, Empty, 0, 0)
+	promo.PackMove(E7, E8, WP, Empty, WQ, 0, 0)
+	castle.PackMove(E1, H1, WK, Empty, Empty, 0, 0)
+	drop.PackDrop(BN, F6, 0, 0)
+	pawnDrop.PackDrop(WP, A1, 0, 0)
+
+	tests := []struct {
+		m    Move16
+		mv   Move
+		want bool
+		str  string
+	}{
+		{NewMove16(E2, E4, FlagNormal), push, true, "e2e4"},
+		{NewMove16(E2, E3, FlagNormal), push, false, "e2e3"},
+		{NewMove16(E7, E8, Queen), promo, true, "e7e8q"},
+		{NewMove16(E7, E8, Knight), promo, false, "e7e8n"},
+		{NewMove16(E7, E8, FlagNormal), promo, false, "e7e8"},
+		{NewMove16(E1, H1, FlagCastling), castle, true, "e1h1"},
+		{NewMove16(F6, F6, FlagDrop+Knight), drop, true, "N@f6"},
+		{NewMove16(F6, F6, FlagDrop+Bishop), drop, false, "B@f6"},
+		{NewMove16(F6, F6, FlagNormal), drop, false, "f6f6"},
+		{NewMove16(A1, A1, FlagDrop+Pawn), pawnDrop, true, "P@a1"},
+		{NoMove16, NoMove, false, "a1a1"},
+		{NewMove16(A1, A1, FlagDrop+Pawn), NoMove, false, "P@a1"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.m.Is(tt.mv); got != tt.want {
+			t.Errorf("%v.Is(%v) = %v, want %v", tt.m, tt.mv, got, tt.want)
+		}
+		if got := tt.m.String(); got != tt.str {
+			t.Errorf("got %v, want %v", got, tt.str)
+		}
+	}
+}
+
+func TestScoredList(t *testing.T) {
+	var sl ScoredList
+	if sl.Best() != -1 {
+		t.Errorf("the best of an empty list is %v, want -1", sl.Best())
+	}
+	for ix, sc := range []int{5, -3, 12, 5, 0} {
+		sl.Add(Move(ix+1), sc)
+	}
+
+	if bIx := sl.Best(); sl[bIx].Mv != 3 {
+		t.Errorf("got the best move %v, want 3", sl[bIx].Mv)
+	}
+	sl.Sort()
+	want := []Move{3, 1, 4, 5, 2} // the two 5s keep their order
+	for ix, mv := range want {
+		if sl[ix].Mv != mv {
+			t.Fatalf("sorted %v, want the moves %v", sl, want)
+		}
+	}
+	sl.Remove(0)
+	if len(sl) != 4 || sl[0].Mv != 2 {
+		t.Errorf("after Remove(0) got %v", sl)
+	}
+}
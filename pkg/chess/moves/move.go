@@ -30,9 +30,17 @@ func (m *Move) PackMove(fr, to, pc, cp, pr, epSq int, castl castlings.Castlings)
 		(epFile << EpShift) | int(castl<<CastlShift))
 }
 
-func (m *Move) PackEval(score int) {
-	(*m) &= Move(^EvalMask) // clear eval
-	(*m) |= Move(score+30000) << EvalShift
+// PackDrop packs the drop of pc from the pocket on to, in Crazyhouse. The 32 bits the
+// transposition table keeps of a move are all taken, so a drop is the move of pc from to to
+// to, a move no piece on the board can make
+func (m *Move) PackDrop(pc, to, epSq int, castl castlings.Castlings) {
+	m.PackMove(to, to, pc, Empty, Empty, epSq, castl)
+}
+
+// IsDrop tells if m drops a piece from the pocket. NoMove looks like a white pawn dropped on
+// a1, which is not a legal drop
+func (m Move) IsDrop() bool {
+	return m.Fr() == m.To() && m.Pc() != Empty && m != NoMove
 }
 
 // compare two moves - only frSq and toSq
@@ -47,12 +55,10 @@ func (m Move) CmpFrToP(m2 Move) bool {
 	return m.Fr() == m2.Fr() && m.To() == m2.To() && m.Pc() == m2.Pc()
 }
 
+// Cmp tells if m and m2 are the same move. The move ordering keeps its scores apart, in a
+// ScoredList
 func (m Move) Cmp(m2 Move) bool {
-	return (m & Move(^EvalMask)) == (m2 & Move(^EvalMask))
-}
-
-func (m Move) Eval() int {
-	return int((uint(m)&uint(EvalMask))>>EvalShift) - 30000
+	return m == m2
 }
 
 func (m Move) Fr() int {
@@ -95,18 +101,19 @@ func (m Move) Ep(sd Color) int {
 	return rank*8 + file - 1
 }
 
-// move without eval
-func (m Move) OnlyMv() Move {
-	return m & Move(^EvalMask)
-}
-
 func (m Move) String() string {
+	if m.IsDrop() {
+		return m.StringFull()
+	}
 	s := m.StringFull()
 	s = s[1:3] + s[5:]
 	return s
 }
 
 func (m Move) StringFull() string {
+	if m.IsDrop() {
+		return strings.ToUpper(Pc2Fen(m.Pc())) + "@" + Sq2Fen[m.To()]
+	}
 	fr := Sq2Fen[int(m.Fr())]
 	to := Sq2Fen[int(m.To())]
 	pc := Pc2Fen(int(m.Pc()))
@@ -20,20 +20,6 @@ func (ml *MoveList) Remove(ix int) {
 	}
 }
 
-// Sort is sorting the moves in the Score/Move list according to the score per move
-func (ml *MoveList) Sort() {
-	bSwap := true
-	for bSwap {
-		bSwap = false
-		for i := 0; i < len(*ml)-1; i++ {
-			if (*ml)[i+1].Eval() > (*ml)[i].Eval() {
-				(*ml)[i], (*ml)[i+1] = (*ml)[i+1], (*ml)[i]
-				bSwap = true
-			}
-		}
-	}
-}
-
 func (ml MoveList) String() string {
 	theString := ""
 	for _, mv := range ml {
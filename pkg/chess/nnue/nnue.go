@@ -0,0 +1,144 @@
+// Package nnue implements a small efficiently updatable neural network evaluation.
+//
+// The network is a 768->HiddenSize x2->1 perspective network. Each side has its own
+// accumulator holding the hidden layer before activation. The accumulators are updated
+// incrementally when a piece is put on or removed from a square, so an evaluation only
+// costs the output layer.
+//
+// A network file is the raw little-endian dump of Network, all values quantized:
+//
+//	FtWeights  [768][HiddenSize]int16  feature transformer weights, scaled by QA
+//	FtBiases   [HiddenSize]int16       feature transformer biases, scaled by QA
+//	OutWeights [2*HiddenSize]int16     output weights (side to move first), scaled by QB
+//	OutBias    int32                   output bias, scaled by QA*QB
+//
+// The feature index of a piece is pc*64+sq seen from white. For the black perspective
+// the colors are swapped and the board is mirrored vertically.
+package nnue
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+
+	. "github.com/Tecu23/go-game/pkg/chess/constants"
+)
+
+const (
+	Inputs     = NoPiecesC * 64 // one input for each piece on each square
+	HiddenSize = 256            // neurons in the hidden layer for each perspective
+	QA         = 255            // quantization of the feature transformer
+	QB         = 64             // quantization of the output layer
+	Scale      = 400            // network output to centipawns
+)
+
+// Network holds the quantized weights of the net
+type Network struct {
+	FtWeights  [Inputs][HiddenSize]int16
+	FtBiases   [HiddenSize]int16
+	OutWeights [2 * HiddenSize]int16
+	OutBias    int32
+}
+
+// Net is the network used by the evaluation. nil means that no network is loaded
+var Net *Network
+
+// Read reads a network in the format described in the package documentation
+func Read(r io.Reader) (*Network, error) {
+	net := &Network{}
+	if err := binary.Read(r, binary.LittleEndian, net); err != nil {
+		return nil, fmt.Errorf("reading network: %v", err)
+	}
+
+	// the file should end here
+	var extra [1]byte
+	if n, _ := r.Read(extra[:]); n != 0 {
+		return nil, fmt.Errorf("network file is larger than %v bytes", binary.Size(net))
+	}
+
+	return net, nil
+}
+
+// Load reads the network in the file at path and makes it the active network
+func Load(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	net, err := Read(f)
+	if err != nil {
+		return err
+	}
+
+	Net = net
+	return nil
+}
+
+// Unload turns off the neural network evaluation
+func Unload() {
+	Net = nil
+}
+
+// Accumulator keeps the hidden layer for the white and the black perspective
+type Accumulator [2][HiddenSize]int16
+
+// index returns the input index of pc on sq seen from the perspective of side sd
+func index(sd Color, pc, sq int) int {
+	if sd == BLACK {
+		pc ^= 0x1 // swap colors
+		sq ^= 56  // mirror ranks
+	}
+	return pc*64 + sq
+}
+
+// Reset sets both perspectives to the biases, which is the accumulator for an empty board
+func (a *Accumulator) Reset() {
+	a[WHITE] = Net.FtBiases
+	a[BLACK] = Net.FtBiases
+}
+
+// Add updates the accumulator with pc put on sq
+func (a *Accumulator) Add(pc, sq int) {
+	w := &Net.FtWeights[index(WHITE, pc, sq)]
+	bl := &Net.FtWeights[index(BLACK, pc, sq)]
+	for i := 0; i < HiddenSize; i++ {
+		a[WHITE][i] += w[i]
+		a[BLACK][i] += bl[i]
+	}
+}
+
+// Sub updates the accumulator with pc removed from sq
+func (a *Accumulator) Sub(pc, sq int) {
+	w := &Net.FtWeights[index(WHITE, pc, sq)]
+	bl := &Net.FtWeights[index(BLACK, pc, sq)]
+	for i := 0; i < HiddenSize; i++ {
+		a[WHITE][i] -= w[i]
+		a[BLACK][i] -= bl[i]
+	}
+}
+
+// Evaluate runs the output layer and returns the score in centipawns from stm pov
+func (a *Accumulator) Evaluate(stm Color) int {
+	us, them := &a[stm], &a[stm.Opposite()]
+	sum := int64(0)
+	for i := 0; i < HiddenSize; i++ {
+		sum += crelu(us[i]) * int64(Net.OutWeights[i])
+		sum += crelu(them[i]) * int64(Net.OutWeights[HiddenSize+i])
+	}
+
+	return int((sum + int64(Net.OutBias)) * Scale / (QA * QB))
+}
+
+// crelu is the clipped ReLU activation
+func crelu(x int16) int64 {
+	if x < 0 {
+		return 0
+	}
+	if x > QA {
+		return QA
+	}
+	return int64(x)
+}
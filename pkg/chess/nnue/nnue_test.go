@@ -0,0 +1,107 @@
+package nnue
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/rand"
+	"testing"
+
+	. "github.com/Tecu23/go-game/pkg/chess/constants"
+)
+
+// randomNet returns a network with small random weights
+func randomNet(seed int64) *Network {
+	rnd := rand.New(rand.NewSource(seed))
+	net := &Network{}
+	for ix := range net.FtWeights {
+		for j := range net.FtWeights[ix] {
+			net.FtWeights[ix][j] = int16(rnd.Intn(65) - 32)
+		}
+	}
+	for ix := range net.FtBiases {
+		net.FtBiases[ix] = int16(rnd.Intn(256))
+	}
+	for ix := range net.OutWeights {
+		net.OutWeights[ix] = int16(rnd.Intn(129) - 64)
+	}
+	net.OutBias = rnd.Int31n(10000)
+	return net
+}
+
+func TestRead(t *testing.T) {
+	want := randomNet(1)
+	var buf bytes.Buffer
+	if err := binary.Write(&buf, binary.LittleEndian, want); err != nil {
+		t.Fatal(err)
+	}
+	file := buf.Bytes()
+
+	got, err := Read(bytes.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *got != *want {
+		t.Errorf("the network read is not the network written")
+	}
+
+	if _, err := Read(bytes.NewReader(file[:len(file)-1])); err == nil {
+		t.Errorf("a file one byte short reads without an error")
+	}
+	if _, err := Read(bytes.NewReader(append(file, 0))); err == nil {
+		t.Errorf("a file one byte too long reads without an error")
+	}
+	if _, err := Read(bytes.NewReader(nil)); err == nil {
+		t.Errorf("an empty file reads without an error")
+	}
+}
+
+func TestAccumulator(t *testing.T) {
+	defer func(n *Network) { Net = n }(Net)
+	Net = randomNet(2)
+
+	var empty, a Accumulator
+	empty.Reset()
+	a.Reset()
+	a.Add(WK, E1)
+	a.Add(BQ, D8)
+	a.Sub(WK, E1)
+	a.Add(WP, E2)
+	a.Sub(BQ, D8)
+	a.Sub(WP, E2)
+	if a != empty {
+		t.Errorf("adding and removing pieces doesn't give the empty accumulator back")
+	}
+
+	// a white piece seen by white is the black piece on the mirrored square seen by black
+	var w, b Accumulator
+	w.Reset()
+	b.Reset()
+	w.Add(WN, G1)
+	b.Add(BN, G8)
+	if w[WHITE] != b[BLACK] || w[BLACK] != b[WHITE] {
+		t.Errorf("the perspectives are not mirrored")
+	}
+	if w.Evaluate(WHITE) != b.Evaluate(BLACK) {
+		t.Errorf("Evaluate is %v for white and %v for the mirrored black", w.Evaluate(WHITE), b.Evaluate(BLACK))
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	defer func(n *Network) { Net = n }(Net)
+	Net = &Network{}
+	for ix := range Net.FtBiases {
+		Net.FtBiases[ix] = 2 * QA // clipped to QA
+		Net.OutWeights[ix] = 1    // side to move only
+	}
+
+	var a Accumulator
+	a.Reset()
+	if got, want := a.Evaluate(WHITE), HiddenSize*Scale/QB; got != want {
+		t.Errorf("Evaluate = %v, want %v", got, want)
+	}
+
+	a[BLACK] = [HiddenSize]int16{} // crelu(0) is 0
+	if got := a.Evaluate(BLACK); got != 0 {
+		t.Errorf("Evaluate = %v for black, want 0", got)
+	}
+}
@@ -0,0 +1,410 @@
+// Package pgn reads and writes chess games in Portable Game Notation.
+//
+// A Reader returns the games of a PGN stream one by one with their tags, the moves of the
+// main line and, attached to the moves, the comments, NAGs and variations. Replay plays the
+// main line on a board and Write writes a game back in export format.
+package pgn
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	. "github.com/Tecu23/go-game/pkg/chess/constants"
+	"github.com/Tecu23/go-game/pkg/chess/moves"
+	"github.com/Tecu23/go-game/pkg/chess/position"
+)
+
+// the game termination markers
+const (
+	WhiteWins = "1-0"
+	BlackWins = "0-1"
+	Draw      = "1/2-1/2"
+	Unknown   = "*"
+)
+
+// Tag is a tag pair like [Event "Casual game"]
+type Tag struct {
+	Name, Value string
+}
+
+// Move is a move in the movetext with what is written around it
+type Move struct {
+	SAN        string     // as written, with + and # but without annotations
+	Mv         moves.Move // set by Replay, main line only
+	NAGs       []int      // !, ?, !!, ??, !? and ?! are read as NAG 1 to 6
+	Before     string     // comment in front of the first move of a line
+	Comment    string     // comment after the move
+	Variations [][]Move   // alternatives to this move
+}
+
+// Game is one game of a PGN file
+type Game struct {
+	Number int   // the number of the game in the file, from 1
+	Tags   []Tag // in the order they were read
+	Moves  []Move
+	Result string // the termination marker, from the Result tag if the movetext has none
+}
+
+// Tag returns the value of the tag name, or "" if the game doesn't have it
+func (g *Game) Tag(name string) string {
+	for _, t := range g.Tags {
+		if t.Name == name {
+			return t.Value
+		}
+	}
+	return ""
+}
+
+// SetTag sets the value of the tag name, adding the tag if it is missing
+func (g *Game) SetTag(name, value string) {
+	for ix := range g.Tags {
+		if g.Tags[ix].Name == name {
+			g.Tags[ix].Value = value
+			return
+		}
+	}
+	g.Tags = append(g.Tags, Tag{name, value})
+}
+
+// Board returns the position the game starts from, the FEN tag or the start position
+func (g *Game) Board() (*position.BoardStruct, error) {
+	fen := g.Tag("FEN")
+	if fen == "" {
+		fen = Startpos
+	}
+	b, err := position.ParseFEN(fen)
+	if err != nil {
+		return nil, fmt.Errorf("game %v: %v", g.Number, err)
+	}
+	return b, nil
+}
+
+// MoveError tells which move of which game couldn't be played
+type MoveError struct {
+	Game int // the number of the game
+	Ply  int // the ply of the move in the game, from 1
+	SAN  string
+	Err  error
+}
+
+func (e *MoveError) Error() string {
+	return fmt.Sprintf("game %v, ply %v: %v", e.Game, e.Ply, e.Err)
+}
+
+func (e *MoveError) Unwrap() error {
+	return e.Err
+}
+
+// Replay plays the main line from the start position of the game and sets Mv of its moves.
+// fn, if not nil, is called with the board before each move and the index of the move in
+// g.Moves; replaying stops when it returns false. The board is returned in the position
+// reached. If a move is not legal a *MoveError is returned with the board before that move
+func (g *Game) Replay(fn func(b *position.BoardStruct, ix int) bool) (*position.BoardStruct, error) {
+	b, err := g.Board()
+	if err != nil {
+		return nil, err
+	}
+
+	for ix := range g.Moves {
+		mv, err := b.ParseSAN(g.Moves[ix].SAN)
+		if err != nil {
+			return b, &MoveError{Game: g.Number, Ply: ix + 1, SAN: g.Moves[ix].SAN, Err: err}
+		}
+		g.Moves[ix].Mv = mv
+		if fn != nil && !fn(b, ix) {
+			break
+		}
+		b.Move(mv)
+	}
+	return b, nil
+}
+
+// Reader reads the games of a PGN stream
+type Reader struct {
+	sc      *bufio.Scanner
+	pending string // the first line of the next game
+	cnt     int
+}
+
+// NewReader returns a Reader reading from r
+func NewReader(r io.Reader) *Reader {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	return &Reader{sc: sc}
+}
+
+// Next returns the next game, or io.EOF when there are no more games
+func (rd *Reader) Next() (*Game, error) {
+	g := &Game{}
+	var text strings.Builder
+	inMoves, comment := false, false
+	var tagErr error
+
+	for {
+		line := rd.pending
+		rd.pending = ""
+		if line == "" {
+			if !rd.sc.Scan() {
+				break
+			}
+			line = rd.sc.Text()
+		}
+		if strings.HasPrefix(line, "%") { // escape line
+			continue
+		}
+
+		trimmed := strings.TrimSpace(line)
+		if !comment && strings.HasPrefix(trimmed, "[") {
+			if inMoves { // the tags of the next game
+				rd.pending = line
+				break
+			}
+			if err := parseTags(trimmed, g); err != nil && tagErr == nil {
+				tagErr = err // the rest of the game is still read
+			}
+			continue
+		}
+
+		if trimmed == "" {
+			continue
+		}
+		inMoves = true
+		text.WriteString(line)
+		text.WriteString("\n")
+		comment = openComment(line, comment)
+	}
+	if err := rd.sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(g.Tags) == 0 && !inMoves && tagErr == nil {
+		return nil, io.EOF
+	}
+
+	rd.cnt++
+	g.Number = rd.cnt
+	if tagErr != nil {
+		return nil, fmt.Errorf("game %v: %v", g.Number, tagErr)
+	}
+	p := &parser{toks: tokenize(text.String())}
+	g.Moves = p.line(0)
+	if p.err != nil {
+		return nil, fmt.Errorf("game %v: %v", g.Number, p.err)
+	}
+	g.Result = p.result
+	if g.Result == "" {
+		g.Result = g.Tag("Result")
+	}
+	if g.Result == "" {
+		g.Result = Unknown
+	}
+	return g, nil
+}
+
+// openComment tells if a {} comment is still open at the end of line
+func openComment(line string, comment bool) bool {
+	for _, c := range line {
+		switch {
+		case comment:
+			comment = c != '}'
+		case c == '{':
+			comment = true
+		case c == ';': // the rest of the line is a comment
+			return false
+		}
+	}
+	return comment
+}
+
+// parseTags reads the tag pairs of a line into g
+func parseTags(line string, g *Game) error {
+	for line != "" {
+		if line[0] != '[' {
+			return fmt.Errorf("invalid tag %v", line)
+		}
+		name, rest, _ := strings.Cut(strings.TrimSpace(line[1:]), " ")
+		rest = strings.TrimSpace(rest)
+		if name == "" || !strings.HasPrefix(rest, `"`) {
+			return fmt.Errorf("invalid tag %v", line)
+		}
+
+		var val strings.Builder
+		ix, done := 1, false
+		for ; ix < len(rest) && !done; ix++ {
+			switch rest[ix] {
+			case '\\':
+				if ix+1 < len(rest) {
+					ix++
+				}
+				val.WriteByte(rest[ix])
+			case '"':
+				done = true
+			default:
+				val.WriteByte(rest[ix])
+			}
+		}
+		rest = strings.TrimSpace(rest[ix:])
+		if !done || !strings.HasPrefix(rest, "]") {
+			return fmt.Errorf("invalid tag %v", line)
+		}
+
+		g.SetTag(name, val.String())
+		line = strings.TrimSpace(rest[1:])
+	}
+	return nil
+}
+
+// the kinds of movetext tokens
+const (
+	tokMove = iota
+	tokComment
+	tokNAG
+	tokOpen
+	tokClose
+	tokResult
+)
+
+type token struct {
+	kind int
+	text string
+	nag  int
+}
+
+// suffixNAGs are the move suffix annotations and their NAGs
+var suffixNAGs = map[string]int{"!": 1, "?": 2, "!!": 3, "??": 4, "!?": 5, "?!": 6}
+
+// tokenize splits movetext into tokens. Move numbers are left out
+func tokenize(text string) []token {
+	var toks []token
+	for ix := 0; ix < len(text); {
+		c := text[ix]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			ix++
+		case c == '{':
+			end := strings.IndexByte(text[ix:], '}')
+			if end < 0 {
+				end = len(text) - ix
+			}
+			toks = append(toks, token{kind: tokComment, text: strings.Join(strings.Fields(text[ix+1:ix+end]), " ")})
+			ix += end + 1
+		case c == ';':
+			end := strings.IndexByte(text[ix:], '\n')
+			if end < 0 {
+				end = len(text) - ix
+			}
+			toks = append(toks, token{kind: tokComment, text: strings.TrimSpace(text[ix+1 : ix+end])})
+			ix += end
+		case c == '(':
+			toks = append(toks, token{kind: tokOpen})
+			ix++
+		case c == ')':
+			toks = append(toks, token{kind: tokClose})
+			ix++
+		default:
+			end := ix + 1
+			for end < len(text) && !strings.ContainsRune(" \t\n\r{};()", rune(text[end])) {
+				end++
+			}
+			toks = append(toks, symbolTokens(text[ix:end])...)
+			ix = end
+		}
+	}
+	return toks
+}
+
+// symbolTokens returns the tokens of a symbol: a NAG, a result, or a move maybe with a
+// move number in front and annotations behind
+func symbolTokens(sym string) []token {
+	if sym[0] == '$' {
+		nag, _ := strconv.Atoi(sym[1:])
+		return []token{{kind: tokNAG, nag: nag}}
+	}
+	switch sym {
+	case WhiteWins, BlackWins, Draw, Unknown:
+		return []token{{kind: tokResult, text: sym}}
+	}
+
+	if i := strings.LastIndexByte(sym, '.'); i >= 0 { // 12. 12... and 1.e4
+		sym = sym[i+1:]
+	}
+	san := strings.TrimRight(sym, "!?")
+	suffix := sym[len(san):]
+
+	var toks []token
+	if san != "" {
+		toks = append(toks, token{kind: tokMove, text: san})
+	}
+	if nag, ok := suffixNAGs[suffix]; ok {
+		toks = append(toks, token{kind: tokNAG, nag: nag})
+	}
+	return toks
+}
+
+type parser struct {
+	toks   []token
+	pos    int
+	result string
+	err    error
+}
+
+// line reads the moves of a line up to the end of the variation or of the movetext
+func (p *parser) line(depth int) []Move {
+	var mvs []Move
+	before := ""
+	for p.pos < len(p.toks) {
+		tok := p.toks[p.pos]
+		p.pos++
+
+		switch tok.kind {
+		case tokMove:
+			mvs = append(mvs, Move{SAN: tok.text, Before: before})
+			before = ""
+		case tokComment:
+			switch {
+			case len(mvs) == 0:
+				before = joinComment(before, tok.text)
+			default:
+				mvs[len(mvs)-1].Comment = joinComment(mvs[len(mvs)-1].Comment, tok.text)
+			}
+		case tokNAG:
+			if len(mvs) > 0 {
+				mvs[len(mvs)-1].NAGs = append(mvs[len(mvs)-1].NAGs, tok.nag)
+			}
+		case tokOpen:
+			vari := p.line(depth + 1)
+			if len(mvs) == 0 {
+				if p.err == nil {
+					p.err = fmt.Errorf("variation without a move before it")
+				}
+				continue
+			}
+			mvs[len(mvs)-1].Variations = append(mvs[len(mvs)-1].Variations, vari)
+		case tokClose:
+			if depth > 0 {
+				return mvs
+			}
+			if p.err == nil {
+				p.err = fmt.Errorf("unbalanced )")
+			}
+		case tokResult:
+			if depth == 0 {
+				p.result = tok.text
+				return mvs
+			}
+		}
+	}
+	if depth > 0 && p.err == nil {
+		p.err = fmt.Errorf("unterminated variation")
+	}
+	return mvs
+}
+
+func joinComment(c, text string) string {
+	if c == "" {
+		return text
+	}
+	return c + " " + text
+}
@@ -0,0 +1,278 @@
+package pgn
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Tecu23/go-game/pkg/chess/castlings"
+	. "github.com/Tecu23/go-game/pkg/chess/constants"
+	"github.com/Tecu23/go-game/pkg/chess/magic"
+	"github.com/Tecu23/go-game/pkg/chess/position"
+)
+
+func TestMain(m *testing.M) {
+	InitFen2Sq()
+	magic.InitMagic()
+	position.InitKeys()
+	position.InitAtksKings()
+	position.InitAtksKnights()
+	castlings.InitCastlings()
+	position.PcSqInit()
+
+	os.Exit(m.Run())
+}
+
+const games = `% a comment line for the tools
+[Event "Casual \"blitz\" game"]
+[Site "Berlin GER"]
+[Date "1852.??.??"]
+[White "Anderssen, Adolf"]
+[Black "Dufresne, Jean"]
+[Result "1-0"]
+[ECO "C52"]
+
+{The Evergreen game} 1.e4 e5 2.Nf3 Nc6 3.Bc4 Bc5 4.b4!? Bxb4 5.c3 Ba5 6.d4 exd4
+7.O-O d3 8.Qb3 Qf6 9.e5 Qg6 10.Re1 Nge7 11.Ba3 b5 $6 12.Qxb5 Rb8 13.Qa4 Bb6
+14.Nbd2 Bb7 15.Ne4 Qf5? (15... d5) 16.Bxd3 Qh5 17.Nf6+ gxf6 18.exf6 Rg8 19.Rad1!
+Qxf3 20.Rxe7+ Nxe7 21.Qxd7+ Kxd7 22.Bf5+ Ke8 23.Bd7+ Kf8 24.Bxe7# 1-0
+
+[Event "?"]
+[FEN "4k3/8/8/8/8/8/4P3/4K3 b - - 0 40"]
+[SetUp "1"]
+
+40... Kd7 41. e4 ; rest of line comment
+Ke6 (41... Kc6 {a long comment over
+two lines} 42. Kd2 (42. e5) 42... Kd6) 42. Kf2 *
+
+[Event "no result"]
+
+1. d4 d5 2. c4
+`
+
+func readAll(t *testing.T, text string) []*Game {
+	t.Helper()
+	rd := NewReader(strings.NewReader(text))
+	var gs []*Game
+	for {
+		g, err := rd.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		gs = append(gs, g)
+	}
+	return gs
+}
+
+func sans(mvs []Move) string {
+	var s []string
+	for _, m := range mvs {
+		s = append(s, m.SAN)
+	}
+	return strings.Join(s, " ")
+}
+
+func TestReader(t *testing.T) {
+	gs := readAll(t, games)
+	if len(gs) != 3 {
+		t.Fatalf("got %v games, want 3", len(gs))
+	}
+
+	g := gs[0]
+	if g.Number != 1 || g.Result != WhiteWins || len(g.Moves) != 47 {
+		t.Errorf("game 1: number %v, result %v, %v moves", g.Number, g.Result, len(g.Moves))
+	}
+	if got := g.Tag("Event"); got != `Casual "blitz" game` {
+		t.Errorf("got Event %v", got)
+	}
+	if got := g.Tag("ECO"); got != "C52" {
+		t.Errorf("got ECO %v", got)
+	}
+	if g.Moves[0].Before != "The Evergreen game" {
+		t.Errorf("got comment before the first move %q", g.Moves[0].Before)
+	}
+	if m := g.Moves[6]; m.SAN != "b4" || !reflect.DeepEqual(m.NAGs, []int{5}) {
+		t.Errorf("got %v %v, want b4 with NAG 5", m.SAN, m.NAGs)
+	}
+	if m := g.Moves[21]; m.SAN != "b5" || !reflect.DeepEqual(m.NAGs, []int{6}) {
+		t.Errorf("got %v %v, want b5 with NAG 6", m.SAN, m.NAGs)
+	}
+	if m := g.Moves[29]; m.SAN != "Qf5" || len(m.Variations) != 1 || sans(m.Variations[0]) != "d5" {
+		t.Errorf("got %v with variations %v", m.SAN, m.Variations)
+	}
+	if m := g.Moves[32]; m.SAN != "Nf6+" {
+		t.Errorf("got %v, want Nf6+", m.SAN)
+	}
+
+	g = gs[1]
+	if g.Result != Unknown || sans(g.Moves) != "Kd7 e4 Ke6 Kf2" {
+		t.Errorf("game 2: result %v, moves %v", g.Result, sans(g.Moves))
+	}
+	if g.Moves[1].Comment != "rest of line comment" {
+		t.Errorf("got comment %q", g.Moves[1].Comment)
+	}
+	v := g.Moves[2].Variations
+	if len(v) != 1 || sans(v[0]) != "Kc6 Kd2 Kd6" || v[0][0].Comment != "a long comment over two lines" {
+		t.Fatalf("got variations %+v", v)
+	}
+	if len(v[0][1].Variations) != 1 || sans(v[0][1].Variations[0]) != "e5" {
+		t.Errorf("got nested variations %+v", v[0][1].Variations)
+	}
+
+	g = gs[2]
+	if g.Number != 3 || g.Result != Unknown || sans(g.Moves) != "d4 d5 c4" {
+		t.Errorf("game 3: number %v, result %v, moves %v", g.Number, g.Result, sans(g.Moves))
+	}
+}
+
+func TestReaderErrors(t *testing.T) {
+	for _, text := range []string{
+		"[Event \"x\"\n\n1. e4 *",
+		"[Event x]\n\n1. e4 *",
+		"1. e4 (1. d4 *",
+		"1. e4 e5) *",
+		"(1. e4) *",
+	} {
+		rd := NewReader(strings.NewReader(text + "\n\n[Event \"next\"]\n\n1. d4 *\n"))
+		if _, err := rd.Next(); err == nil {
+			t.Errorf("%q: no error", text)
+		}
+		// the next game is read fine
+		if g, err := rd.Next(); err != nil || g.Number != 2 || g.Tag("Event") != "next" {
+			t.Errorf("%q: got %+v %v after it", text, g, err)
+		}
+	}
+}
+
+func TestReplay(t *testing.T) {
+	gs := readAll(t, games)
+	b, err := gs[0].Replay(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := b.FEN(), "1r3kr1/pbpBBp1p/1b3P2/8/8/2P2q2/P4PPP/3R2K1 b - - 0 24"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if mv := gs[0].Moves[0].Mv; Sq2Fen[mv.Fr()]+Sq2Fen[mv.To()] != "e2e4" {
+		t.Errorf("got first move %v", mv)
+	}
+
+	b, err = gs[1].Replay(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := b.FEN(), "8/8/4k3/8/4P3/8/5K2/8 b - - 2 42"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// stop before the fourth ply
+	b, err = gs[0].Replay(func(b *position.BoardStruct, ix int) bool { return ix < 3 })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := b.FEN(), "rnbqkbnr/pppp1ppp/8/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R b KQkq - 1 2"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestReplayError(t *testing.T) {
+	text := "[Event \"1\"]\n\n1. e4 e5 *\n\n[Event \"2\"]\n\n1. e4 e5 2. Nf3 Nf6 3. Nxe5 Nxe5 *\n"
+	gs := readAll(t, text)
+	if _, err := gs[0].Replay(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := gs[1].Replay(nil)
+	var me *MoveError
+	if !errors.As(err, &me) {
+		t.Fatalf("got %v, want a MoveError", err)
+	}
+	if me.Game != 2 || me.Ply != 6 || me.SAN != "Nxe5" {
+		t.Errorf("got game %v ply %v %v, want game 2 ply 6 Nxe5", me.Game, me.Ply, me.SAN)
+	}
+	if !strings.HasPrefix(err.Error(), "game 2, ply 6: ") {
+		t.Errorf("got %v", err)
+	}
+	if got, want := b.FEN(), "rnbqkb1r/pppp1ppp/5n2/4N3/4P3/8/PPPP1PPP/RNBQKB1R b KQkq - 0 3"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	g := &Game{Number: 7, Tags: []Tag{{"FEN", "8/8/8/8/8/8/8/8 w - - 0 1"}}}
+	if _, err := g.Replay(nil); err == nil || !strings.HasPrefix(err.Error(), "game 7: ") {
+		t.Errorf("got %v, want an error for the FEN", err)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	gs := readAll(t, games)
+
+	var buf bytes.Buffer
+	for _, g := range gs {
+		if err := Write(&buf, g); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := `[Event "Casual \"blitz\" game"]
+[Site "Berlin GER"]
+[Date "1852.??.??"]
+[Round "?"]
+[White "Anderssen, Adolf"]
+[Black "Dufresne, Jean"]
+[Result "1-0"]
+[ECO "C52"]
+
+{The Evergreen game} 1. e4 e5 2. Nf3 Nc6 3. Bc4 Bc5 4. b4 $5 Bxb4 5. c3 Ba5
+6. d4 exd4 7. O-O d3 8. Qb3 Qf6 9. e5 Qg6 10. Re1 Nge7 11. Ba3 b5 $6 12. Qxb5
+Rb8 13. Qa4 Bb6 14. Nbd2 Bb7 15. Ne4 Qf5 $2 (15... d5) 16. Bxd3 Qh5 17. Nf6+
+gxf6 18. exf6 Rg8 19. Rad1 $1 Qxf3 20. Rxe7+ Nxe7 21. Qxd7+ Kxd7 22. Bf5+ Ke8
+23. Bd7+ Kf8 24. Bxe7# 1-0
+
+[Event "?"]
+[Site "?"]
+[Date "????.??.??"]
+[Round "?"]
+[White "?"]
+[Black "?"]
+[Result "*"]
+[FEN "4k3/8/8/8/8/8/4P3/4K3 b - - 0 40"]
+[SetUp "1"]
+
+40... Kd7 41. e4 {rest of line comment} 41... Ke6 (41... Kc6 {a long comment
+over two lines} 42. Kd2 (42. e5) 42... Kd6) 42. Kf2 *
+
+[Event "no result"]
+[Site "?"]
+[Date "????.??.??"]
+[Round "?"]
+[White "?"]
+[Black "?"]
+[Result "*"]
+
+1. d4 d5 2. c4 *
+
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got\n%v\nwant\n%v", got, want)
+	}
+	for _, l := range strings.Split(buf.String(), "\n") {
+		if len(l) > lineLen {
+			t.Errorf("line longer than %v: %v", lineLen, l)
+		}
+	}
+
+	// and read back the same
+	again := readAll(t, buf.String())
+	for ix := range gs {
+		if !reflect.DeepEqual(gs[ix].Moves, again[ix].Moves) || gs[ix].Result != again[ix].Result {
+			t.Errorf("game %v is not the same after writing it", ix+1)
+		}
+	}
+}
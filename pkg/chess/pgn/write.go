@@ -0,0 +1,178 @@
+package pgn
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// lineLen is the maximum length of the movetext lines in export format
+const lineLen = 79
+
+// roster is the seven tag roster with the values used for missing tags. Its tags are
+// written first and in this order
+var roster = []Tag{
+	{"Event", "?"},
+	{"Site", "?"},
+	{"Date", "????.??.??"},
+	{"Round", "?"},
+	{"White", "?"},
+	{"Black", "?"},
+	{"Result", Unknown},
+}
+
+// Write writes g in export format: the seven tag roster, the other tags in their order,
+// and the movetext with its comments, NAGs and variations in lines of at most 79 characters
+func Write(w io.Writer, g *Game) error {
+	result := g.Result
+	if result == "" {
+		result = Unknown
+	}
+
+	bw := bufio.NewWriter(w)
+	for _, t := range roster {
+		val := g.Tag(t.Name)
+		switch {
+		case t.Name == "Result":
+			val = result
+		case val == "":
+			val = t.Value
+		}
+		writeTag(bw, t.Name, val)
+	}
+	for _, t := range g.Tags {
+		if !inRoster(t.Name) {
+			writeTag(bw, t.Name, t.Value)
+		}
+	}
+	bw.WriteString("\n")
+
+	var mt movetext
+	mt.line(g.Moves, startPly(g.Tag("FEN")))
+	mt.add(result)
+	for _, l := range mt.wrap() {
+		bw.WriteString(l)
+		bw.WriteString("\n")
+	}
+	bw.WriteString("\n")
+	return bw.Flush()
+}
+
+func inRoster(name string) bool {
+	for _, t := range roster {
+		if t.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func writeTag(w *bufio.Writer, name, val string) {
+	val = strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(val)
+	fmt.Fprintf(w, "[%v \"%v\"]\n", name, val)
+}
+
+// startPly returns the ply of the first move from the side to move and the full move
+// counter of the FEN, 0 for white's first move
+func startPly(fen string) int {
+	f := strings.Fields(fen)
+	ply := 0
+	if len(f) >= 6 {
+		if n, err := strconv.Atoi(f[5]); err == nil && n > 0 {
+			ply = 2 * (n - 1)
+		}
+	}
+	if len(f) >= 2 && f[1] == "b" {
+		ply++
+	}
+	return ply
+}
+
+// movetext collects the tokens of the movetext
+type movetext struct {
+	toks []string
+	open bool // the next token gets a ( in front
+}
+
+func (mt *movetext) add(tok string) {
+	if mt.open {
+		tok = "(" + tok
+		mt.open = false
+	}
+	mt.toks = append(mt.toks, tok)
+}
+
+// comment adds the words of a comment, so that long comments are wrapped too
+func (mt *movetext) comment(text string) {
+	words := strings.Fields(text)
+	if len(words) == 0 {
+		mt.add("{}")
+		return
+	}
+	words[0] = "{" + words[0]
+	words[len(words)-1] += "}"
+	for _, w := range words {
+		mt.add(w)
+	}
+}
+
+// line adds the moves of a line starting at ply
+func (mt *movetext) line(mvs []Move, ply int) {
+	needNum := true // black moves need their number after a comment or variation
+	for ix, m := range mvs {
+		p := ply + ix
+		if m.Before != "" {
+			mt.comment(m.Before)
+			needNum = true
+		}
+		switch { // the number stays on the line of its move
+		case p%2 == 0:
+			mt.add(fmt.Sprintf("%v. %v", p/2+1, m.SAN))
+		case needNum:
+			mt.add(fmt.Sprintf("%v... %v", p/2+1, m.SAN))
+		default:
+			mt.add(m.SAN)
+		}
+		needNum = false
+
+		for _, nag := range m.NAGs {
+			mt.add(fmt.Sprintf("$%v", nag))
+		}
+		if m.Comment != "" {
+			mt.comment(m.Comment)
+			needNum = true
+		}
+		for _, v := range m.Variations {
+			mt.open = true
+			mt.line(v, p)
+			if mt.open { // an empty variation
+				mt.add(")")
+			} else {
+				mt.toks[len(mt.toks)-1] += ")"
+			}
+			needNum = true
+		}
+	}
+}
+
+// wrap joins the tokens in lines of at most lineLen characters
+func (mt *movetext) wrap() []string {
+	var lines []string
+	var l strings.Builder
+	for _, tok := range mt.toks {
+		if l.Len() > 0 && l.Len()+1+len(tok) > lineLen {
+			lines = append(lines, l.String())
+			l.Reset()
+		}
+		if l.Len() > 0 {
+			l.WriteString(" ")
+		}
+		l.WriteString(tok)
+	}
+	if l.Len() > 0 {
+		lines = append(lines, l.String())
+	}
+	return lines
+}
@@ -0,0 +1,119 @@
+package position
+
+import (
+	"math/rand"
+	"testing"
+
+	. "github.com/Tecu23/go-game/pkg/chess/constants"
+	"github.com/Tecu23/go-game/pkg/chess/moves"
+	"github.com/Tecu23/go-game/pkg/chess/nnue"
+)
+
+// freshAcc returns the accumulator of b computed from scratch
+func freshAcc(b *BoardStruct) nnue.Accumulator {
+	c := *b
+	c.RefreshAcc()
+	return c.Acc
+}
+
+// TestAccUpdates plays random games and checks after every move, unmove, null move and
+// undo that the incrementally updated accumulator is the one RefreshAcc computes
+func TestAccUpdates(t *testing.T) {
+	defer func(n *nnue.Network) { nnue.Net = n }(nnue.Net)
+	rnd := rand.New(rand.NewSource(5))
+	nnue.Net = &nnue.Network{}
+	for ix := range nnue.Net.FtWeights {
+		for j := range nnue.Net.FtWeights[ix] {
+			nnue.Net.FtWeights[ix][j] = int16(rnd.Intn(65) - 32)
+		}
+	}
+
+	type seed struct {
+		v   Variant
+		fen string
+	}
+	seeds := []seed{{Crazyhouse, "r1bqkb1r/pppp1ppp/2n2n2/4p3/2B1P3/5N2/PPPP1PPP/RNBQK2R[Pp] w KQkq - 0 1"}}
+	for _, fen := range fenSeeds {
+		seeds = append(seeds, seed{Standard, fen})
+	}
+
+	var cnt struct{ capt, promo, castl, ep, drop int }
+	for _, s := range seeds {
+		b, err := ParseFENVariant(s.fen, s.v, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b.Acc != freshAcc(b) {
+			t.Fatalf("%v: the accumulator is wrong after ParseFEN", s.fen)
+		}
+
+		for ply := 0; ply < 60; ply++ {
+			before := b.Acc
+			var ml moves.MoveList
+			b.GenAllMoves(&ml)
+			var legal []moves.Move
+			for _, mv := range ml {
+				isEp := mv.Pc()>>1 == Pawn && mv.To() == b.Ep && b.Ep != 0 && mv.Fr() != mv.To()
+				isCastl := b.IsCastling(mv)
+				if !b.Move(mv) {
+					continue
+				}
+				legal = append(legal, mv)
+				switch {
+				case mv.IsDrop():
+					cnt.drop++
+				case isCastl:
+					cnt.castl++
+				case isEp:
+					cnt.ep++
+				case mv.Cp() != Empty:
+					cnt.capt++
+				}
+				if mv.Pr() != Empty {
+					cnt.promo++
+				}
+				if b.Acc != freshAcc(b) {
+					t.Fatalf("%v: the accumulator is wrong after %v", b.FEN(), mv)
+				}
+				b.Unmove(mv)
+				if b.Acc != before {
+					t.Fatalf("%v: the accumulator is wrong after %v and Unmove", b.FEN(), mv)
+				}
+			}
+			if len(legal) == 0 {
+				break
+			}
+
+			b.MoveNull()
+			if b.Acc != before {
+				t.Fatalf("%v: the accumulator changed with a null move", b.FEN())
+			}
+			b.UndoNull()
+
+			b.Move(legal[rnd.Intn(len(legal))])
+		}
+	}
+
+	if cnt.capt == 0 || cnt.promo == 0 || cnt.castl == 0 || cnt.ep == 0 || cnt.drop == 0 {
+		t.Errorf("not all kinds of moves were played: %+v", cnt)
+	}
+
+	// a clone of a board set up before the net was loaded
+	net := nnue.Net
+	nnue.Net = nil
+	b, err := ParseFEN(fenSeeds[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	nnue.Net = net
+	if c := b.Clone(); c.Acc != freshAcc(c) {
+		t.Errorf("the accumulator of a clone is stale")
+	}
+
+	// a clone of a board set up with the same net copies the accumulator
+	b.RefreshAcc()
+	b.Acc[WHITE][0]++
+	if c := b.Clone(); c.Acc != b.Acc {
+		t.Errorf("the clone recomputed the accumulator instead of copying it")
+	}
+}
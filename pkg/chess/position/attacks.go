@@ -0,0 +1,195 @@
+package position
+
+import (
+	"github.com/Tecu23/go-game/pkg/chess/bitboard"
+	. "github.com/Tecu23/go-game/pkg/chess/constants"
+	"github.com/Tecu23/go-game/pkg/chess/magic"
+	"github.com/Tecu23/go-game/pkg/chess/moves"
+)
+
+// PawnAtks returns the squares a pawn of sd on sq attacks
+func PawnAtks(sd Color, sq int) bitboard.BitBoard {
+	sqBB := bitboard.BitBoard(1) << uint(sq)
+	if sd == WHITE {
+		return sqBB.NorthWest() | sqBB.NorthEast()
+	}
+	return sqBB.SouthWest() | sqBB.SouthEast()
+}
+
+// AttackersOcc returns the pieces of both sides that attack sq when the occupied squares are
+// occ. Sliders see through the squares not in occ, like in SEE when the front attackers are gone
+func (b *BoardStruct) AttackersOcc(sq int, occ bitboard.BitBoard) bitboard.BitBoard {
+	diag := b.PieceBB[Bishop] | b.PieceBB[Queen]
+	line := b.PieceBB[Rook] | b.PieceBB[Queen]
+	atkBB := PawnAtks(BLACK, sq)&b.PieceBB[Pawn]&b.WbBB[WHITE] |
+		PawnAtks(WHITE, sq)&b.PieceBB[Pawn]&b.WbBB[BLACK] |
+		AtksKnights[sq]&b.PieceBB[Knight] |
+		AtksKings[sq]&b.PieceBB[King] |
+		magic.BishopAtks(sq, occ)&diag |
+		magic.RookAtks(sq, occ)&line
+	return atkBB & occ
+}
+
+// Attackers returns the pieces of sd that attack sq
+func (b *BoardStruct) Attackers(sq int, sd Color) bitboard.BitBoard {
+	return b.AttackersOcc(sq, b.AllBB()) & b.WbBB[sd]
+}
+
+// AttacksFrom returns the squares the piece on sq attacks, empty or not. Empty for an empty sq
+func (b *BoardStruct) AttacksFrom(sq int) bitboard.BitBoard {
+	pc := b.Squares[sq]
+	if pc == Empty {
+		return 0
+	}
+	return pieceAtks(Pc2pt(pc), PcColor(pc), sq, b.AllBB())
+}
+
+// pieceAtks returns the squares a piece of type pt and side sd on sq attacks with the
+// squares in occ occupied
+func pieceAtks(pt int, sd Color, sq int, occ bitboard.BitBoard) bitboard.BitBoard {
+	switch pt {
+	case Pawn:
+		return PawnAtks(sd, sq)
+	case Knight:
+		return AtksKnights[sq]
+	case Bishop:
+		return magic.BishopAtks(sq, occ)
+	case Rook:
+		return magic.RookAtks(sq, occ)
+	case Queen:
+		return magic.BishopAtks(sq, occ) | magic.RookAtks(sq, occ)
+	case King:
+		return AtksKings[sq]
+	}
+	return 0
+}
+
+// XrayAttackers returns the bishops, rooks and queens of sd that attack sq through exactly
+// one piece of either side
+func (b *BoardStruct) XrayAttackers(sq int, sd Color) bitboard.BitBoard {
+	occ := b.AllBB()
+	diag := (b.PieceBB[Bishop] | b.PieceBB[Queen]) & b.WbBB[sd]
+	line := (b.PieceBB[Rook] | b.PieceBB[Queen]) & b.WbBB[sd]
+
+	bAtks := magic.BishopAtks(sq, occ)
+	rAtks := magic.RookAtks(sq, occ)
+	xray := (magic.BishopAtks(sq, occ&^bAtks) &^ bAtks) & diag
+	xray |= (magic.RookAtks(sq, occ&^rAtks) &^ rAtks) & line
+	return xray
+}
+
+// Checkers returns the pieces that give check to the side to move. None if the king is a
+// common piece in the variant
+func (b *BoardStruct) Checkers() bitboard.BitBoard {
+	if !b.Variant.Royal() {
+		return 0
+	}
+	return b.Attackers(b.King[b.Stm], b.Stm.Opposite())
+}
+
+// Pinned returns the pieces of sd that are pinned to their king
+func (b *BoardStruct) Pinned(sd Color) bitboard.BitBoard {
+	pinned, _ := b.pins(sd)
+	return pinned
+}
+
+// Pinners returns the pieces of the other side that pin a piece of sd to its king
+func (b *BoardStruct) Pinners(sd Color) bitboard.BitBoard {
+	_, pinners := b.pins(sd)
+	return pinners
+}
+
+// pins returns the pieces of sd pinned to their king and the pieces that pin them. A slider
+// pins the only piece between it and the king if that piece is of sd
+func (b *BoardStruct) pins(sd Color) (pinned, pinners bitboard.BitBoard) {
+	if !b.Variant.Royal() {
+		return 0, 0
+	}
+	king := b.King[sd]
+	them := b.WbBB[sd.Opposite()]
+	snipers := magic.BishopAtks(king, 0)&(b.PieceBB[Bishop]|b.PieceBB[Queen])&them |
+		magic.RookAtks(king, 0)&(b.PieceBB[Rook]|b.PieceBB[Queen])&them
+
+	occ := b.AllBB()
+	for sq := snipers.FirstOne(); sq != 64; sq = snipers.FirstOne() {
+		between := Between(king, sq) & occ
+		if between.Count() == 1 && between&b.WbBB[sd] != 0 {
+			pinned |= between
+			pinners |= bitboard.BitBoard(1) << uint(sq)
+		}
+	}
+	return pinned, pinners
+}
+
+// Between returns the squares between fr and to on a rank, file or diagonal, none if they
+// are not on one line
+func Between(fr, to int) bitboard.BitBoard {
+	frBB, toBB := bitboard.BitBoard(1)<<uint(fr), bitboard.BitBoard(1)<<uint(to)
+	if magic.RookAtks(fr, 0)&toBB != 0 {
+		return magic.RookAtks(fr, toBB) & magic.RookAtks(to, frBB)
+	}
+	if magic.BishopAtks(fr, 0)&toBB != 0 {
+		return magic.BishopAtks(fr, toBB) & magic.BishopAtks(to, frBB)
+	}
+	return 0
+}
+
+// GivesCheck tells if mv, a legal move of the side to move, checks the king of the other
+// side. It is answered from the board after the move without making it, with discovered
+// checks, promotions, en passant, castlings and drops
+func (b *BoardStruct) GivesCheck(mv moves.Move) bool {
+	if !b.Variant.Royal() {
+		return false
+	}
+	us := b.Stm
+	king := b.King[us.Opposite()]
+	fr, to := mv.Fr(), mv.To()
+	pt := Pc2pt(mv.Pc())
+	occ := b.AllBB()
+
+	// the pieces of us after the move, by type
+	var ours [NoPiecesT]bitboard.BitBoard
+	for p := Pawn; p <= King; p++ {
+		ours[p] = b.PieceBB[p] & b.WbBB[us]
+	}
+	move := func(pt, fr, to int) {
+		ours[pt].Clear(fr)
+		occ.Clear(fr)
+		ours[pt].SetBit(to)
+		occ.SetBit(to)
+	}
+
+	switch {
+	case mv.IsDrop():
+		ours[pt].SetBit(to)
+		occ.SetBit(to)
+	case b.IsCastling(mv):
+		opt := &b.Castl[us]
+		kingTo, rookTo := opt.KingToSh, opt.RookToSh
+		if to == opt.RookL {
+			kingTo, rookTo = opt.KingToL, opt.RookToL
+		}
+		ours[King].Clear(fr)
+		occ.Clear(fr)
+		ours[Rook].Clear(to)
+		occ.Clear(to)
+		ours[King].SetBit(kingTo)
+		occ.SetBit(kingTo)
+		ours[Rook].SetBit(rookTo)
+		occ.SetBit(rookTo)
+	default:
+		if pt == Pawn && to == b.Ep && b.Ep != 0 { // the captured pawn is beside the to square
+			occ.Clear(to - 8 + 16*int(us))
+		}
+		move(pt, fr, to)
+		if pr := mv.Pr(); pr != Empty {
+			ours[Pawn].Clear(to)
+			ours[Pc2pt(pr)].SetBit(to)
+		}
+	}
+
+	return PawnAtks(us.Opposite(), king)&ours[Pawn] != 0 ||
+		AtksKnights[king]&ours[Knight] != 0 ||
+		magic.BishopAtks(king, occ)&(ours[Bishop]|ours[Queen]) != 0 ||
+		magic.RookAtks(king, occ)&(ours[Rook]|ours[Queen]) != 0
+}
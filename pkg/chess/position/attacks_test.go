@@ -0,0 +1,257 @@
+package position
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/Tecu23/go-game/pkg/chess/bitboard"
+	. "github.com/Tecu23/go-game/pkg/chess/constants"
+	"github.com/Tecu23/go-game/pkg/chess/moves"
+)
+
+// the directions of the pieces as file and rank steps
+var (
+	diagDirs   = [][2]int{{1, 1}, {1, -1}, {-1, 1}, {-1, -1}}
+	lineDirs   = [][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}}
+	knightDirs = [][2]int{{1, 2}, {2, 1}, {2, -1}, {1, -2}, {-1, -2}, {-2, -1}, {-2, 1}, {-1, 2}}
+)
+
+// step returns the square df files and dr ranks from sq, or -1 off the board
+func step(sq, df, dr int) int {
+	f, r := sq%8+df, sq/8+dr
+	if f < 0 || f > 7 || r < 0 || r > 7 {
+		return -1
+	}
+	return r*8 + f
+}
+
+// refAttacks returns the squares the piece on sq attacks by walking the board
+func refAttacks(b *BoardStruct, sq int) bitboard.BitBoard {
+	pc := b.Squares[sq]
+	var atks bitboard.BitBoard
+	jump := func(dirs [][2]int) {
+		for _, d := range dirs {
+			if to := step(sq, d[0], d[1]); to >= 0 {
+				atks.SetBit(to)
+			}
+		}
+	}
+	slide := func(dirs [][2]int) {
+		for _, d := range dirs {
+			for to := step(sq, d[0], d[1]); to >= 0; to = step(to, d[0], d[1]) {
+				atks.SetBit(to)
+				if b.Squares[to] != Empty {
+					break
+				}
+			}
+		}
+	}
+
+	switch Pc2pt(pc) {
+	case Pawn:
+		dr := 1
+		if PcColor(pc) == BLACK {
+			dr = -1
+		}
+		jump([][2]int{{-1, dr}, {1, dr}})
+	case Knight:
+		jump(knightDirs)
+	case Bishop:
+		slide(diagDirs)
+	case Rook:
+		slide(lineDirs)
+	case Queen:
+		slide(diagDirs)
+		slide(lineDirs)
+	case King:
+		jump(append(append([][2]int{}, diagDirs...), lineDirs...))
+	}
+	return atks
+}
+
+// refXray returns the sliders of sd that would attack sq if exactly one piece between them
+// was gone
+func refXray(b *BoardStruct, sq int, sd Color) bitboard.BitBoard {
+	var xray bitboard.BitBoard
+	for _, dirs := range [][][2]int{diagDirs, lineDirs} {
+		for _, d := range dirs {
+			blockers := 0
+			for fr := step(sq, d[0], d[1]); fr >= 0 && blockers < 2; fr = step(fr, d[0], d[1]) {
+				pc := b.Squares[fr]
+				if pc == Empty {
+					continue
+				}
+				pt := Pc2pt(pc)
+				slider := pt == Queen || pt == Bishop && dirs[0] == diagDirs[0] || pt == Rook && dirs[0] == lineDirs[0]
+				if blockers == 1 && PcColor(pc) == sd && slider {
+					xray.SetBit(fr)
+				}
+				blockers++
+			}
+		}
+	}
+	return xray
+}
+
+// refPinned returns the pieces of sd pinned to their king by trying the board without them
+func refPinned(b *BoardStruct, sd Color) bitboard.BitBoard {
+	var pinned bitboard.BitBoard
+	king := b.King[sd]
+	for sq, pc := range b.Squares {
+		if pc == Empty || PcColor(pc) != sd || sq == king {
+			continue
+		}
+		c := b.Clone()
+		c.SetSq(Empty, sq)
+		for fr, p := range c.Squares {
+			pt := Pc2pt(p)
+			if p != Empty && PcColor(p) != sd && (pt == Bishop || pt == Rook || pt == Queen) &&
+				refAttacks(c, fr).IsBitSet(king) && !refAttacks(b, fr).IsBitSet(king) {
+				pinned.SetBit(sq)
+			}
+		}
+	}
+	return pinned
+}
+
+// pinFEN has three white pieces pinned to their king. On the first rank nothing is pinned,
+// there are two white pieces in front of the rook on a1 and a black bishop in front of h1
+const pinFEN = "4k3/8/4r3/8/1b5q/8/3PNB2/rNR1K1br w - - 0 1"
+
+// attackPositions returns the seed positions and the positions of random games from them
+func attackPositions(t *testing.T) []*BoardStruct {
+	rnd := rand.New(rand.NewSource(2))
+	games := 6
+	if testing.Short() {
+		games = 2
+	}
+	var bs []*BoardStruct
+	for _, seed := range append(fenSeeds, pinFEN) {
+		for g := 0; g < games; g++ {
+			b, err := ParseFEN(seed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for ply := 0; ply < 60; ply++ {
+				bs = append(bs, b.Clone())
+				var ml moves.MoveList
+				b.GenAllLegals(&ml)
+				if len(ml) == 0 {
+					break
+				}
+				b.Move(ml[rnd.Intn(len(ml))])
+			}
+		}
+	}
+	return bs
+}
+
+func TestAttackers(t *testing.T) {
+	for _, b := range attackPositions(t) {
+		var want [2][64]bitboard.BitBoard
+		for fr, pc := range b.Squares {
+			if pc == Empty {
+				continue
+			}
+			atks := refAttacks(b, fr)
+			if got := b.AttacksFrom(fr); got != atks {
+				t.Fatalf("%v: AttacksFrom(%v) = %x, want %x", b.FEN(), Sq2Fen[fr], got, atks)
+			}
+			for to := atks.FirstOne(); to != 64; to = atks.FirstOne() {
+				want[PcColor(pc)][to].SetBit(fr)
+			}
+		}
+
+		for sd := WHITE; sd <= BLACK; sd++ {
+			for sq := A1; sq <= H8; sq++ {
+				if got := b.Attackers(sq, sd); got != want[sd][sq] {
+					t.Fatalf("%v: Attackers(%v, %v) = %x, want %x", b.FEN(), Sq2Fen[sq], sd, got, want[sd][sq])
+				}
+				if got := b.IsAttacked(sq, sd); got && want[sd][sq] == 0 {
+					t.Fatalf("%v: IsAttacked(%v, %v) without an attacker", b.FEN(), Sq2Fen[sq], sd)
+				}
+				if got, want := b.XrayAttackers(sq, sd), refXray(b, sq, sd); got != want {
+					t.Fatalf("%v: XrayAttackers(%v, %v) = %x, want %x", b.FEN(), Sq2Fen[sq], sd, got, want)
+				}
+			}
+		}
+
+		if got, want := b.Checkers(), want[b.Stm.Opposite()][b.King[b.Stm]]; got != want {
+			t.Fatalf("%v: Checkers() = %x, want %x", b.FEN(), got, want)
+		}
+		if got := b.Checkers() != 0; got != b.InCheck() {
+			t.Fatalf("%v: Checkers() is %v and InCheck() %v", b.FEN(), got, b.InCheck())
+		}
+	}
+}
+
+func TestPins(t *testing.T) {
+	b, _ := ParseFEN(pinFEN)
+	if got, want := b.Pinned(WHITE), bitboard.BitBoard(1<<D2|1<<E2|1<<F2); got != want {
+		t.Errorf("Pinned(WHITE) = %x, want %x", got, want)
+	}
+	if got, want := b.Pinners(WHITE), bitboard.BitBoard(1<<B4|1<<E6|1<<H4); got != want {
+		t.Errorf("Pinners(WHITE) = %x, want %x", got, want)
+	}
+	if got := b.Pinned(BLACK); got != 0 {
+		t.Errorf("Pinned(BLACK) = %x, want none", got)
+	}
+
+	for _, b := range attackPositions(t) {
+		for sd := WHITE; sd <= BLACK; sd++ {
+			if got, want := b.Pinned(sd), refPinned(b, sd); got != want {
+				t.Fatalf("%v: Pinned(%v) = %x, want %x", b.FEN(), sd, got, want)
+			}
+			pinners := b.Pinners(sd)
+			for sq := pinners.FirstOne(); sq != 64; sq = pinners.FirstOne() {
+				if between := Between(sq, b.King[sd]); between&b.Pinned(sd) == 0 {
+					t.Fatalf("%v: %v pins nothing", b.FEN(), Sq2Fen[sq])
+				}
+			}
+		}
+	}
+}
+
+func TestGivesCheck(t *testing.T) {
+	bs := attackPositions(t)
+	for _, fen := range []string{
+		"4k3/8/8/8/8/8/8/R3K2R w KQ - 0 1", // castlings
+		"5k2/8/8/8/8/8/8/4K2R w K - 0 1",   // the rook checks on f1 after O-O
+		"8/8/8/KR1pP2k/8/8/8/8 w - d6 0 1", // en passant discovers the rook
+		"1n2k3/P7/8/8/8/8/8/4K3 w - - 0 1", // promotions
+		"3k4/8/8/8/3N4/8/8/3RK3 w - - 0 1", // discovered checks by the knight
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+	} {
+		b, err := ParseFEN(fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bs = append(bs, b)
+	}
+	zh, err := ParseFENVariant("3k4/8/8/8/8/8/8/4K3[QNp] w - - 0 1", Crazyhouse, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs = append(bs, zh)
+
+	checks := 0
+	for _, b := range bs {
+		var ml moves.MoveList
+		b.GenAllLegals(&ml)
+		for _, mv := range ml {
+			got := b.GivesCheck(mv)
+			b.Move(mv)
+			want := b.InCheck()
+			b.Unmove(mv)
+			if got != want {
+				t.Fatalf("%v: GivesCheck(%v) = %v, want %v", b.FEN(), b.UCI(mv), got, want)
+			}
+			if want {
+				checks++
+			}
+		}
+	}
+	if checks == 0 {
+		t.Errorf("no checking moves were tested")
+	}
+}
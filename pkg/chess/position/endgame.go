@@ -0,0 +1,289 @@
+package position
+
+import (
+	"strings"
+
+	"github.com/Tecu23/go-game/pkg/chess/bitboard"
+	. "github.com/Tecu23/go-game/pkg/chess/constants"
+)
+
+// endgameEval scores a known ending from the strong side pov
+type endgameEval func(b *BoardStruct, strong Color) int
+
+type endgameEntry struct {
+	eval   endgameEval
+	strong Color
+}
+
+// endgames maps a material signature to its dedicated evaluator
+var endgames = make(map[uint64]endgameEntry)
+
+// maxEndgamePieces is the largest number of pieces (kings included) in any registered ending
+const maxEndgamePieces = 5
+
+const (
+	darkSquares = bitboard.BitBoard(0xAA55AA55AA55AA55)
+	winBonus    = 1000 // for endings we know are won. Keeps the score far away from mate scores
+)
+
+// InitEndgames registers all endings with special knowledge and solves the bitbases they probe
+func InitEndgames() {
+	InitKPK()
+
+	addEndgame("KQvK", evalKXK)
+	addEndgame("KRvK", evalKXK)
+	addEndgame("KQQvK", evalKXK)
+	addEndgame("KQRvK", evalKXK)
+	addEndgame("KRRvK", evalKXK)
+	addEndgame("KBBvK", evalKBBK)
+	addEndgame("KBNvK", evalKBNK)
+	addEndgame("KPvK", evalKPK)
+
+	// not enough material to win
+	addEndgame("KvK", evalDraw)
+	addEndgame("KNvK", evalDraw)
+	addEndgame("KBvK", evalDraw)
+	addEndgame("KNNvK", evalDraw)
+	addEndgame("KBvKB", evalDraw)
+	addEndgame("KNvKN", evalDraw)
+	addEndgame("KBvKN", evalDraw)
+}
+
+// addEndgame registers eval for the signature (e.g. "KBNvK", white is the strong side)
+// and for the same signature with the colors reversed
+func addEndgame(sig string, eval endgameEval) {
+	sides := strings.Split(sig, "v")
+	endgames[sigKey(sides[0], sides[1])] = endgameEntry{eval, WHITE}
+	endgames[sigKey(sides[1], sides[0])] = endgameEntry{eval, BLACK}
+}
+
+// sigKey computes the material key for the white and black pieces in a signature
+func sigKey(white, black string) uint64 {
+	var count [NoPiecesC]int
+	for _, c := range white {
+		count[Pt2pc(strings.IndexRune(PtFen, c), WHITE)]++
+	}
+	for _, c := range black {
+		count[Pt2pc(strings.IndexRune(PtFen, c), BLACK)]++
+	}
+	return countKey(&count)
+}
+
+// countKey packs the number of pawns, knights, bishops, rooks and queens of both sides into a key
+func countKey(count *[NoPiecesC]int) uint64 {
+	key := uint64(0)
+	for pc := WP; pc < WK; pc++ {
+		key |= uint64(count[pc]) << uint(4*pc)
+	}
+	return key
+}
+
+// MaterialKey returns the key for the material signature of the position
+func (b *BoardStruct) MaterialKey() uint64 {
+	return countKey(&b.Count)
+}
+
+// evalEndgame returns the score from white pov if the position is a known ending
+func evalEndgame(b *BoardStruct) (int, bool) {
+	if b.AllBB().Count() > maxEndgamePieces {
+		return 0, false
+	}
+
+	e, ok := endgames[b.MaterialKey()]
+	if !ok {
+		return 0, false
+	}
+
+	ev := e.eval(b, e.strong)
+	if e.strong == BLACK {
+		return -ev, true
+	}
+	return ev, true
+}
+
+// scaleEndgame scales down the white pov score ev in endings that are hard or impossible to win
+func scaleEndgame(b *BoardStruct, ev int) int {
+	if ev == 0 {
+		return 0
+	}
+
+	strong := WHITE
+	if ev < 0 {
+		strong = BLACK
+	}
+	weak := strong.Opposite()
+
+	// the strong side has no pawns and is at most a bishop up
+	if b.PieceBB[Pawn]&b.WbBB[strong] == 0 &&
+		nonPawnMaterial(b, strong)-nonPawnMaterial(b, weak) <= PieceVal[WB] {
+		return ev / 8
+	}
+
+	if b.isWrongBishop(strong) {
+		return 0
+	}
+
+	if b.isOppositeBishops() {
+		return ev / 2
+	}
+
+	return ev
+}
+
+// nonPawnMaterial is the value of all knights, bishops, rooks and queens of side sd
+func nonPawnMaterial(b *BoardStruct, sd Color) int {
+	val := 0
+	for pt := Knight; pt <= Queen; pt++ {
+		val += b.Count[Pt2pc(pt, sd)] * PieceVal[Pt2pc(pt, WHITE)]
+	}
+	return val
+}
+
+// isOppositeBishops is true if both sides have only one bishop and pawns, and the bishops are on different colors
+func (b *BoardStruct) isOppositeBishops() bool {
+	if b.Count[WB] != 1 || b.Count[BB] != 1 {
+		return false
+	}
+	if (b.PieceBB[Knight] | b.PieceBB[Rook] | b.PieceBB[Queen]) != 0 {
+		return false
+	}
+
+	wB := b.PieceBB[Bishop] & b.WbBB[WHITE]
+	bB := b.PieceBB[Bishop] & b.WbBB[BLACK]
+	return (wB&darkSquares != 0) != (bB&darkSquares != 0)
+}
+
+// isWrongBishop is true if the strong side only has a bishop and pawns on one rook file,
+// the bishop can't control the promotion square and the weak king is in the corner
+func (b *BoardStruct) isWrongBishop(strong Color) bool {
+	weak := strong.Opposite()
+	if b.Count[Pt2pc(Bishop, strong)] != 1 || b.Count[Pt2pc(Pawn, strong)] == 0 {
+		return false
+	}
+	if b.Count[Pt2pc(Knight, strong)]+b.Count[Pt2pc(Rook, strong)]+b.Count[Pt2pc(Queen, strong)] != 0 {
+		return false
+	}
+	if b.WbBB[weak] != b.PieceBB[King]&b.WbBB[weak] { // the weak side must only have its king
+		return false
+	}
+
+	pawns := b.PieceBB[Pawn] & b.WbBB[strong]
+	promFile := 0
+	switch {
+	case pawns & ^FileA == 0:
+		promFile = 0
+	case pawns & ^FileH == 0:
+		promFile = 7
+	default:
+		return false
+	}
+
+	promSq := 7*8 + promFile
+	if strong == BLACK {
+		promSq = promFile
+	}
+
+	bishop := b.PieceBB[Bishop] & b.WbBB[strong]
+	if (bishop&darkSquares != 0) == darkSquares.IsBitSet(promSq) {
+		return false // the bishop controls the promotion square
+	}
+
+	return SqDistance(b.King[weak], promSq) <= 1
+}
+
+// evalDraw is for endings where neither side can win
+func evalDraw(b *BoardStruct, strong Color) int {
+	return 0
+}
+
+// evalKXK drives the weak king to the edge with the help of the strong king (KQK, KRK and so on)
+func evalKXK(b *BoardStruct, strong Color) int {
+	weak := strong.Opposite()
+	ev := nonPawnMaterial(b, strong) + winBonus
+	ev += pushToEdge(b.King[weak])
+	ev += pushClose(b.King[strong], b.King[weak])
+	ev -= 10 * b.kingFlights(weak)
+	return ev
+}
+
+// evalKBBK is won only with bishops on both colors
+func evalKBBK(b *BoardStruct, strong Color) int {
+	bishops := b.PieceBB[Bishop] & b.WbBB[strong]
+	if bishops&darkSquares == 0 || bishops & ^darkSquares == 0 {
+		return 0
+	}
+	return evalKXK(b, strong)
+}
+
+// evalKBNK drives the weak king to a corner of the same color as the bishop
+func evalKBNK(b *BoardStruct, strong Color) int {
+	weak := strong.Opposite()
+	ev := nonPawnMaterial(b, strong) + winBonus
+	ev += pushClose(b.King[strong], b.King[weak])
+
+	// the manhattan distance gives a gradient along the edge towards the right corner
+	wk := b.King[weak]
+	bishop := b.PieceBB[Bishop] & b.WbBB[strong]
+	if bishop&darkSquares != 0 {
+		ev += 30 * (14 - Min(manhattan(wk, A1), manhattan(wk, H8)))
+	} else {
+		ev += 30 * (14 - Min(manhattan(wk, H1), manhattan(wk, A8)))
+	}
+	ev += pushToEdge(wk) / 2
+	ev -= 10 * b.kingFlights(weak)
+	return ev
+}
+
+// evalKPK is exact with the bitbase. Won positions get a bonus for advancing the pawn
+func evalKPK(b *BoardStruct, strong Color) int {
+	if !probeKPK(b, strong) {
+		return 0
+	}
+
+	pawns := b.PieceBB[Pawn]
+	rank := pawns.FirstOne() / 8
+	if strong == BLACK {
+		rank = 7 - rank
+	}
+	return winBonus + PieceVal[WP] + 20*rank
+}
+
+// kingFlights counts the squares the king of side sd can go to without being captured
+func (b *BoardStruct) kingFlights(sd Color) int {
+	cnt := 0
+	toBB := AtksKings[b.King[sd]] & ^b.WbBB[sd]
+	for to := toBB.FirstOne(); to != 64; to = toBB.FirstOne() {
+		if !b.IsAttacked(to, sd.Opposite()) {
+			cnt++
+		}
+	}
+	return cnt
+}
+
+// pushToEdge gives a bonus the closer sq is to the edge of the board
+func pushToEdge(sq int) int {
+	fl, rk := sq%8, sq/8
+	fDist := Min(fl, 7-fl)
+	rDist := Min(rk, 7-rk)
+	return 20 * (6 - fDist - rDist)
+}
+
+// pushClose gives a bonus the closer the two kings are
+func pushClose(sq1, sq2 int) int {
+	return 10 * (7 - SqDistance(sq1, sq2))
+}
+
+// manhattan is the number of file and rank steps between sq1 and sq2
+func manhattan(sq1, sq2 int) int {
+	return Abs(sq1%8-sq2%8) + Abs(sq1/8-sq2/8)
+}
+
+// SqDistance is the number of king moves between sq1 and sq2
+func SqDistance(sq1, sq2 int) int {
+	fDist := Abs(sq1%8 - sq2%8)
+	rDist := Abs(sq1/8 - sq2/8)
+	if fDist > rDist {
+		return fDist
+	}
+	return rDist
+}
@@ -2,6 +2,7 @@ package position
 
 import (
 	. "github.com/Tecu23/go-game/pkg/chess/constants"
+	"github.com/Tecu23/go-game/pkg/chess/nnue"
 )
 
 // TODO: eval hash
@@ -13,16 +14,37 @@ import (
 // TODO: space, center control, knight outposts, connected rooks, 7th row and more
 // TODO: combine middle game and end game values
 
-// evaluate returns score from white pov
+// Evaluate returns score from white pov, by the rules of the variant
 func Evaluate(b *BoardStruct) int {
+	return b.Variant.Evaluate(b)
+}
+
+// evaluate returns the score of chess from white pov
+func evaluate(b *BoardStruct) int {
+	if ev, ok := evalEndgame(b); ok {
+		return ev
+	}
+	return scaleEndgame(b, evalBoard(b))
+}
+
+// evalBoard returns the score of the pieces on the board from white pov
+func evalBoard(b *BoardStruct) int {
 	ev := 0
-	for sq := A1; sq <= H8; sq++ {
-		pc := b.Squares[sq]
-		if pc == Empty {
-			continue
+	if nnue.Net != nil {
+		// the network scores from stm pov
+		ev = b.Acc.Evaluate(b.Stm)
+		if b.Stm == BLACK {
+			ev = -ev
+		}
+	} else {
+		for sq := A1; sq <= H8; sq++ {
+			pc := b.Squares[sq]
+			if pc == Empty {
+				continue
+			}
+			ev += PieceVal[pc]
+			ev += PcSqScore(pc, sq)
 		}
-		ev += PieceVal[pc]
-		ev += PcSqScore(pc, sq)
 	}
 	return ev
 }
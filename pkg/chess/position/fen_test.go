@@ -0,0 +1,269 @@
+package position
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	. "github.com/Tecu23/go-game/pkg/chess/constants"
+	"github.com/Tecu23/go-game/pkg/chess/moves"
+)
+
+// seed positions for the round trip corpus: the start position, the perft test
+// positions and a few endings
+var fenSeeds = []string{
+	"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+	"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+	"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1",
+	"r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1",
+	"rnbq1k1r/pp1Pbppp/2p5/8/2B5/8/PPP1NnPP/RNBQK2R w KQ - 1 8",
+	"r4rk1/1pp1qppp/p1np1n2/2b1p1B1/2B1P1b1/P1NP1N2/1PP1QPPP/R4RK1 w - - 0 10",
+	"8/8/4k3/8/2p5/8/B2P2K1/8 w - - 0 1",
+	"4k3/8/8/8/8/8/8/4K2R w K - 0 1",
+	"2r3k1/1q1nbppp/r3p3/3pP3/pPpP4/P1Q2N2/2RN1PPP/2R4K b - b3 0 23",
+	"1r3kr1/pppppppp/8/8/8/8/PPPPPPPP/R1RK3R w KCkq - 0 1", // Chess960
+}
+
+func TestFEN(t *testing.T) {
+	for _, fen := range fenSeeds {
+		b, err := ParseFEN(fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := b.FEN(); got != fen {
+			t.Errorf("got %v, want %v", got, fen)
+		}
+	}
+}
+
+func TestParseFENErrors(t *testing.T) {
+	tests := []struct {
+		fen string
+		err string // part of the error message
+	}{
+		{"", "fields"},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR", "fields"},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1 x", "fields"},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP w KQkq - 0 1", "ranks"},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBN w KQkq - 0 1", "rank 1 has 7 files"},
+		{"rnbqkbnr/pppppppp/9/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", "invalid character"},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNRR w KQkq - 0 1", "rank 1 has 9 files"},
+		{"rnbqkbnr/ppppxppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", "invalid character"},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR x KQkq - 0 1", "side to move"},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQ1BNR w kq - 0 1", "white has 0 kings"},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBKKBNR w kq - 0 1", "white has 2 kings"},
+		{"rnbqkbnr/pppppppp/8/8/8/P7/PPPPPPPP/RNBQKBNR w KQkq - 0 1", "white has 9 pawns"},
+		{"rnbqkbnP/pppppppp/8/8/8/8/PPPPPPP1/RNBQKBNR w KQkq - 0 1", "pawn on the first or last rank"},
+		{"4k3/8/8/8/8/8/8/p3K3 w - - 0 1", "pawn on the first or last rank"},
+		{"4k3/8/8/8/8/8/8/4K2R b - - 0 1", ""}, // fine
+		{"4k3/8/8/8/8/8/8/4R1K1 w - - 0 1", "not to move is in check"},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkqK - 0 1", "castling rights"},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQxq - 0 1", "castling rights"},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBN1 w KQkq - 0 1", "white can't castle short"},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQ1KNR w QA - 0 1", "castling rights"},
+		{"rnbqkbnr/pppppppp/8/8/8/4K3/PPPPPPPP/RNBQ1BNR w Q - 0 1", "white can't castle long"},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/1NBQKBNR w B - 0 1", "white can't castle long"},
+		{"1nbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w q - 0 1", "black can't castle long"},
+		{"1r3kr1/pppppppp/8/8/8/8/PPPPPPPP/R1RK3R w KQkq - 0 1", "white can't castle short from d1 and h1 without UCI_Chess960"},
+		{"r3k2r/8/8/8/8/8/8/1R2K1RR w Qk - 0 1", "white can't castle long from e1 and b1 without UCI_Chess960"},
+		{"1r3kr1/pppppppp/8/8/8/8/PPPPPPPP/R1RK3R w KCkq - 0 1", ""}, // Shredder letters are Chess960
+		{"rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e4 0 1", "ep square e4"},
+		{"rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq d3 0 1", "ep square d3"},
+		{"rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR w KQkq e3 0 1", "ep square e3"},
+		{"rnbqkbnr/pppppppp/8/8/4P3/4P3/PPP2PPP/RNBQKBNR b KQkq e3 0 1", "ep square e3"},
+		{"rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e9 0 1", "invalid ep square"},
+		{"rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1", ""},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - -1 1", "halfmove clock"},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - x 1", "halfmove clock"},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 0", "full move counter"},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq -", ""}, // EPD
+	}
+
+	for _, tt := range tests {
+		_, err := ParseFEN(tt.fen)
+		switch {
+		case tt.err == "" && err != nil:
+			t.Errorf("%v: unexpected error %v", tt.fen, err)
+		case tt.err != "" && err == nil:
+			t.Errorf("%v: no error, want %v", tt.fen, tt.err)
+		case tt.err != "" && !strings.Contains(err.Error(), tt.err):
+			t.Errorf("%v: got error %v, want %v", tt.fen, err, tt.err)
+		}
+	}
+}
+
+func TestFENCastlings(t *testing.T) {
+	tests := []struct {
+		fen            string
+		want           string // the castling rights written back
+		rookSh, rookL  int    // white's castling rooks
+		kingTo, rookTo int    // white's king and rook after O-O
+	}{
+		{Startpos, "KQkq", H1, A1, G1, F1},
+		{"bqnb1rkr/pp3ppp/3ppn2/2p5/5P2/P2P4/NPP1P1PP/BQ1BNRKR w HFhf - 2 9", "KQkq", H1, F1, G1, F1},
+		{"b1q1rrkb/pppppppp/3nn3/8/P7/1PPP4/4PPPP/BQNNRKRB w GE - 1 9", "KQ", G1, E1, G1, F1},
+		{"r3k2r/8/8/8/8/8/8/1R2K1RR w GBkq - 0 1", "GQkq", G1, B1, G1, F1},
+		{"r3k2r/8/8/8/8/8/8/1R2K1RR w Qk - 0 1", "Qk", H1, B1, G1, F1},
+	}
+
+	for _, tt := range tests {
+		b, err := ParseFENVariant(tt.fen, Standard, true) // KQkq for rooks that didn't start in the corners
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Fields(b.FEN())[2]; got != tt.want {
+			t.Errorf("%v: got castling rights %v, want %v", tt.fen, got, tt.want)
+		}
+		opt := b.Castl[WHITE]
+		if opt.RookSh != tt.rookSh || opt.RookL != tt.rookL || opt.KingToSh != tt.kingTo || opt.RookToSh != tt.rookTo {
+			t.Errorf("%v: got rooks %v %v, O-O to %v %v", tt.fen, Sq2Fen[opt.RookSh], Sq2Fen[opt.RookL], Sq2Fen[opt.KingToSh], Sq2Fen[opt.RookToSh])
+		}
+	}
+}
+
+func TestUCI(t *testing.T) {
+	defer func() { Chess960 = false }()
+	tests := []struct {
+		fen      string
+		chess960 bool
+		uci      string // the castling as written by UCI
+		alt      string // also read as the castling
+		not      string // not read as the castling
+		san      string
+		after    string
+	}{
+		{"r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", false, "e1g1", "e1h1", "", "O-O", "r3k2r/8/8/8/8/8/8/R4RK1 b kq - 1 1"},
+		{"r3k2r/8/8/8/8/8/8/R3K2R b KQkq - 0 1", true, "e8a8", "", "e8c8", "O-O-O", "2kr3r/8/8/8/8/8/8/R3K2R w KQ - 1 2"},
+		{"3k4/8/8/8/8/8/8/4RK1R w K - 0 1", true, "f1h1", "", "f1g1", "O-O", "3k4/8/8/8/8/8/8/4RRK1 b - - 1 1"},
+		{"4k3/8/8/8/8/8/8/5KR1 w K - 0 1", true, "f1g1", "", "", "O-O", "4k3/8/8/8/8/8/8/5RK1 b - - 1 1"},
+		{"4k3/8/8/8/8/8/8/RK6 w Q - 0 1", true, "b1a1", "", "b1c1", "O-O-O", "4k3/8/8/8/8/8/8/2KR4 b - - 1 1"},
+	}
+
+	for _, tt := range tests {
+		Chess960 = tt.chess960
+		b, err := ParseFENVariant(tt.fen, Standard, tt.chess960)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mv, err := b.ParseUCI(tt.uci)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !b.IsCastling(mv) {
+			t.Errorf("%v: %v is not a castling", tt.fen, tt.uci)
+		}
+		if got := b.UCI(mv); got != tt.uci {
+			t.Errorf("%v: got %v, want %v", tt.fen, got, tt.uci)
+		}
+		if got := b.SAN(mv); got != tt.san {
+			t.Errorf("%v: got %v, want %v", tt.fen, got, tt.san)
+		}
+		if sanMv, err := b.ParseSAN(tt.san); err != nil || sanMv != mv {
+			t.Errorf("%v: got %v %v for %v", tt.fen, sanMv, err, tt.san)
+		}
+		if tt.alt != "" {
+			if alt, err := b.ParseUCI(tt.alt); err != nil || alt != mv {
+				t.Errorf("%v: got %v %v for %v", tt.fen, alt, err, tt.alt)
+			}
+		}
+		if tt.not != "" {
+			if other, err := b.ParseUCI(tt.not); err == nil && b.IsCastling(other) {
+				t.Errorf("%v: %v is read as a castling", tt.fen, tt.not)
+			}
+		}
+
+		b.Move(mv)
+		if got := b.FEN(); got != tt.after {
+			t.Errorf("%v: got %v after %v, want %v", tt.fen, got, tt.uci, tt.after)
+		}
+		b.Unmove(mv)
+		if got := b.FEN(); got != tt.fen {
+			t.Errorf("got %v after Unmove, want %v", got, tt.fen)
+		}
+	}
+}
+
+func TestFENAfterMoves(t *testing.T) {
+	tests := []struct {
+		mvs string
+		fen string
+	}{
+		{"e2e4", "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1"},
+		{"e2e4 c7c5", "rnbqkbnr/pp1ppppp/8/2p5/4P3/8/PPPP1PPP/RNBQKBNR w KQkq c6 0 2"},
+		{"e2e4 c7c5 g1f3", "rnbqkbnr/pp1ppppp/8/2p5/4P3/5N2/PPPP1PPP/RNBQKB1R b KQkq - 1 2"},
+		{"g1f3 g8f6 f3g1 f6g8", "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 4 3"},
+		{"e2e4 d7d5 e4d5 d8d5 b1c3", "rnb1kbnr/ppp1pppp/8/3q4/8/2N5/PPPP1PPP/R1BQKBNR b KQkq - 1 3"},
+		{"e2e4 e7e5 e1e2 e8e7", "rnbq1bnr/ppppkppp/8/4p3/4P3/8/PPPPKPPP/RNBQ1BNR w - - 2 3"},
+	}
+
+	for _, tt := range tests {
+		Board.NewGame()
+		if err := ParseMvs(tt.mvs); err != nil {
+			t.Fatalf("%v: %v", tt.mvs, err)
+		}
+		if got := Board.FEN(); got != tt.fen {
+			t.Errorf("%v: got %v, want %v", tt.mvs, got, tt.fen)
+		}
+	}
+}
+
+// TestFENRoundTrip plays random games from the seed positions and checks that every
+// position comes back the same from its FEN, and that Unmove restores the counters
+func TestFENRoundTrip(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	games, plies := 40, 120
+	if testing.Short() {
+		games = 5
+	}
+
+	cnt := 0
+	for _, seed := range fenSeeds {
+		// the Chess960 games write KQkq for rooks that didn't start in the corners
+		chess960 := strings.Trim(strings.Fields(seed)[2], "KQkq-") != ""
+		for g := 0; g < games; g++ {
+			b, err := ParseFENVariant(seed, Standard, chess960)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var played []moves.Move
+			var fens []string
+			for ply := 0; ply < plies; ply++ {
+				fen := b.FEN()
+				b2, err := ParseFENVariant(fen, Standard, chess960)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !sameBoard(b, b2) {
+					t.Fatalf("%v doesn't give the same board back", fen)
+				}
+				cnt++
+
+				var ml moves.MoveList
+				b.GenAllLegals(&ml)
+				if len(ml) == 0 {
+					break
+				}
+				mv := ml[rnd.Intn(len(ml))]
+				b.Move(mv)
+				played = append(played, mv)
+				fens = append(fens, fen)
+			}
+
+			for ix := len(played) - 1; ix >= 0; ix-- {
+				b.Unmove(played[ix])
+				if got := b.FEN(); got != fens[ix] {
+					t.Fatalf("after Unmove got %v, want %v", got, fens[ix])
+				}
+			}
+		}
+	}
+	t.Logf("%v positions", cnt)
+}
+
+func sameBoard(b1, b2 *BoardStruct) bool {
+	return b1.Squares == b2.Squares && b1.WbBB == b2.WbBB && b1.PieceBB == b2.PieceBB &&
+		b1.King == b2.King && b1.Count == b2.Count && b1.Stm == b2.Stm &&
+		b1.Castlings == b2.Castlings && b1.Ep == b2.Ep && b1.Rule50 == b2.Rule50 &&
+		b1.FullMove == b2.FullMove && b1.Key == b2.Key && b1.FullKey() == b2.FullKey()
+}
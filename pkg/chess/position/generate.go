@@ -2,7 +2,6 @@ package position
 
 import (
 	"github.com/Tecu23/go-game/pkg/chess/bitboard"
-	"github.com/Tecu23/go-game/pkg/chess/castlings"
 	. "github.com/Tecu23/go-game/pkg/chess/constants"
 	"github.com/Tecu23/go-game/pkg/chess/magic"
 	"github.com/Tecu23/go-game/pkg/chess/moves"
@@ -152,8 +151,7 @@ func (b *BoardStruct) iswPawnAtkingSq(to int) bool {
 	wPawns := b.PieceBB[Pawn] & b.WbBB[WHITE]
 
 	// Attacks left and right
-	toCap := ((wPawns & ^FileA) << NW) & b.WbBB[BLACK]
-	toCap |= ((wPawns & ^FileH) << NE) & b.WbBB[BLACK]
+	toCap := (wPawns.NorthWest() | wPawns.NorthEast()) & b.WbBB[BLACK]
 	return (toCap & sqBB) != 0
 }
 
@@ -164,8 +162,7 @@ func (b *BoardStruct) isbPawnAtkingSq(to int) bool {
 	bPawns := b.PieceBB[Pawn] & b.WbBB[BLACK]
 
 	// Attacks left and right
-	toCap := ((bPawns & ^FileA) >> (-SW)) & b.WbBB[WHITE]
-	toCap |= ((bPawns & ^FileH) >> (-SE)) & b.WbBB[WHITE]
+	toCap := (bPawns.SouthWest() | bPawns.SouthEast()) & b.WbBB[WHITE]
 
 	return (toCap & sqBB) != 0
 }
@@ -175,8 +172,7 @@ func (b *BoardStruct) wPawnAtkers() bitboard.BitBoard {
 	BB := b.WbBB[BLACK] // all their pieces
 	// pretend that all their pieces are pawns
 	// Get pawn Attacks left and right from their pieces into our pawns that now are all our pwan attackers
-	ourPawnAttackers := ((BB & ^FileA) >> (-SW)) & b.WbBB[WHITE] & b.PieceBB[Pawn]
-	ourPawnAttackers |= ((BB & ^FileH) >> (-SE)) & b.WbBB[WHITE] & b.PieceBB[Pawn]
+	ourPawnAttackers := (BB.SouthWest() | BB.SouthEast()) & b.WbBB[WHITE] & b.PieceBB[Pawn]
 
 	return ourPawnAttackers
 }
@@ -186,8 +182,7 @@ func (b *BoardStruct) bPawnAtkers() bitboard.BitBoard {
 	BB := b.WbBB[WHITE] // all their pieces
 	// pretend that all their pieces are pawns
 	// Get pawn Attacks left and right from their pieces into our pawns that now are all our pwan attackers
-	ourPawnAttackers := ((BB & ^FileA) << NW) & b.WbBB[BLACK] & b.PieceBB[Pawn]
-	ourPawnAttackers |= ((BB & ^FileH) << NE) & b.WbBB[BLACK] & b.PieceBB[Pawn]
+	ourPawnAttackers := (BB.NorthWest() | BB.NorthEast()) & b.WbBB[BLACK] & b.PieceBB[Pawn]
 
 	return ourPawnAttackers
 }
@@ -197,9 +192,7 @@ func (b *BoardStruct) WPawnAtksFr(fr int) bitboard.BitBoard {
 	frBB := bitboard.BitBoard(1) << uint(fr)
 
 	// Attacks left and right
-	toCap := ((frBB & ^FileA) << NW) & b.WbBB[BLACK]
-	toCap |= ((frBB & ^FileH) << NE) & b.WbBB[BLACK]
-	return toCap
+	return (frBB.NorthWest() | frBB.NorthEast()) & b.WbBB[BLACK]
 }
 
 // returns captures from fr-sq
@@ -207,10 +200,7 @@ func (b *BoardStruct) BPawnAtksFr(fr int) bitboard.BitBoard {
 	frBB := bitboard.BitBoard(1) << uint(fr)
 
 	// Attacks left and right
-	toCap := ((frBB & ^FileA) >> (-SW)) & b.WbBB[WHITE]
-	toCap |= ((frBB & ^FileH) >> (-SE)) & b.WbBB[WHITE]
-
-	return toCap
+	return (frBB.SouthWest() | frBB.SouthEast()) & b.WbBB[WHITE]
 }
 
 // returns bitBoard with all attacks, empty or not, from all white Pawns
@@ -218,19 +208,15 @@ func (b *BoardStruct) wPawnAtksBB() bitboard.BitBoard {
 	frBB := b.PieceBB[Pawn] & b.WbBB[WHITE]
 
 	// Attacks left and right
-	toCap := ((frBB & ^FileA) << NW)
-	toCap |= ((frBB & ^FileH) << NE)
-	return toCap
+	return frBB.NorthWest() | frBB.NorthEast()
 }
 
-// returns bitBoard with all attacks, empty or not, from all white Pawns
+// returns bitBoard with all attacks, empty or not, from all black Pawns
 func (b *BoardStruct) bPawnAtksBB() bitboard.BitBoard {
 	frBB := b.PieceBB[Pawn] & b.WbBB[BLACK]
 
 	// Attacks left and right
-	toCap := ((frBB & ^FileA) << NW)
-	toCap |= ((frBB & ^FileH) << NE)
-	return toCap
+	return frBB.SouthWest() | frBB.SouthEast()
 }
 
 func (b *BoardStruct) GenRookMoves(ml *moves.MoveList, targetBB bitboard.BitBoard) {
@@ -239,7 +225,7 @@ func (b *BoardStruct) GenRookMoves(ml *moves.MoveList, targetBB bitboard.BitBoar
 	pc := Pt2pc(Rook, Color(sd))
 	var mv moves.Move
 	for fr := allRBB.FirstOne(); fr != 64; fr = allRBB.FirstOne() {
-		toBB := magic.MRookTab[fr].Atks(b.AllBB()) & targetBB
+		toBB := magic.RookAtks(fr, b.AllBB()) & targetBB
 		for to := toBB.FirstOne(); to != 64; to = toBB.FirstOne() {
 			mv.PackMove(fr, to, pc, b.Squares[to], Empty, b.Ep, b.Castlings)
 			ml.Add(mv)
@@ -256,7 +242,7 @@ func (b *BoardStruct) GenBishopMoves(ml *moves.MoveList, targetBB bitboard.BitBo
 	var mv moves.Move
 
 	for fr := allBBB.FirstOne(); fr != 64; fr = allBBB.FirstOne() {
-		toBB := magic.MBishopTab[fr].Atks(b.AllBB()) & targetBB
+		toBB := magic.BishopAtks(fr, b.AllBB()) & targetBB
 		for to := toBB.LastOne(); to != 64; to = toBB.LastOne() {
 			mv.PackMove(fr, to, pc, b.Squares[to], Empty, ep, castlings)
 			ml.Add(mv)
@@ -273,8 +259,8 @@ func (b *BoardStruct) GenQueenMoves(mlq *moves.MoveList, targetBB bitboard.BitBo
 	var mv moves.Move
 
 	for fr := allQBB.FirstOne(); fr != 64; fr = allQBB.FirstOne() {
-		toBB := magic.MBishopTab[fr].Atks(b.AllBB()) & targetBB
-		toBB |= magic.MRookTab[fr].Atks(b.AllBB()) & targetBB
+		toBB := magic.BishopAtks(fr, b.AllBB()) & targetBB
+		toBB |= magic.RookAtks(fr, b.AllBB()) & targetBB
 		for to := toBB.FirstOne(); to != 64; to = toBB.FirstOne() {
 			mv.PackMove(fr, to, pc, b.Squares[to], Empty, ep, castlings)
 			mlq.Add(mv)
@@ -298,6 +284,8 @@ func (b *BoardStruct) GenKnightMoves(ml *moves.MoveList, targetBB bitboard.BitBo
 	}
 }
 
+// GenKingMoves generates the moves of all kings, there can be more than one when the king is a
+// common piece like in antichess
 func (b *BoardStruct) GenKingMoves(ml *moves.MoveList, targetBB bitboard.BitBoard) {
 	sd := b.Stm
 	// 'normal' moves
@@ -306,51 +294,26 @@ func (b *BoardStruct) GenKingMoves(ml *moves.MoveList, targetBB bitboard.BitBoar
 	Castlings := b.Castlings
 	var mv moves.Move
 
-	toBB := AtksKings[b.King[sd]] & targetBB
-	for to := toBB.FirstOne(); to != 64; to = toBB.FirstOne() {
-		mv.PackMove(b.King[sd], to, pc, b.Squares[to], Empty, ep, Castlings)
-		ml.Add(mv)
-	}
-
-	// castlings
-	if b.King[sd] == castlings.Castl[sd].KingPos { // NOTE: Maybe not needed. We should know that the king is there if the flags are ok
-		if targetBB.IsBitSet(b.King[sd] + 2) {
-			// short castling
-			if b.Squares[castlings.Castl[sd].RookSh] == castlings.Castl[sd].Rook && // NOTE: Maybe not needed. We should know that the rook is there if the flags are ok
-				(castlings.Castl[sd].BetweenSh&b.AllBB()) == 0 {
-				if b.IsShortOk(sd) {
-					mv.PackMove(
-						b.King[sd],
-						b.King[sd]+2,
-						b.Squares[b.King[sd]],
-						Empty,
-						Empty,
-						b.Ep,
-						b.Castlings,
-					)
-					ml.Add(mv)
-				}
-			}
+	frBB := b.PieceBB[King] & b.WbBB[sd]
+	for fr := frBB.FirstOne(); fr != 64; fr = frBB.FirstOne() {
+		toBB := AtksKings[fr] & targetBB
+		for to := toBB.FirstOne(); to != 64; to = toBB.FirstOne() {
+			mv.PackMove(fr, to, pc, b.Squares[to], Empty, ep, Castlings)
+			ml.Add(mv)
 		}
+	}
 
-		if targetBB.IsBitSet(b.King[sd] - 2) {
-			// long castling
-			if b.Squares[castlings.Castl[sd].RookL] == castlings.Castl[sd].Rook && // NOTE: Maybe not needed. We should know that the rook is there if the flags are ok
-				(castlings.Castl[sd].BetweenL&b.AllBB()) == 0 {
-				if b.IsLongOk(sd) {
-					mv.PackMove(
-						b.King[sd],
-						b.King[sd]-2,
-						b.Squares[b.King[sd]],
-						Empty,
-						Empty,
-						b.Ep,
-						b.Castlings,
-					)
-					ml.Add(mv)
-				}
-			}
-		}
+	// castlings, the king takes its own rook. They are not captures
+	if targetBB&^b.WbBB[sd.Opposite()] == 0 {
+		return
+	}
+	if b.IsShortOk(sd) {
+		mv.PackMove(b.King[sd], b.Castl[sd].RookSh, pc, Empty, Empty, ep, Castlings)
+		ml.Add(mv)
+	}
+	if b.IsLongOk(sd) {
+		mv.PackMove(b.King[sd], b.Castl[sd].RookL, pc, Empty, Empty, ep, Castlings)
+		ml.Add(mv)
 	}
 }
 
@@ -420,6 +383,10 @@ func (b *BoardStruct) GenWPawnMoves(ml *moves.MoveList) {
 				ml.Add(mv)
 				mv.PackMove(fr, to, WP, cp, WB, b.Ep, b.Castlings)
 				ml.Add(mv)
+				if !b.Variant.Royal() {
+					mv.PackMove(fr, to, WP, cp, WK, b.Ep, b.Castlings)
+					ml.Add(mv)
+				}
 			}
 		}
 		to1Step &= ^Row8
@@ -505,6 +472,10 @@ func (b *BoardStruct) GenBPawnMoves(ml *moves.MoveList) {
 				ml.Add(mv)
 				mv.PackMove(fr, to, BP, cp, BB, b.Ep, b.Castlings)
 				ml.Add(mv)
+				if !b.Variant.Royal() {
+					mv.PackMove(fr, to, BP, cp, BK, b.Ep, b.Castlings)
+					ml.Add(mv)
+				}
 			}
 		}
 		to1Step &= ^Row1
@@ -584,6 +555,10 @@ func (b *BoardStruct) GenWPawnCapt(ml *moves.MoveList) {
 				ml.Add(mv)
 				mv.PackMove(fr, to, WP, cp, WB, b.Ep, b.Castlings)
 				ml.Add(mv)
+				if !b.Variant.Royal() {
+					mv.PackMove(fr, to, WP, cp, WK, b.Ep, b.Castlings)
+					ml.Add(mv)
+				}
 			}
 		}
 		toCapL &= ^Row8
@@ -654,6 +629,10 @@ func (b *BoardStruct) GenBPawnCapt(ml *moves.MoveList) {
 				ml.Add(mv)
 				mv.PackMove(fr, to, BP, cp, BB, b.Ep, b.Castlings)
 				ml.Add(mv)
+				if !b.Variant.Royal() {
+					mv.PackMove(fr, to, BP, cp, BK, b.Ep, b.Castlings)
+					ml.Add(mv)
+				}
 			}
 		}
 		toCapL &= ^Row1
@@ -742,6 +721,29 @@ func (b *BoardStruct) GenAllMoves(ml *moves.MoveList) {
 	b.GenRookMoves(ml, ^b.WbBB[b.Stm])
 	b.GenQueenMoves(ml, ^b.WbBB[b.Stm])
 	b.GenKingMoves(ml, ^b.WbBB[b.Stm])
+	b.GenDrops(ml, ^b.AllBB())
+}
+
+// GenDrops generates the drops from the pocket to targetBB, never pawns to the first or last rank
+func (b *BoardStruct) GenDrops(ml *moves.MoveList, targetBB bitboard.BitBoard) {
+	if !b.Variant.Drops() {
+		return
+	}
+	var mv moves.Move
+	for pt := Pawn; pt <= Queen; pt++ {
+		pc := Pt2pc(pt, b.Stm)
+		if b.Pockets[pc] == 0 {
+			continue
+		}
+		toBB := targetBB
+		if pt == Pawn {
+			toBB &^= Row1 | Row8
+		}
+		for to := toBB.FirstOne(); to != 64; to = toBB.FirstOne() {
+			mv.PackDrop(pc, to, b.Ep, b.Castlings)
+			ml.Add(mv)
+		}
+	}
 }
 
 func (b *BoardStruct) GenAllCaptures(ml *moves.MoveList) {
@@ -783,7 +785,7 @@ func (b *BoardStruct) GenAllCapturesy(ml *moves.MoveList) {
 	pc = Pt2pc(Bishop, us)
 	frBB = b.PieceBB[Bishop] & usBB
 	for fr := frBB.FirstOne(); fr != 64; fr = frBB.FirstOne() {
-		atkBB = magic.MBishopTab[fr].Atks(allBB) & themBB
+		atkBB = magic.BishopAtks(fr, allBB) & themBB
 		for to := atkBB.FirstOne(); to != 64; to = atkBB.FirstOne() {
 			cp := b.Squares[to]
 			mv.PackMove(fr, to, pc, cp, Empty, b.Ep, b.Castlings)
@@ -795,7 +797,7 @@ func (b *BoardStruct) GenAllCapturesy(ml *moves.MoveList) {
 	pc = Pt2pc(Rook, us)
 	frBB = b.PieceBB[Rook] & usBB
 	for fr := frBB.FirstOne(); fr != 64; fr = frBB.FirstOne() {
-		atkBB = magic.MRookTab[fr].Atks(allBB) & themBB
+		atkBB = magic.RookAtks(fr, allBB) & themBB
 		for to := atkBB.FirstOne(); to != 64; to = atkBB.FirstOne() {
 			cp := b.Squares[to]
 			mv.PackMove(fr, to, pc, cp, Empty, b.Ep, b.Castlings)
@@ -807,8 +809,8 @@ func (b *BoardStruct) GenAllCapturesy(ml *moves.MoveList) {
 	pc = Pt2pc(Queen, us)
 	frBB = b.PieceBB[Queen] & usBB
 	for fr := frBB.FirstOne(); fr != 64; fr = frBB.FirstOne() {
-		atkBB = magic.MBishopTab[fr].Atks(allBB) & themBB
-		atkBB |= magic.MRookTab[fr].Atks(allBB) & themBB
+		atkBB = magic.BishopAtks(fr, allBB) & themBB
+		atkBB |= magic.RookAtks(fr, allBB) & themBB
 		for to := atkBB.FirstOne(); to != 64; to = atkBB.FirstOne() {
 			cp := b.Squares[to]
 			mv.PackMove(fr, to, pc, cp, Empty, b.Ep, b.Castlings)
@@ -835,12 +837,19 @@ func (b *BoardStruct) GenAllNonCaptures(ml *moves.MoveList) {
 	b.GenRookMoves(ml, emptyBB)
 	b.GenQueenMoves(ml, emptyBB)
 	b.GenKingMoves(ml, emptyBB)
+	b.GenDrops(ml, emptyBB)
 }
 
-// generates all legal moves
+// generates all legal moves, none if the game is over, and filters them by the variant
 func (b *BoardStruct) GenAllLegals(ml *moves.MoveList) {
+	if _, over := b.Variant.Result(b); over {
+		return
+	}
 	b.GenAllMoves(ml)
 	b.FilterLegals(ml)
+	if f, ok := b.Variant.(MoveFilter); ok {
+		f.Filter(b, ml)
+	}
 }
 
 // generate all legal moves
@@ -0,0 +1,110 @@
+package position
+
+import (
+	. "github.com/Tecu23/go-game/pkg/chess/constants"
+	"github.com/Tecu23/go-game/pkg/chess/moves"
+)
+
+// The KPK positions are normalized so that the pawn is white and on the a-d files.
+// 24 pawn squares * 64 * 64 king squares * 2 sides to move
+const kpkSize = 24 * 64 * 64 * 2
+
+// kpk is the win/draw bitbase for king and pawn against king
+var kpk Bitbase
+
+// InitKPK solves the KPK ending by retrograde analysis
+func InitKPK() {
+	r := Retro{
+		Size:    kpkSize,
+		Strong:  WHITE,
+		Setup:   kpkSetup,
+		Index:   kpkIndexOf,
+		Convert: kpkConvert,
+	}
+	kpk = r.Solve()
+}
+
+// kpkIndex numbers a position from the pawn side's pov. stm is the side to move
+func kpkIndex(strong Color, sk, wk, psq int, stm Color) int {
+	if strong == BLACK {
+		sk, wk, psq = sk^56, wk^56, psq^56
+	}
+	if psq%8 > 3 {
+		sk, wk, psq = sk^7, wk^7, psq^7
+	}
+
+	pawnIx := (psq/8-1)*4 + psq%8
+	ix := (pawnIx*64+sk)*64 + wk
+	if stm == strong {
+		return 2 * ix
+	}
+	return 2*ix + 1
+}
+
+// kpkIndexOf returns the index of b if it is still a KPK position
+func kpkIndexOf(b *BoardStruct) (int, bool) {
+	pawns := b.PieceBB[Pawn]
+	if b.AllBB().Count() != 3 || pawns == 0 {
+		return 0, false
+	}
+
+	psq := pawns.FirstOne()
+	strong := PcColor(b.Squares[psq])
+	return kpkIndex(strong, b.King[strong], b.King[strong.Opposite()], psq, b.Stm), true
+}
+
+// kpkSetup puts the normalized KPK position ix on b
+func kpkSetup(b *BoardStruct, ix int) bool {
+	stm := WHITE
+	if ix%2 == 1 {
+		stm = BLACK
+	}
+	ix /= 2
+	bk, wk, pawnIx := ix%64, ix/64%64, ix/(64*64)
+	psq := (pawnIx/4+1)*8 + pawnIx%4
+
+	b.Clear()
+	if wk == bk || wk == psq || bk == psq || SqDistance(wk, bk) <= 1 {
+		return false
+	}
+
+	b.SetSq(WK, wk)
+	b.SetSq(BK, bk)
+	b.SetSq(WP, psq)
+	if stm == BLACK {
+		b.Stm = BLACK
+		b.Key = ^b.Key
+	}
+
+	// the side that just moved can't be in check
+	return !b.IsAttacked(b.King[stm.Opposite()], stm)
+}
+
+// kpkConvert tells if a position after a capture or a promotion is won for the pawn side.
+// After a promotion the weak side is to move and draws by taking the new piece or by stalemate
+func kpkConvert(b *BoardStruct) bool {
+	strong := b.Stm.Opposite()
+	if b.PieceBB[Queen]|b.PieceBB[Rook]|b.PieceBB[Bishop]|b.PieceBB[Knight] == 0 {
+		return false // the pawn has been captured
+	}
+
+	var ml moves.MoveList
+	b.GenAllLegals(&ml)
+	if len(ml) == 0 {
+		return b.IsAttacked(b.King[b.Stm], strong)
+	}
+	for _, mv := range ml {
+		if mv.Cp() != Empty {
+			return false
+		}
+	}
+
+	return b.PieceBB[Queen]|b.PieceBB[Rook] != 0
+}
+
+// probeKPK returns true if the KPK position on b is won for the strong side
+func probeKPK(b *BoardStruct, strong Color) bool {
+	pawns := b.PieceBB[Pawn] & b.WbBB[strong]
+	psq := pawns.FirstOne()
+	return kpk.IsWin(kpkIndex(strong, b.King[strong], b.King[strong.Opposite()], psq, b.Stm))
+}
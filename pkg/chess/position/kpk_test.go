@@ -0,0 +1,57 @@
+package position
+
+import (
+	"os"
+	"testing"
+
+	"github.com/Tecu23/go-game/pkg/chess/castlings"
+	. "github.com/Tecu23/go-game/pkg/chess/constants"
+	"github.com/Tecu23/go-game/pkg/chess/magic"
+)
+
+func TestMain(m *testing.M) {
+	InitFen2Sq()
+	magic.InitMagic()
+	InitKeys()
+	InitAtksKings()
+	InitAtksKnights()
+	castlings.InitCastlings()
+	PcSqInit()
+	InitEndgames()
+
+	os.Exit(m.Run())
+}
+
+func TestKPK(t *testing.T) {
+	tests := []struct {
+		fen string
+		win bool
+	}{
+		{"4k3/8/4K3/4P3/8/8/8/8 w - - 0 1", true}, // king on the 6th in front of the pawn
+		{"4k3/8/4K3/4P3/8/8/8/8 b - - 0 1", true},
+		{"4k3/8/8/4K3/4P3/8/8/8 w - - 0 1", true},  // white takes the opposition
+		{"4k3/8/8/4K3/4P3/8/8/8 b - - 0 1", false}, // black takes the opposition
+		{"8/4k3/8/8/8/8/4P3/4K3 w - - 0 1", false}, // black king in front of the pawn
+		{"k7/8/K7/P7/8/8/8/8 b - - 0 1", false},    // rook pawn
+		{"6k1/8/8/8/8/8/P7/K7 w - - 0 1", true},    // black king outside the square
+		{"6k1/8/8/8/8/8/P7/K7 b - - 0 1", false},   // black king steps into the square
+		{"8/8/8/8/8/k7/1p6/1K6 b - - 0 1", false},  // black pawn, white king in front
+		{"8/8/8/8/8/8/1pK5/k7 w - - 0 1", true},    // black pawn promotes
+		{"2k5/2P5/2K5/8/8/8/8/8 b - - 0 1", false}, // stalemate
+	}
+
+	for _, tt := range tests {
+		b, err := ParseFEN(tt.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		strong := WHITE
+		if b.Count[BP] != 0 {
+			strong = BLACK
+		}
+
+		if got := probeKPK(b, strong); got != tt.win {
+			t.Errorf("%v: got win=%v, want %v", tt.fen, got, tt.win)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package position
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	. "github.com/Tecu23/go-game/pkg/chess/constants"
+)
+
+// LongDiag is the bonus for a bishop on one of the long diagonals
+var LongDiag = 10
+
+// Param is one tunable evaluation term. Val points into the table the evaluation reads from
+type Param struct {
+	Name string
+	Val  *int
+}
+
+// Params returns all evaluation terms that can be tuned or loaded from a parameter file.
+// Only the white piece values are listed, the black ones are mirrored by InitParams
+func Params() []Param {
+	var ps []Param
+
+	for pt, name := range []string{"Pawn", "Knight", "Bishop", "Rook", "Queen"} {
+		ps = append(ps, Param{"PieceVal." + name, &PieceVal[Pt2pc(pt, WHITE)]})
+	}
+
+	tabs := []struct {
+		name string
+		tab  *[8]int
+	}{
+		{"KnightFile", &KnightFile},
+		{"KnightRank", &KnightRank},
+		{"CenterFile", &CenterFile},
+		{"KingFile", &KingFile},
+		{"KingRank", &KingRank},
+		{"PawnRank", &PawnRank},
+		{"PawnFile", &PawnFile},
+	}
+	for _, t := range tabs {
+		for ix := range t.tab {
+			ps = append(ps, Param{fmt.Sprintf("%v.%v", t.name, ix), &t.tab[ix]})
+		}
+	}
+
+	ps = append(ps, Param{"LongDiag", &LongDiag})
+	return ps
+}
+
+// InitParams recomputes everything derived from the parameters.
+// It must be called after one or more parameters have been changed
+func InitParams() {
+	for pt := Pawn; pt < King; pt++ {
+		PieceVal[Pt2pc(pt, BLACK)] = -PieceVal[Pt2pc(pt, WHITE)]
+	}
+	PcSqInit()
+}
+
+// LoadParams reads a parameter file with one "name value" pair per line.
+// Empty lines and lines starting with # are ignored. The parameters are only changed if
+// the whole file reads without errors
+func LoadParams(r io.Reader) error {
+	byName := make(map[string]*int)
+	for _, p := range Params() {
+		byName[p.Name] = p.Val
+	}
+
+	vals := make(map[*int]int)
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		txt := strings.TrimSpace(scanner.Text())
+		if txt == "" || strings.HasPrefix(txt, "#") {
+			continue
+		}
+
+		fields := strings.Fields(txt)
+		if len(fields) != 2 {
+			return fmt.Errorf("line %v: expected 'name value' but got %q", line, txt)
+		}
+
+		val, ok := byName[fields[0]]
+		if !ok {
+			return fmt.Errorf("line %v: unknown parameter %v", line, fields[0])
+		}
+
+		v, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return fmt.Errorf("line %v: value for %v is not numeric: %v", line, fields[0], fields[1])
+		}
+		vals[val] = v
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	for val, v := range vals {
+		*val = v
+	}
+	InitParams()
+	return nil
+}
+
+// LoadParamsFile reads the parameter file at path, see LoadParams
+func LoadParamsFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return LoadParams(f)
+}
+
+// SaveParams writes all parameters in the format read by LoadParams
+func SaveParams(w io.Writer) error {
+	for _, p := range Params() {
+		if _, err := fmt.Fprintf(w, "%v %v\n", p.Name, *p.Val); err != nil {
+			return err
+		}
+	}
+	return nil
+}
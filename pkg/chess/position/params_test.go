@@ -0,0 +1,57 @@
+package position
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// paramVals returns the current values of all parameters
+func paramVals() []int {
+	var vals []int
+	for _, p := range Params() {
+		vals = append(vals, *p.Val)
+	}
+	return vals
+}
+
+func TestParamsRoundTrip(t *testing.T) {
+	orig := paramVals()
+	var saved bytes.Buffer
+	if err := SaveParams(&saved); err != nil {
+		t.Fatal(err)
+	}
+	defer LoadParams(bytes.NewReader(saved.Bytes()))
+
+	for _, p := range Params() {
+		*p.Val += 7
+	}
+	InitParams()
+
+	if err := LoadParams(bytes.NewReader(saved.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	for ix, v := range paramVals() {
+		if v != orig[ix] {
+			t.Fatalf("%v is %v after the round trip, want %v", Params()[ix].Name, v, orig[ix])
+		}
+	}
+}
+
+func TestLoadParamsErrors(t *testing.T) {
+	orig := paramVals()
+	for _, txt := range []string{
+		"PieceVal.Pawn 120\nPieceVal.Knight\n",
+		"# comment\nPieceVal.Pawn 120\nPieceVal.King 1000\n",
+		"PieceVal.Pawn 120\nLongDiag many\n",
+	} {
+		if err := LoadParams(strings.NewReader(txt)); err == nil {
+			t.Errorf("%q loads without an error", txt)
+		}
+		for ix, v := range paramVals() {
+			if v != orig[ix] {
+				t.Fatalf("%q: %v is %v after a failed load, want %v", txt, Params()[ix].Name, v, orig[ix])
+			}
+		}
+	}
+}
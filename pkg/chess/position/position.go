@@ -7,6 +7,7 @@ import (
 	. "github.com/Tecu23/go-game/pkg/chess/constants"
 	"github.com/Tecu23/go-game/pkg/chess/magic"
 	"github.com/Tecu23/go-game/pkg/chess/moves"
+	"github.com/Tecu23/go-game/pkg/chess/nnue"
 )
 
 // BoardStruct defines all the necessary to generate moves and keep track of a certain position
@@ -18,27 +19,89 @@ type BoardStruct struct {
 	King                [2]int                       // the position of each king
 	Ep                  int                          // en-passant square
 	castlings.Castlings                              // whether each castling is allowed
+	Castl               [2]castlings.Options         // where the kings and rooks castle from, set by ParseFEN
 	Stm                 Color                        // Side To Move
 	Count               [NoPiecesC]int               // 12 counters that count how many pieces we have
 	Rule50              int                          // set to 0 if a pawn or capt move otherwise increment
+	FullMove            int                          // starts at 1 and is incremented after each black move
+	Checks              [2]int                       // the checks given by each side, counted in Three-check
+	Pockets             [NoPiecesC]int               // the captured pieces in hand by pc, in Crazyhouse
+	Promoted            bitboard.BitBoard            // the pieces that were pawns, they go to the pocket as pawns
+	Variant             Variant                      // the rules, set by ParseFENVariant
+	Acc                 nnue.Accumulator             // network hidden layer, only kept up to date when nnue.Net is loaded
+	accNet              *nnue.Network                // the network Acc was computed with, nil if none
+	hist                []undo                       // one entry for each move made on the board
+}
+
+// undo is the state before a move that Unmove and UndoNull restore. Move and MoveNull push
+// one on the stack of the board
+type undo struct {
+	key       uint64 // b.Key, without ep and castlings
+	ep        int
+	castlings castlings.Castlings
+	rule50    int
+	captured  int // the captured piece, also by en passant. Empty if none
+	checks    [2]int
+	promoted  bitboard.BitBoard
+	pocket    int  // the piece the move put in the pocket, Empty if none
+	null      bool // a null move, repetitions don't reach across it
+}
+
+// fullKey is the full key of the position before the move, used for repetitions
+func (u *undo) fullKey() uint64 {
+	return u.key ^ EpKey(u.ep) ^ CastlKey(uint(u.castlings))
+}
+
+// pushUndo pushes the state before a move on the stack and returns it
+func (b *BoardStruct) pushUndo() *undo {
+	b.hist = append(b.hist, undo{
+		key:       b.Key,
+		ep:        b.Ep,
+		castlings: b.Castlings,
+		rule50:    b.Rule50,
+		captured:  Empty,
+		checks:    b.Checks,
+		promoted:  b.Promoted,
+		pocket:    Empty,
+	})
+	return &b.hist[len(b.hist)-1]
 }
 
 // Board defines the actual board that the game will be set up and played in
-var Board = BoardStruct{}
+var Board = BoardStruct{Variant: Standard}
 
 // AllBB should return all bitboards
 func (b *BoardStruct) AllBB() bitboard.BitBoard {
 	return b.WbBB[0] | b.WbBB[1]
 }
 
-// Clear should clear the board, flags, bitboards etc
+// Clone returns a copy of b that can be moved on and taken back independently of b, like by
+// a search thread. A BoardStruct is a value but its undo stack is shared by plain copies
+func (b *BoardStruct) Clone() *BoardStruct {
+	c := *b
+	c.hist = append([]undo(nil), b.hist...)
+	if c.accNet != nnue.Net { // the network has changed since b was set up
+		c.RefreshAcc()
+	}
+	return &c
+}
+
+// Clear should clear the board, flags, bitboards etc. The variant is kept, chess if not set
 func (b *BoardStruct) Clear() {
+	if b.Variant == nil {
+		b.Variant = Standard
+	}
 	b.Stm = WHITE
 	b.Rule50 = 0
+	b.FullMove = 1
 	b.Squares = [64]int{}
 	b.King = [2]int{}
 	b.Ep = 0
 	b.Castlings = 0
+	b.Castl = castlings.Castl
+	b.Checks = [2]int{}
+	b.Pockets = [NoPiecesC]int{}
+	b.Promoted = 0
 
 	for i := A1; i <= H8; i++ {
 		b.Squares[i] = Empty
@@ -54,13 +117,33 @@ func (b *BoardStruct) Clear() {
 	}
 
 	b.Key = 0
+	b.hist = nil
+
+	b.accNet = nnue.Net
+	if nnue.Net != nil {
+		b.Acc.Reset()
+	}
+}
+
+// RefreshAcc recomputes the network accumulator from scratch. Used when a new network is loaded
+func (b *BoardStruct) RefreshAcc() {
+	b.accNet = nnue.Net
+	if nnue.Net == nil {
+		return
+	}
+
+	b.Acc.Reset()
+	for sq := A1; sq <= H8; sq++ {
+		if b.Squares[sq] != Empty {
+			b.Acc.Add(b.Squares[sq], sq)
+		}
+	}
 }
 
-// NewGame should start a new game from the starting position
+// NewGame should start a new game of the same variant from the starting position
 func (b *BoardStruct) NewGame() {
-	b.Stm = WHITE
-	b.Clear()
-	ParseFEN(Startpos)
+	start, _ := ParseFENVariant(Startpos, b.Variant, false)
+	*b = *start
 }
 
 // SetSq should set a square sq to a particular piece pc
@@ -75,6 +158,9 @@ func (b *BoardStruct) SetSq(pc, sq int) {
 		b.WbBB[sd^0x1].Clear(sq)
 		b.PieceBB[Pc2pt(cp)].Clear(sq)
 		b.Key ^= PcSqKey(cp, sq)
+		if nnue.Net != nil {
+			b.Acc.Sub(cp, sq)
+		}
 	}
 
 	b.Squares[sq] = pc
@@ -89,6 +175,9 @@ func (b *BoardStruct) SetSq(pc, sq int) {
 	}
 
 	b.Key ^= PcSqKey(pc, sq)
+	if nnue.Net != nil {
+		b.Acc.Add(pc, sq)
+	}
 
 	b.Count[pc]++
 
@@ -103,82 +192,110 @@ func (b *BoardStruct) SetSq(pc, sq int) {
 // Move should make a move on the board
 func (b *BoardStruct) Move(mv moves.Move) bool {
 	newEp := 0
+	u := b.pushUndo()
+	if mv.IsDrop() {
+		return b.drop(mv)
+	}
 
 	// Assume that the move is legally correct (except for inCheck())
 	fr := mv.Fr()
 	to := mv.To()
 	pr := mv.Pr()
 	pc := b.Squares[fr]
+	sd := PcColor(pc)
+	castle := Pc2pt(pc) == King && b.Squares[to] == Pt2pc(Rook, sd) // the king takes its own rook
+	captured := b.Squares[to]
+	if castle {
+		captured = Empty
+	}
+
+	b.Rule50++
+	if Pc2pt(pc) == Pawn || b.Squares[to] != Empty && !castle {
+		b.Rule50 = 0
+	}
+	if b.Stm == BLACK {
+		b.FullMove++
+	}
 
 	switch {
-	case pc == WK:
-		b.Castlings.Off(castlings.ShortW | castlings.LongW)
-
-		if Abs(int(to)-int(fr)) == 2 {
-			if to == G1 {
-				b.SetSq(WR, F1)
-				b.SetSq(Empty, H1)
-			} else {
-				b.SetSq(WR, D1)
-				b.SetSq(Empty, A1)
-			}
-		}
-	case pc == BK:
-		b.Castlings.Off(castlings.ShortB | castlings.LongB)
-
-		if Abs(int(to)-int(fr)) == 2 {
-			if to == G8 {
-				b.SetSq(BR, F8)
-				b.SetSq(Empty, H8)
-			} else {
-				b.SetSq(BR, D8)
-				b.SetSq(Empty, A8)
-			}
-		}
-	case pc == WR:
-		if fr == A1 {
-			b.Off(castlings.LongW)
-		} else if fr == H1 {
-			b.Off(castlings.ShortW)
-		}
-	case pc == BR:
-		if fr == A8 {
-			b.Off(castlings.LongB)
-		} else if fr == H8 {
-			b.Off(castlings.ShortB)
-		}
+	case Pc2pt(pc) == King:
+		b.Castlings.Off(b.Castl[sd].Short | b.Castl[sd].Long)
 	case pc == WP && b.Squares[to] == Empty: // en passant move or set en passant
 		if to-fr == 16 {
 			newEp = fr + 8
 		} else if to-fr == 7 { // must be ep
 			b.SetSq(Empty, to-8)
+			captured = BP
 		} else if to-fr == 9 { // must be ep
 			b.SetSq(Empty, to-8)
+			captured = BP
 		}
 	case pc == BP && b.Squares[to] == Empty: // en passant move or set en passant
 		if fr-to == 16 {
 			newEp = to + 8
 		} else if fr-to == 7 { // must be ep
 			b.SetSq(Empty, to+8)
+			captured = WP
 		} else if fr-to == 9 { // must be ep
 			b.SetSq(Empty, to+8)
+			captured = WP
 		}
 	}
 
-	b.Ep = newEp
-	b.SetSq(Empty, fr)
+	// a rook that moves or is captured takes its castling right with it
+	for s := WHITE; s <= BLACK; s++ {
+		if fr == b.Castl[s].RookSh || to == b.Castl[s].RookSh {
+			b.Off(b.Castl[s].Short)
+		}
+		if fr == b.Castl[s].RookL || to == b.Castl[s].RookL {
+			b.Off(b.Castl[s].Long)
+		}
+	}
 
-	if pr != Empty {
+	// in Crazyhouse the captured piece goes to the pocket, a promoted piece as a pawn
+	if captured != Empty && b.Variant.Drops() {
+		pt := Pc2pt(captured)
+		if b.Promoted.IsBitSet(to) {
+			pt = Pawn
+		}
+		pocket := Pt2pc(pt, sd)
+		b.setPocket(pocket, b.Pockets[pocket]+1)
+		u.pocket = pocket
+	}
+	u.captured = captured
+	if b.Promoted != 0 || pr != Empty && b.Variant.Drops() {
+		promoted := b.Promoted.IsBitSet(fr) || pr != Empty
+		b.Promoted.Clear(fr)
+		b.Promoted.Clear(to)
+		if promoted {
+			b.Promoted.SetBit(to)
+		}
+	}
+
+	b.Ep = newEp
+	switch {
+	case castle:
+		kingTo, rookTo := b.Castl[sd].KingToSh, b.Castl[sd].RookToSh
+		if to == b.Castl[sd].RookL {
+			kingTo, rookTo = b.Castl[sd].KingToL, b.Castl[sd].RookToL
+		}
+		b.SetSq(Empty, fr)
+		b.SetSq(Empty, to)
+		b.SetSq(pc, kingTo)
+		b.SetSq(b.Castl[sd].Rook, rookTo)
+	case pr != Empty:
+		b.SetSq(Empty, fr)
 		b.SetSq(pr, to)
-	} else {
+	default:
+		b.SetSq(Empty, fr)
 		b.SetSq(pc, to)
 	}
 
 	b.Key = ^b.Key
 	b.Stm = b.Stm ^ 0x1
 
-	// Undo the move if the king is in check
-	if b.IsAttacked(b.King[b.Stm^0x1], b.Stm) {
+	// Undo the move if the variant doesn't allow it, in chess if the king is in check
+	if !b.Variant.AfterMove(b) {
 		b.Unmove(mv)
 		return false
 	}
@@ -186,41 +303,171 @@ func (b *BoardStruct) Move(mv moves.Move) bool {
 	return true
 }
 
-// Unmove undoes a certain move
-func (b *BoardStruct) Unmove(mv moves.Move) {
-	b.Ep = mv.Ep(b.Stm.Opposite())
-	b.Castlings = mv.Castl()
+// drop makes mv, a drop of a piece from the pocket
+func (b *BoardStruct) drop(mv moves.Move) bool {
+	pc, to := mv.Pc(), mv.To()
+	b.Rule50++
+	if b.Stm == BLACK {
+		b.FullMove++
+	}
+
+	b.Ep = 0
+	b.setPocket(pc, b.Pockets[pc]-1)
+	b.SetSq(pc, to)
+
+	b.Key = ^b.Key
+	b.Stm = b.Stm ^ 0x1
+	if !b.Variant.AfterMove(b) {
+		b.Unmove(mv)
+		return false
+	}
+	return true
+}
+
+// setPocket sets the number of pc in hand to n and keeps the key up to date
+func (b *BoardStruct) setPocket(pc, n int) {
+	b.Key ^= PocketKey(pc, b.Pockets[pc]) ^ PocketKey(pc, n)
+	b.Pockets[pc] = n
+}
 
+// Unmove takes back mv, the last move made, with the state on the undo stack
+func (b *BoardStruct) Unmove(mv moves.Move) {
+	u := &b.hist[len(b.hist)-1]
 	pc := int(mv.Pc())
 	fr := int(mv.Fr())
 	to := int(mv.To())
 
-	b.SetSq(mv.Cp(), to)
-	b.SetSq(pc, fr)
-
-	if Pc2pt(pc) == Pawn {
-		if to == b.Ep && b.Ep != 0 {
-			b.SetSq(Empty, to)
-			switch to - fr {
-			case NW, NE:
-				b.SetSq(BP, to-N)
-			case SW, SE:
-				b.SetSq(WP, to-S)
+	// a captured piece is taken back from the pocket
+	if u.pocket != Empty {
+		b.setPocket(u.pocket, b.Pockets[u.pocket]-1)
+	}
 
-			}
-		}
-	} else if Pc2pt(pc) == King {
+	switch {
+	case mv.IsDrop():
+		b.SetSq(Empty, to)
+		b.setPocket(pc, b.Pockets[pc]+1)
+	case b.IsCastling(mv):
 		sd := PcColor(pc)
-		if fr-to == 2 {
-			b.SetSq(castlings.Castl[sd].Rook, int(castlings.Castl[sd].RookL))
-			b.SetSq(Empty, fr-1)
-		} else if fr-to == -2 {
-			b.SetSq(castlings.Castl[sd].Rook, int(castlings.Castl[sd].RookSh))
-			b.SetSq(Empty, fr+1)
+		kingTo, rookTo := b.Castl[sd].KingToSh, b.Castl[sd].RookToSh
+		if to == b.Castl[sd].RookL {
+			kingTo, rookTo = b.Castl[sd].KingToL, b.Castl[sd].RookToL
 		}
+		b.SetSq(Empty, kingTo)
+		b.SetSq(Empty, rookTo)
+		b.SetSq(pc, fr)
+		b.SetSq(b.Castl[sd].Rook, to)
+	case Pc2pt(pc) == Pawn && to == u.ep && u.ep != 0: // en passant, the pawn was beside to
+		b.SetSq(Empty, to)
+		b.SetSq(u.captured, to-8+16*int(PcColor(pc)))
+		b.SetSq(pc, fr)
+	default:
+		b.SetSq(u.captured, to)
+		b.SetSq(pc, fr)
 	}
-	b.Key = ^b.Key
+
 	b.Stm = b.Stm ^ 0x1
+	if b.Stm == BLACK {
+		b.FullMove--
+	}
+	b.Key = u.key
+	b.Ep = u.ep
+	b.Castlings = u.castlings
+	b.Rule50 = u.rule50
+	b.Checks = u.checks
+	b.Promoted = u.promoted
+	b.hist = b.hist[:len(b.hist)-1]
+}
+
+// IsCastling tells if mv, a move on b, is a castling. Castlings are encoded as the king
+// taking its own rook, e1h1 for O-O in chess
+func (b *BoardStruct) IsCastling(mv moves.Move) bool {
+	pc := mv.Pc()
+	if Pc2pt(pc) != King {
+		return false
+	}
+	sd, c, to := PcColor(pc), mv.Castl(), mv.To()
+	return c.ShortFlag(sd) && to == b.Castl[sd].RookSh || c.LongFlag(sd) && to == b.Castl[sd].RookL
+}
+
+// Compact returns mv, a move on b, in 16 bits
+func (b *BoardStruct) Compact(mv moves.Move) moves.Move16 {
+	switch {
+	case mv == moves.NoMove:
+		return moves.NoMove16
+	case mv.IsDrop():
+		return moves.NewMove16(mv.To(), mv.To(), moves.FlagDrop+Pc2pt(mv.Pc()))
+	case mv.Pr() != Empty:
+		return moves.NewMove16(mv.Fr(), mv.To(), Pc2pt(mv.Pr()))
+	case b.IsCastling(mv):
+		return moves.NewMove16(mv.Fr(), mv.To(), moves.FlagCastling)
+	case Pc2pt(mv.Pc()) == Pawn && mv.To() == b.Ep && b.Ep != 0:
+		return moves.NewMove16(mv.Fr(), mv.To(), moves.FlagEp)
+	}
+	return moves.NewMove16(mv.Fr(), mv.To(), moves.FlagNormal)
+}
+
+// Expand returns m as a move on b with the pieces, ep square and castling rights of b.
+// NoMove if m doesn't fit b, like a move from an empty square or a castling that is gone.
+// The move is not checked further, see IsLegal
+func (b *BoardStruct) Expand(m moves.Move16) moves.Move {
+	var mv moves.Move
+	if m == moves.NoMove16 {
+		return moves.NoMove
+	}
+	fr, to := m.Fr(), m.To()
+	if m.IsDrop() {
+		if m.Drop() > Queen {
+			return moves.NoMove
+		}
+		mv.PackDrop(Pt2pc(m.Drop(), b.Stm), to, b.Ep, b.Castlings)
+		return mv
+	}
+
+	pc, cp := b.Squares[fr], b.Squares[to]
+	if pc == Empty || PcColor(pc) != b.Stm || fr == to {
+		return moves.NoMove
+	}
+	pr := Empty
+	switch flag := m.Flag(); {
+	case m.Promo() != Empty:
+		if Pc2pt(pc) != Pawn {
+			return moves.NoMove
+		}
+		pr = Pt2pc(m.Promo(), b.Stm)
+	case flag == moves.FlagEp:
+		if Pc2pt(pc) != Pawn || to != b.Ep || b.Ep == 0 {
+			return moves.NoMove
+		}
+		cp = Pt2pc(Pawn, b.Stm.Opposite())
+	case flag == moves.FlagCastling:
+		if Pc2pt(pc) != King || cp != Pt2pc(Rook, b.Stm) {
+			return moves.NoMove
+		}
+		cp = Empty
+	case flag != moves.FlagNormal:
+		return moves.NoMove
+	}
+	mv.PackMove(fr, to, pc, cp, pr, b.Ep, b.Castlings)
+	if m.Flag() == moves.FlagCastling && !b.IsCastling(mv) {
+		return moves.NoMove
+	}
+	return mv
+}
+
+// IsRepetition returns true if the current position has occurred before with the same side to move.
+// Only the positions since the last capture, pawn move or null move are looked at
+func (b *BoardStruct) IsRepetition() bool {
+	key := b.FullKey()
+	for n := 1; n <= b.Rule50 && n <= len(b.hist); n++ {
+		u := &b.hist[len(b.hist)-n]
+		if u.null {
+			return false
+		}
+		if n%2 == 0 && u.fullKey() == key {
+			return true
+		}
+	}
+	return false
 }
 
 // isAttacked should return whether the sq is attacked by the sd color side
@@ -235,36 +482,40 @@ func (b *BoardStruct) IsAttacked(to int, sd Color) bool {
 	if AtksKings[to]&b.PieceBB[King]&b.WbBB[sd] != 0 {
 		return true
 	}
-	if (magic.MBishopTab[to].Atks(b.AllBB()) & (b.PieceBB[Bishop] | b.PieceBB[Queen]) & b.WbBB[sd]) != 0 {
+	if (magic.BishopAtks(to, b.AllBB()) & (b.PieceBB[Bishop] | b.PieceBB[Queen]) & b.WbBB[sd]) != 0 {
 		return true
 	}
-	if (magic.MRookTab[to].Atks(b.AllBB()) & (b.PieceBB[Rook] | b.PieceBB[Queen]) & b.WbBB[sd]) != 0 {
+	if (magic.RookAtks(to, b.AllBB()) & (b.PieceBB[Rook] | b.PieceBB[Queen]) & b.WbBB[sd]) != 0 {
 		return true
 	}
 
 	return false
 }
 
-func (b *BoardStruct) MoveNull() moves.Move {
-	mv := moves.NoMove
-	mv.PackMove(0, 0, Empty, Empty, Empty, b.Ep, b.Castlings)
-
+// MoveNull passes the move to the other side, for null move pruning
+func (b *BoardStruct) MoveNull() {
+	b.pushUndo().null = true
 	b.Ep = 0
 	b.Key = ^b.Key
 	b.Stm = b.Stm ^ 0x1
-	return mv
 }
 
-func (b *BoardStruct) UndoNull(mv moves.Move) {
-	b.Key = ^b.Key
+// UndoNull takes back the null move made last
+func (b *BoardStruct) UndoNull() {
+	u := &b.hist[len(b.hist)-1]
+	b.Key = u.key
+	b.Ep = u.ep
 	b.Stm = b.Stm ^ 0x1
-
-	b.Ep = mv.Ep(b.Stm)
-	// b.castlings = mv.castl()     // no need!
+	b.hist = b.hist[:len(b.hist)-1]
 }
 
 // is the move legal (except from inCheck)
 func (b *BoardStruct) IsLegal(mv moves.Move) bool {
+	if mv.IsDrop() {
+		pc, to := mv.Pc(), mv.To()
+		return b.Variant.Drops() && pc < NoPiecesC && b.Pockets[pc] > 0 && b.Stm == PcColor(pc) &&
+			b.Squares[to] == Empty && (Pc2pt(pc) != Pawn || !(Row1 | Row8).IsBitSet(to))
+	}
 	fr := mv.Fr()
 	pc := mv.Pc()
 	if b.Squares[fr] != pc || pc == Empty {
@@ -276,6 +527,16 @@ func (b *BoardStruct) IsLegal(mv moves.Move) bool {
 
 	to := mv.To()
 	cp := mv.Cp()
+	if pr := mv.Pr(); pr != Empty || Pc2pt(pc) == Pawn && (Row1|Row8).IsBitSet(to) { // promotions
+		if Pc2pt(pc) != Pawn || !(Row1 | Row8).IsBitSet(to) || pr == Empty || PcColor(pr) != b.Stm ||
+			Pc2pt(pr) == Pawn || Pc2pt(pr) == King && b.Variant.Royal() {
+			return false
+		}
+	}
+	if Pc2pt(pc) == King && cp == Empty && b.Squares[to] == Pt2pc(Rook, b.Stm) { // castlings
+		return to == b.Castl[b.Stm].RookSh && b.IsShortOk(b.Stm) ||
+			to == b.Castl[b.Stm].RookL && b.IsLongOk(b.Stm)
+	}
 	if !((pc == WP || pc == BP) && to == b.Ep && b.Ep != 0) {
 		if b.Squares[to] != cp {
 			return false
@@ -292,14 +553,14 @@ func (b *BoardStruct) IsLegal(mv moves.Move) bool {
 				return true
 			}
 		} else if to-fr == 16 {
-			if b.Squares[fr+8] == Empty && b.Squares[fr+16] == Empty { // wP two step
+			if fr/8 == 1 && b.Squares[fr+8] == Empty && b.Squares[fr+16] == Empty { // wP two step
 				return true
 			}
-		} else if b.Ep == mv.Ep(b.Stm) && b.Squares[to-8] == BP { // wP ep
-			return true
-		} else if to-fr == 7 && cp != Empty { // wP capture left
+		} else if !PawnAtks(WHITE, fr).IsBitSet(to) { // not a capture either
+			return false
+		} else if to == b.Ep && b.Ep != 0 && b.Squares[to-8] == BP { // wP ep
 			return true
-		} else if to-fr == 9 && cp != Empty { // wp capture right
+		} else if cp != Empty { // wP capture left or right
 			return true
 		}
 
@@ -310,97 +571,43 @@ func (b *BoardStruct) IsLegal(mv moves.Move) bool {
 				return true
 			}
 		} else if fr-to == 16 {
-			if b.Squares[fr-8] == Empty && b.Squares[fr-16] == Empty { // bP two step
+			if fr/8 == 6 && b.Squares[fr-8] == Empty && b.Squares[fr-16] == Empty { // bP two step
 				return true
 			}
-		} else if b.Ep == mv.Ep(b.Stm) && b.Squares[to+8] == WP { // bP ep
-			return true
-		} else if fr-to == 7 && cp != Empty { // bP capture right
+		} else if !PawnAtks(BLACK, fr).IsBitSet(to) { // not a capture either
+			return false
+		} else if to == b.Ep && b.Ep != 0 && b.Squares[to+8] == WP { // bP ep
 			return true
-		} else if fr-to == 9 && cp != Empty { // bp capture left
+		} else if cp != Empty { // bP capture left or right
 			return true
 		}
 
 		return false
+	case pc == WN, pc == BN:
+		return AtksKnights[fr].IsBitSet(to)
 	case pc == WB, pc == BB:
 		toBB := bitboard.BitBoard(1) << uint(to)
-		if magic.MBishopTab[fr].Atks(b.AllBB())&toBB != 0 {
+		if magic.BishopAtks(fr, b.AllBB())&toBB != 0 {
 			return true
 		}
 		return false
 	case pc == WR, pc == BR:
 		toBB := bitboard.BitBoard(1) << uint(to)
-		if magic.MRookTab[fr].Atks(b.AllBB())&toBB != 0 {
+		if magic.RookAtks(fr, b.AllBB())&toBB != 0 {
 			return true
 		}
 		return false
 	case pc == WQ, pc == BQ:
 		toBB := bitboard.BitBoard(1) << uint(to)
-		if magic.MBishopTab[fr].Atks(b.AllBB())&toBB != 0 {
+		if magic.BishopAtks(fr, b.AllBB())&toBB != 0 {
 			return true
 		}
-		if magic.MRookTab[fr].Atks(b.AllBB())&toBB != 0 {
+		if magic.RookAtks(fr, b.AllBB())&toBB != 0 {
 			return true
 		}
 		return false
-	case pc == WK:
-		if Abs(int(to)-int(fr)) == 2 { // castlings
-			if to == G1 {
-				if b.Squares[H1] != WR || b.Squares[E1] != WK {
-					return false
-				}
-
-				if b.Squares[F1] != Empty || b.Squares[G1] != Empty {
-					return false
-				}
-
-				if !b.IsShortOk(b.Stm) {
-					return false
-				}
-			} else {
-				if b.Squares[A1] != WR || b.Squares[E1] != WK {
-					return false
-				}
-				if to != C1 {
-					return false
-				}
-				if b.Squares[B1] != Empty || b.Squares[C1] != Empty || b.Squares[D1] != Empty {
-					return false
-				}
-				if !b.IsLongOk(b.Stm) {
-					return false
-				}
-			}
-		}
-		return true
-	case pc == BK:
-		if Abs(int(to)-int(fr)) == 2 { // castlings
-			if to == G8 {
-				if b.Squares[H8] != BR || b.Squares[E8] != BK {
-					return false
-				}
-				if b.Squares[F8] != Empty || b.Squares[G8] != Empty {
-					return false
-				}
-				if !b.IsShortOk(b.Stm) {
-					return false
-				}
-			} else {
-				if b.Squares[A8] != BR || b.Squares[E8] != BK {
-					return false
-				}
-				if to != C8 {
-					return false
-				}
-				if b.Squares[B8] != Empty || b.Squares[C8] != Empty || b.Squares[D8] != Empty {
-					return false
-				}
-				if !b.IsLongOk(b.Stm) {
-					return false
-				}
-			}
-		}
-		return true
+	case pc == WK, pc == BK:
+		return AtksKings[fr].IsBitSet(to)
 	}
 
 	return true
@@ -408,94 +615,45 @@ func (b *BoardStruct) IsLegal(mv moves.Move) bool {
 
 // check if short castlings is legal
 func (b *BoardStruct) IsShortOk(sd Color) bool {
-	if !b.ShortFlag(sd) {
-		return false
-	}
-
-	opp := sd ^ 0x1
-	if castlings.Castl[sd].PawnsSh&b.PieceBB[Pawn]&b.WbBB[opp] != 0 { // stopped by pawns?
-		return false
-	}
-	if castlings.Castl[sd].PawnsSh&b.PieceBB[King]&b.WbBB[opp] != 0 { // stopped by king?
-		return false
-	}
-	if castlings.Castl[sd].KnightsSh&b.PieceBB[Knight]&b.WbBB[opp] != 0 { // stopped by Knights?
-		return false
-	}
-
-	// sliding to e1/e8	//NOTE: Maybe not needed during search because we know if we are in check
-	sq := b.King[sd]
-	if (magic.MBishopTab[sq].Atks(b.AllBB()) & (b.PieceBB[Bishop] | b.PieceBB[Queen]) & b.WbBB[opp]) != 0 {
-		return false
-	}
-	if (magic.MRookTab[sq].Atks(b.AllBB()) & (b.PieceBB[Rook] | b.PieceBB[Queen]) & b.WbBB[opp]) != 0 {
-		return false
-	}
-
-	// slidings to f1/f8
-	if (magic.MBishopTab[sq+1].Atks(b.AllBB()) & (b.PieceBB[Bishop] | b.PieceBB[Queen]) & b.WbBB[opp]) != 0 {
-		return false
-	}
-	if (magic.MRookTab[sq+1].Atks(b.AllBB()) & (b.PieceBB[Rook] | b.PieceBB[Queen]) & b.WbBB[opp]) != 0 {
-		return false
-	}
-
-	// slidings to g1/g8		//NOTE: Maybe not needed because we always make isAttacked() after a move
-	if (magic.MBishopTab[sq+2].Atks(b.AllBB()) & (b.PieceBB[Bishop] | b.PieceBB[Queen]) & b.WbBB[opp]) != 0 {
-		return false
-	}
-	if (magic.MRookTab[sq+2].Atks(b.AllBB()) & (b.PieceBB[Rook] | b.PieceBB[Queen]) & b.WbBB[opp]) != 0 {
-		return false
-	}
-	return true
+	opt := &b.Castl[sd]
+	return b.castlingOk(sd, opt.Short, opt.RookSh, opt.BetweenSh, opt.PathSh)
 }
 
 // check if long castlings is legal
 func (b *BoardStruct) IsLongOk(sd Color) bool {
-	if !b.LongFlag(sd) {
-		return false
-	}
-
-	opp := sd ^ 0x1
-	if castlings.Castl[sd].PawnsL&b.PieceBB[Pawn]&b.WbBB[opp] != 0 {
-		return false
-	}
-	if castlings.Castl[sd].PawnsL&b.PieceBB[King]&b.WbBB[opp] != 0 {
-		return false
-	}
-	if castlings.Castl[sd].KnightsL&b.PieceBB[Knight]&b.WbBB[opp] != 0 {
-		return false
-	}
+	opt := &b.Castl[sd]
+	return b.castlingOk(sd, opt.Long, opt.RookL, opt.BetweenL, opt.PathL)
+}
 
-	// sliding e1/e8
-	sq := b.King[sd]
-	if (magic.MBishopTab[sq].Atks(b.AllBB()) & (b.PieceBB[Bishop] | b.PieceBB[Queen]) & b.WbBB[opp]) != 0 {
+// castlingOk checks the flag, that the king and the rook are on their squares, that the
+// squares between are empty and that the king doesn't go over an attacked square. The
+// square the king ends on is checked by Move, as for any king move
+func (b *BoardStruct) castlingOk(sd Color, flag uint, rook int, between, path bitboard.BitBoard) bool {
+	if uint(b.Castlings)&flag == 0 {
 		return false
 	}
-	if (magic.MRookTab[sq].Atks(b.AllBB()) & (b.PieceBB[Rook] | b.PieceBB[Queen]) & b.WbBB[opp]) != 0 {
+	if b.King[sd] != b.Castl[sd].KingPos || b.Squares[rook] != b.Castl[sd].Rook {
 		return false
 	}
-
-	// sliding d1/d8
-	if (magic.MBishopTab[sq-1].Atks(b.AllBB()) & (b.PieceBB[Bishop] | b.PieceBB[Queen]) & b.WbBB[opp]) != 0 {
-		return false
-	}
-	if (magic.MRookTab[sq-1].Atks(b.AllBB()) & (b.PieceBB[Rook] | b.PieceBB[Queen]) & b.WbBB[opp]) != 0 {
+	if between&b.AllBB() != 0 {
 		return false
 	}
 
-	// sliding c1/c8	//NOTE: Maybe not needed because we always make inCheck() before a move
-	if (magic.MBishopTab[sq-2].Atks(b.AllBB()) & (b.PieceBB[Bishop] | b.PieceBB[Queen]) & b.WbBB[opp]) != 0 {
-		return false
-	}
-	if (magic.MRookTab[sq-2].Atks(b.AllBB()) & (b.PieceBB[Rook] | b.PieceBB[Queen]) & b.WbBB[opp]) != 0 {
-		return false
+	for sq := path.FirstOne(); sq != 64; sq = path.FirstOne() {
+		// IsAttacked only sees the pawns that attack a piece
+		if b.IsAttacked(sq, sd.Opposite()) || pawnAtksFr[sd](b, sq)&b.PieceBB[Pawn] != 0 {
+			return false
+		}
 	}
 	return true
 }
 
-// is this a position to avoid null move?
+// is this a position to avoid null move? Also in the variants that filter the moves, a pass
+// skips the moves they force
 func (b *BoardStruct) IsAntiNullMove() bool {
+	if _, ok := b.Variant.(MoveFilter); ok {
+		return true
+	}
 	if b.WbBB[b.Stm] == b.PieceBB[King]&b.WbBB[b.Stm] {
 		return true
 	}
@@ -0,0 +1,138 @@
+package position
+
+import (
+	"math/rand"
+	"testing"
+
+	. "github.com/Tecu23/go-game/pkg/chess/constants"
+	"github.com/Tecu23/go-game/pkg/chess/moves"
+)
+
+// TestUndoStack makes every pseudo legal move and null move in the positions of random games
+// and checks that the board is restored exactly, also when the move only has its from, to,
+// piece and promotion: Unmove takes the rest from the undo stack
+func TestUndoStack(t *testing.T) {
+	rnd := rand.New(rand.NewSource(3))
+	for _, seed := range fenSeeds {
+		b, err := ParseFEN(seed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for ply := 0; ply < 80; ply++ {
+			before := b.Clone()
+
+			var ml moves.MoveList
+			b.GenAllMoves(&ml)
+			for _, mv := range ml {
+				legal := b.Move(mv)
+				if legal {
+					bare := mv
+					if !before.IsCastling(mv) {
+						bare.PackMove(mv.Fr(), mv.To(), mv.Pc(), Empty, mv.Pr(), 0, mv.Castl())
+					}
+					b.Unmove(bare)
+				}
+				if !sameBoard(b, before) || len(b.hist) != len(before.hist) {
+					t.Fatalf("%v: got %v after %v and Unmove", before.FEN(), b.FEN(), b.UCI(mv))
+				}
+			}
+
+			b.MoveNull()
+			if b.Ep != 0 || b.Stm == before.Stm {
+				t.Fatalf("%v: got %v after the null move", before.FEN(), b.FEN())
+			}
+			b.UndoNull()
+			if !sameBoard(b, before) {
+				t.Fatalf("%v: got %v after UndoNull", before.FEN(), b.FEN())
+			}
+
+			b.GenAllLegals(&ml)
+			if len(ml) == 0 {
+				break
+			}
+			b.Move(ml[rnd.Intn(len(ml))])
+		}
+	}
+}
+
+func TestClone(t *testing.T) {
+	b, err := ParseFEN(fenSeeds[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Move(findMove(t, b, "e2a6"))
+	fen := b.FEN()
+
+	// moves on the clone and on b don't disturb each other, also not in the undo stack
+	c := b.Clone()
+	cMv, bMv := findMove(t, c, "b4c3"), findMove(t, b, "e8g8")
+	c.Move(cMv)
+	b.Move(bMv)
+	if c.Squares[C3] != BP || b.Squares[G8] != BK || c.Squares[G8] != Empty {
+		t.Fatalf("got %v and %v", b.FEN(), c.FEN())
+	}
+	c.Unmove(cMv)
+	b.Unmove(bMv)
+	if b.FEN() != fen || c.FEN() != fen || !sameBoard(b, c) {
+		t.Errorf("got %v and %v after Unmove, want %v", b.FEN(), c.FEN(), fen)
+	}
+	if len(c.hist) != len(b.hist) || c.hist[0] != b.hist[0] {
+		t.Errorf("the clone has another history")
+	}
+}
+
+// TestCompact packs every legal move of random games in 16 bits and expands it on the board
+// again. Expanding all 16 bit values of some positions gives no other legal moves
+func TestCompact(t *testing.T) {
+	bs := attackPositions(t)
+	zh, err := ParseFENVariant("r1bqk2r/pppp1ppp/2n1p3/4P3/1b1Pn3/2NB1N2/PPP2PPP/R1BQK2R[Pn] b KQkq - 0 1", Crazyhouse, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs = append(bs, zh)
+
+	for _, b := range bs {
+		var ml moves.MoveList
+		b.GenAllLegals(&ml)
+		seen := map[moves.Move16]bool{}
+		for _, mv := range ml {
+			m := b.Compact(mv)
+			if got := b.Expand(m); got != mv || !m.Is(mv) || seen[m] {
+				t.Fatalf("%v: %v is %v in 16 bits and %v expanded", b.FEN(), b.UCI(mv), m, b.UCI(got))
+			}
+			seen[m] = true
+		}
+	}
+
+	for ix := len(bs) - 1; ix >= 0; ix -= len(bs) / 12 { // the crazyhouse position first
+		b := bs[ix]
+		var ml moves.MoveList
+		b.GenAllMoves(&ml)
+		for m := 0; m < 1<<16; m++ {
+			mv := b.Expand(moves.Move16(m))
+			if mv == moves.NoMove || !b.IsLegal(mv) {
+				continue
+			}
+			found := false
+			for _, mv2 := range ml {
+				found = found || mv2 == mv
+			}
+			if !found {
+				t.Fatalf("%v: %v expands to %v that is not generated", b.FEN(), moves.Move16(m), mv.StringFull())
+			}
+		}
+	}
+}
+
+func TestTransMove16(t *testing.T) {
+	b, _ := ParseFEN(fenSeeds[1])
+	mv := findMove(t, b, "e1h1")
+	if err := Trans.New(1); err != nil {
+		t.Fatal(err)
+	}
+	Trans.Store(b.FullKey(), b.Compact(mv), 3, 0, 25, ScoreTypeLower)
+	m, sc, _, ok := Trans.Retrieve(b.FullKey(), 3, 0)
+	if !ok || sc != 25 || b.Expand(m) != mv || m.Flag() != moves.FlagCastling {
+		t.Errorf("got %v %v %v, want the castling %v", m, sc, ok, b.UCI(mv))
+	}
+}
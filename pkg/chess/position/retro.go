@@ -0,0 +1,150 @@
+package position
+
+import (
+	. "github.com/Tecu23/go-game/pkg/chess/constants"
+	"github.com/Tecu23/go-game/pkg/chess/moves"
+)
+
+// Bitbase holds one bit per position of a small ending. A set bit means the strong side wins
+type Bitbase []uint64
+
+// NewBitbase allocates a bitbase for size positions, all of them draws
+func NewBitbase(size int) Bitbase {
+	return make(Bitbase, (size+63)/64)
+}
+
+// IsWin returns true if the position with index ix is won for the strong side
+func (bb Bitbase) IsWin(ix int) bool {
+	return bb[ix/64]&(1<<uint(ix%64)) != 0
+}
+
+// SetWin marks the position with index ix as won for the strong side
+func (bb Bitbase) SetWin(ix int) {
+	bb[ix/64] |= 1 << uint(ix%64)
+}
+
+// Retro describes a small ending for the retrograde solver. Every position in the
+// ending is numbered from 0 to Size-1 by Index and can be set up again by Setup
+type Retro struct {
+	Size   int
+	Strong Color
+
+	// Setup puts position ix on b and returns false if ix is not a legal position
+	Setup func(b *BoardStruct, ix int) bool
+	// Index returns the number of the position on b, or false if b has left the ending (capture or promotion)
+	Index func(b *BoardStruct) (int, bool)
+	// Convert returns true if a position that has left the ending is won for the strong side
+	Convert func(b *BoardStruct) bool
+}
+
+// results during the retrograde analysis
+const (
+	retroUnknown = iota
+	retroInvalid
+	retroDraw
+	retroWin
+)
+
+// children that have left the ending are stored with their result instead of an index
+const (
+	childDraw = -1 - iota
+	childWin
+)
+
+// Solve finds all won positions of the ending. The children of every position are
+// generated once with the move generator. Then positions that only lead to known
+// results are resolved in passes over the whole ending, until a pass doesn't change
+// anything. The positions still unknown are draws
+func (r *Retro) Solve() Bitbase {
+	var b BoardStruct
+	var ml moves.MoveList
+	res := make([]uint8, r.Size)
+	first := make([]int32, r.Size+1) // the children of ix are children[first[ix]:first[ix+1]]
+	strongToMove := make([]bool, r.Size)
+	var children []int32
+
+	for ix := range res {
+		first[ix] = int32(len(children))
+		if !r.Setup(&b, ix) {
+			res[ix] = retroInvalid
+			continue
+		}
+		strongToMove[ix] = b.Stm == r.Strong
+
+		ml = ml[:0]
+		b.GenAllLegals(&ml)
+		if len(ml) == 0 {
+			res[ix] = retroDraw
+			if b.Stm != r.Strong && b.IsAttacked(b.King[b.Stm], r.Strong) {
+				res[ix] = retroWin
+			}
+			continue
+		}
+
+		for _, mv := range ml {
+			b.Move(mv)
+			if cix, ok := r.Index(&b); ok {
+				children = append(children, int32(cix))
+			} else if r.Convert(&b) {
+				children = append(children, childWin)
+			} else {
+				children = append(children, childDraw)
+			}
+			b.Unmove(mv)
+		}
+	}
+	first[r.Size] = int32(len(children))
+
+	for changed := true; changed; {
+		changed = false
+		for ix := range res {
+			if res[ix] != retroUnknown {
+				continue
+			}
+
+			if sc := r.classify(strongToMove[ix], children[first[ix]:first[ix+1]], res); sc != retroUnknown {
+				res[ix] = uint8(sc)
+				changed = true
+			}
+		}
+	}
+
+	bb := NewBitbase(r.Size)
+	for ix, sc := range res {
+		if sc == retroWin {
+			bb.SetWin(ix)
+		}
+	}
+	return bb
+}
+
+// classify computes the result of a position from the results of its children
+func (r *Retro) classify(strongToMove bool, children []int32, res []uint8) int {
+	unknown := false
+	for _, cix := range children {
+		sc := retroDraw
+		switch {
+		case cix == childWin:
+			sc = retroWin
+		case cix >= 0:
+			sc = int(res[cix])
+		}
+
+		switch {
+		case strongToMove && sc == retroWin:
+			return retroWin // the strong side picks a winning move
+		case !strongToMove && sc == retroDraw:
+			return retroDraw // the weak side picks a drawing move
+		case sc == retroUnknown:
+			unknown = true
+		}
+	}
+
+	if unknown {
+		return retroUnknown
+	}
+	if strongToMove {
+		return retroDraw
+	}
+	return retroWin
+}
@@ -0,0 +1,185 @@
+package position
+
+import (
+	"fmt"
+	"strings"
+
+	. "github.com/Tecu23/go-game/pkg/chess/constants"
+	"github.com/Tecu23/go-game/pkg/chess/moves"
+)
+
+// sanPieces are the piece letters of SAN by piece type
+const sanPieces = "PNBRQK"
+
+// SAN returns mv, a legal move on b, in standard algebraic notation like Nbd7, exd5, O-O-O or e8=Q+
+func (b *BoardStruct) SAN(mv moves.Move) string {
+	fr, to := mv.Fr(), mv.To()
+	pc := b.Squares[fr]
+	pt := Pc2pt(pc)
+
+	var san strings.Builder
+	switch {
+	case mv.IsDrop():
+		san.WriteString(mv.String())
+	case b.IsCastling(mv) && to == b.Castl[b.Stm].RookSh:
+		san.WriteString("O-O")
+	case b.IsCastling(mv):
+		san.WriteString("O-O-O")
+	case pt == Pawn:
+		if fr%8 != to%8 { // captures, en passant too
+			san.WriteString(Sq2Fen[fr][:1] + "x")
+		}
+		san.WriteString(Sq2Fen[to])
+		if mv.Pr() != Empty {
+			san.WriteString("=" + sanPieces[Pc2pt(mv.Pr()):Pc2pt(mv.Pr())+1])
+		}
+	default:
+		san.WriteByte(sanPieces[pt])
+		san.WriteString(b.disambiguate(mv))
+		if b.Squares[to] != Empty {
+			san.WriteByte('x')
+		}
+		san.WriteString(Sq2Fen[to])
+	}
+
+	if b.Move(mv) {
+		if b.InCheck() {
+			var ml moves.MoveList
+			b.GenAllLegals(&ml)
+			if len(ml) == 0 {
+				san.WriteByte('#')
+			} else {
+				san.WriteByte('+')
+			}
+		}
+		b.Unmove(mv)
+	}
+	return san.String()
+}
+
+// disambiguate returns what SAN needs of the from square of mv to tell it apart from the moves
+// of other pieces of the same kind to the same square: nothing, the file, the rank or both
+func (b *BoardStruct) disambiguate(mv moves.Move) string {
+	fr, to := mv.Fr(), mv.To()
+	var ml moves.MoveList
+	b.GenAllLegals(&ml)
+
+	others, sameFile, sameRank := false, false, false
+	for _, m := range ml {
+		if m.To() != to || m.Fr() == fr || b.Squares[m.Fr()] != b.Squares[fr] {
+			continue
+		}
+		others = true
+		sameFile = sameFile || m.Fr()%8 == fr%8
+		sameRank = sameRank || m.Fr()/8 == fr/8
+	}
+
+	switch {
+	case !others:
+		return ""
+	case !sameFile:
+		return Sq2Fen[fr][:1]
+	case !sameRank:
+		return Sq2Fen[fr][1:]
+	}
+	return Sq2Fen[fr]
+}
+
+// ParseSAN returns the legal move on b written as san. It accepts the usual variations:
+// check and annotation suffixes, 0-0 for O-O, a missing = before the promotion piece and
+// more disambiguation than needed. A drop is written like N@f3
+func (b *BoardStruct) ParseSAN(san string) (moves.Move, error) {
+	s := strings.TrimRight(strings.TrimSpace(san), "+#!?")
+	if s == "" {
+		return moves.NoMove, fmt.Errorf("empty move")
+	}
+
+	if strings.Contains(s, "@") {
+		mv, err := b.ParseUCI(s)
+		if err != nil {
+			return moves.NoMove, fmt.Errorf("%v: no such drop", san)
+		}
+		return mv, nil
+	}
+
+	var ml moves.MoveList
+	b.GenAllLegals(&ml)
+
+	// castling
+	if c := strings.ReplaceAll(s, "0", "O"); c == "O-O" || c == "O-O-O" {
+		to := b.Castl[b.Stm].RookSh
+		if c == "O-O-O" {
+			to = b.Castl[b.Stm].RookL
+		}
+		for _, mv := range ml {
+			if b.IsCastling(mv) && mv.To() == to {
+				return mv, nil
+			}
+		}
+		return moves.NoMove, fmt.Errorf("%v: castling is not legal here", san)
+	}
+
+	pt := Pawn
+	if strings.IndexByte("NBRQK", s[0]) >= 0 {
+		pt = strings.IndexByte(sanPieces, s[0])
+		s = s[1:]
+	}
+
+	// promotion, e8=Q or e8Q. In antichess to a king too
+	pr := Empty
+	proms := "NBRQnbrq"
+	if !b.Variant.Royal() {
+		proms += "Kk"
+	}
+	if n := len(s); n >= 2 && strings.IndexByte(proms, s[n-1]) >= 0 {
+		pr = Pt2pc(strings.IndexByte(sanPieces, strings.ToUpper(s[n-1:])[0]), b.Stm)
+		s = strings.TrimSuffix(s[:n-1], "=")
+	}
+
+	capture := strings.ContainsAny(s, "x:")
+	s = strings.NewReplacer("x", "", ":", "", "-", "").Replace(s)
+	if len(s) < 2 || len(s) > 4 {
+		return moves.NoMove, fmt.Errorf("%v is not a move in SAN", san)
+	}
+	to, ok := Fen2Sq[s[len(s)-2:]]
+	if !ok {
+		return moves.NoMove, fmt.Errorf("%v: %v is not a square", san, s[len(s)-2:])
+	}
+
+	from := s[:len(s)-2] // a file, a rank or a square
+	for _, c := range from {
+		if (c < 'a' || c > 'h') && (c < '1' || c > '8') {
+			return moves.NoMove, fmt.Errorf("%v is not a move in SAN", san)
+		}
+	}
+
+	var found []moves.Move
+	for _, mv := range ml {
+		if mv.To() != to || Pc2pt(b.Squares[mv.Fr()]) != pt || mv.Pr() != pr || b.IsCastling(mv) {
+			continue
+		}
+		if !strings.Contains(Sq2Fen[mv.Fr()], from) {
+			continue
+		}
+		found = append(found, mv)
+	}
+
+	switch {
+	case len(found) == 1:
+		if capture && b.Squares[to] == Empty && !(pt == Pawn && to == b.Ep) {
+			return moves.NoMove, fmt.Errorf("%v: there is nothing to capture on %v", san, Sq2Fen[to])
+		}
+		return found[0], nil
+	case len(found) > 1:
+		var alts []string
+		for _, mv := range found {
+			alts = append(alts, b.SAN(mv))
+		}
+		return moves.NoMove, fmt.Errorf("%v is ambiguous: %v", san, strings.Join(alts, ", "))
+	}
+
+	if pr == Empty && pt == Pawn && (to/8 == 0 || to/8 == 7) {
+		return moves.NoMove, fmt.Errorf("%v: the promotion piece is missing", san)
+	}
+	return moves.NoMove, fmt.Errorf("%v is not a legal move", san)
+}
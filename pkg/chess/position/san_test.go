@@ -0,0 +1,187 @@
+package position
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/Tecu23/go-game/pkg/chess/moves"
+)
+
+// findMove returns the legal move on b given in long algebraic notation
+func findMove(t *testing.T, b *BoardStruct, uci string) moves.Move {
+	t.Helper()
+	mv, err := b.ParseUCI(uci)
+	if err != nil {
+		t.Fatalf("%v in %v", err, b.FEN())
+	}
+	return mv
+}
+
+func TestSAN(t *testing.T) {
+	tests := []struct {
+		fen string
+		uci string
+		san string
+	}{
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", "e2e4", "e4"},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", "g1f3", "Nf3"},
+		{"rnbqkbnr/ppp1pppp/8/8/8/8/PPPPPPPP/RNBQKBNR b KQkq - 0 1", "b8d7", "Nd7"},
+		{"rnbqkb1r/ppp1pppp/5n2/8/8/8/PPPPPPPP/RNBQKBNR b KQkq - 0 1", "f6d5", "Nd5"},
+		{"rnbqkb1r/ppp1pppp/5n2/8/8/8/PPPPPPPP/RNBQKBNR b KQkq - 0 1", "b8d7", "Nbd7"},
+		{"rnbqkb1r/ppp1pppp/5n2/8/8/8/PPPPPPPP/RNBQKBNR b KQkq - 0 1", "f6d7", "Nfd7"},
+		{"4k3/8/8/8/8/R7/8/R3K3 w Q - 0 1", "a1a2", "R1a2"},
+		{"4k3/8/8/8/8/R7/8/R3K3 w Q - 0 1", "a3a2", "R3a2"},
+		{"8/8/k7/8/7Q/8/K7/7Q w - - 0 1", "h4e1", "Q4e1"},
+		{"8/8/k7/8/7Q/8/K7/7Q w - - 0 1", "h1e1", "Q1e1"},
+		{"8/8/k7/8/4Q2Q/8/K7/7Q w - - 0 1", "h4e1", "Qh4e1"},
+		{"8/8/k7/8/4Q2Q/8/K7/7Q w - - 0 1", "e4e1", "Qee1"},
+		{"4k3/8/8/8/8/8/3N4/4K1N1 w - - 0 1", "d2f3", "Ndf3"},
+		{"4k3/4r3/8/8/8/8/3N1N2/4K3 w - - 0 1", "f2e4", "Nfe4"},
+		{"4k3/4r3/8/8/8/8/3N4/2N1K3 w - - 0 1", "c1e2", "Ne2"}, // only one knight can block
+		{"4k3/8/8/8/1b6/8/3N4/4K1N1 w - - 0 1", "g1f3", "Nf3"}, // the knight on d2 is pinned
+		{"rnbqkbnr/ppp1pppp/8/3p4/4P3/8/PPPP1PPP/RNBQKBNR w KQkq - 0 2", "e4d5", "exd5"},
+		{"rnbqkbnr/ppp1p1pp/8/3pPp2/8/8/PPPP1PPP/RNBQKBNR w KQkq f6 0 3", "e5f6", "exf6"},
+		{"r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", "e1g1", "O-O"},
+		{"r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", "e1c1", "O-O-O"},
+		{"r3k2r/8/8/8/8/8/8/R3K2R b KQkq - 0 1", "e8c8", "O-O-O"},
+		{"r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", "a1a8", "Rxa8+"},
+		{"8/4P3/8/8/8/8/8/k3K3 w - - 0 1", "e7e8q", "e8=Q"},
+		{"8/4P3/8/8/8/8/8/k3K3 w - - 0 1", "e7e8n", "e8=N"},
+		{"1k6/4P3/8/8/8/8/8/4K3 w - - 0 1", "e7e8q", "e8=Q+"},
+		{"k7/4P3/1K6/8/8/8/8/8 w - - 0 1", "e7e8q", "e8=Q#"},
+		{"k3r3/3P4/1K6/8/8/8/8/8 w - - 0 1", "d7e8r", "dxe8=R#"},
+		{"6k1/5ppp/8/8/8/8/8/R5K1 w - - 0 1", "a1a8", "Ra8#"},
+		{"rnbqkbnr/pppp1ppp/8/4p3/6P1/5P2/PPPPP2P/RNBQKBNR b KQkq - 0 2", "d8h4", "Qh4#"},
+	}
+
+	for _, tt := range tests {
+		b, err := ParseFEN(tt.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mv := findMove(t, b, tt.uci)
+		if got := b.SAN(mv); got != tt.san {
+			t.Errorf("%v %v: got %v, want %v", tt.fen, tt.uci, got, tt.san)
+		}
+		if got, err := b.ParseSAN(tt.san); err != nil || got != mv {
+			t.Errorf("%v: ParseSAN(%v) = %v %v, want %v", tt.fen, tt.san, got, err, mv)
+		}
+	}
+}
+
+func TestParseSAN(t *testing.T) {
+	tests := []struct {
+		fen string
+		san string
+		uci string // empty if an error is expected
+		err string // part of the error message
+	}{
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", "Ng1f3", "g1f3", ""},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", "Ngf3", "g1f3", ""},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", "e2-e4", "e2e4", ""},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", "e4!?", "e2e4", ""},
+		{"r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", "0-0", "e1g1", ""},
+		{"r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", "0-0-0", "e1c1", ""},
+		{"8/4P3/8/8/8/8/8/k3K3 w - - 0 1", "e8Q", "e7e8q", ""},
+		{"8/4P3/8/8/8/8/8/k3K3 w - - 0 1", "e8=q", "e7e8q", ""},
+		{"rnbqkbnr/ppp1pppp/8/3p4/4P3/8/PPPP1PPP/RNBQKBNR w KQkq - 0 2", "exd5", "e4d5", ""},
+		{"rnbqkbnr/ppp1pppp/8/3p4/4P3/8/PPPP1PPP/RNBQKBNR w KQkq - 0 2", "ed5", "e4d5", ""},
+
+		{"rnbqkb1r/ppp1pppp/5n2/8/8/8/PPPPPPPP/RNBQKBNR b KQkq - 0 1", "Nd7", "", "ambiguous"},
+		{"8/8/k7/8/4Q2Q/8/K7/7Q w - - 0 1", "Qhe1", "", "ambiguous"},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", "Nf4", "", "not a legal move"},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", "e5", "", "not a legal move"},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", "Nxf3", "", "nothing to capture"},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", "O-O", "", "castling is not legal"},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", "Nz3", "", "not a square"},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", "hello", "", "not a move"},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", "", "", "empty"},
+		{"8/4P3/8/8/8/8/8/k3K3 w - - 0 1", "e8", "", "promotion piece is missing"},
+		{"4k3/8/8/8/1b6/8/3N4/4K3 w - - 0 1", "Nf3", "", "not a legal move"}, // pinned
+	}
+
+	for _, tt := range tests {
+		b, err := ParseFEN(tt.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mv, err := b.ParseSAN(tt.san)
+		if tt.uci != "" {
+			if want := findMove(t, b, tt.uci); err != nil || mv != want {
+				t.Errorf("%v: ParseSAN(%v) = %v %v, want %v", tt.fen, tt.san, mv, err, want)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%v: ParseSAN(%v) = %v %v, want error %v", tt.fen, tt.san, mv, err, tt.err)
+		}
+	}
+}
+
+// TestSANRoundTrip checks in random games that every legal move has a unique SAN that parses back to it
+func TestSANRoundTrip(t *testing.T) {
+	rnd := rand.New(rand.NewSource(2))
+	games := 20
+	if testing.Short() {
+		games = 3
+	}
+
+	for _, seed := range fenSeeds {
+		for g := 0; g < games; g++ {
+			b, err := ParseFEN(seed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for ply := 0; ply < 100; ply++ {
+				var ml moves.MoveList
+				b.GenAllLegals(&ml)
+				if len(ml) == 0 {
+					break
+				}
+
+				seen := make(map[string]bool)
+				for _, mv := range ml {
+					san := b.SAN(mv)
+					if seen[san] {
+						t.Fatalf("%v: %v twice", b.FEN(), san)
+					}
+					seen[san] = true
+
+					if got, err := b.ParseSAN(san); err != nil || got != mv {
+						t.Fatalf("%v: ParseSAN(%v) = %v %v, want %v", b.FEN(), san, got, err, mv)
+					}
+				}
+				b.Move(ml[rnd.Intn(len(ml))])
+			}
+		}
+	}
+}
+
+func TestParseMvsSAN(t *testing.T) {
+	Board.NewGame()
+	if err := ParseMvs("e4 e7e5 Nf3 Nc6 Bb5 a7a6 Ba4 Nf6 O-O"); err != nil {
+		t.Fatal(err)
+	}
+	want := "r1bqkb1r/1ppp1ppp/p1n2n2/4p3/B3P3/5N2/PPPP1PPP/RNBQ1RK1 b kq - 3 5"
+	if got := Board.FEN(); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	if err := ParseMvs("Nd4 Nh5"); err == nil || !strings.Contains(err.Error(), "Nh5") {
+		t.Errorf("got %v, want an error for Nh5", err)
+	}
+
+	// a board of its own doesn't touch Board
+	b, err := ParseFEN(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := Board.FEN()
+	if err := b.ParseMvs("Nxe4 Re1"); err != nil {
+		t.Fatal(err)
+	}
+	if got := Board.FEN(); got != before {
+		t.Errorf("Board changed from %v to %v", before, got)
+	}
+}
@@ -11,9 +11,11 @@ import (
 // //////////////////////////////////////////////////////
 // ////////////////////// HASH //////////////////////////
 var (
-	RandPcSq  [12 * 64]uint64 // keyvalues for 'pc on sq'
-	RandEp    [8]uint64       // keyvalues for 8 ep files
-	RandCastl [16]uint64      // keyvalues for castling states
+	RandPcSq   [12 * 64]uint64 // keyvalues for 'pc on sq'
+	RandEp     [8]uint64       // keyvalues for 8 ep files
+	RandCastl  [16]uint64      // keyvalues for castling states
+	RandCheck  [2][3]uint64    // keyvalues for 1 to 3 checks given by each side in Three-check
+	RandPocket [12][16]uint64  // keyvalues for 1 to 16 pieces in hand by pc in Crazyhouse
 )
 
 // setup random generator with seed
@@ -41,6 +43,16 @@ func InitKeys() {
 	for i := 0; i < 16; i++ {
 		RandCastl[i] = Rand64()
 	}
+	for sd := range RandCheck {
+		for i := range RandCheck[sd] {
+			RandCheck[sd][i] = Rand64()
+		}
+	}
+	for pc := range RandPocket {
+		for i := range RandPocket[pc] {
+			RandPocket[pc][i] = Rand64()
+		}
+	}
 
 	// check that all keys are different
 	fmt.Println("checking all random keys")
@@ -123,6 +135,22 @@ func CastlKey(castling uint) uint64 {
 	return RandCastl[castling]
 }
 
+// ChecksKey returns the keyvalue for n checks given by sd, 0 for no checks
+func ChecksKey(sd Color, n int) uint64 {
+	if n <= 0 {
+		return 0
+	}
+	return RandCheck[sd][Min(n, 3)-1]
+}
+
+// PocketKey is the keyvalue for n pieces pc in hand
+func PocketKey(pc, n int) uint64 {
+	if n <= 0 {
+		return 0
+	}
+	return RandPocket[pc][Min(n, 16)-1]
+}
+
 func CheckKey(b *BoardStruct) bool {
 	key := uint64(0)
 	for sq, pc := range b.Squares {
@@ -131,6 +159,10 @@ func CheckKey(b *BoardStruct) bool {
 		}
 		key ^= PcSqKey(pc, sq)
 	}
+	key ^= ChecksKey(WHITE, b.Checks[WHITE]) ^ ChecksKey(BLACK, b.Checks[BLACK])
+	for pc, n := range b.Pockets {
+		key ^= PocketKey(pc, n)
+	}
 	if b.Stm == BLACK {
 		key = ^key
 	}
@@ -144,24 +176,23 @@ func CheckKey(b *BoardStruct) bool {
 
 // //////////////////////////////////////////////////////
 // ////////////////////// TRANS /////////////////////////
-const EntrySize = 128 / 8
+const EntrySize = 96 / 8
 
 type TtEntry struct {
-	Lock      uint32 // the lock, extra safety
-	Move      uint32 // the best move from the search
-	_         uint16 // alignement, not used
-	Score     int16  // the score from the search
-	Age       uint8  // the age of this entry
-	Depth     int8   // the depth that the score is based on
-	ScoreType uint8  // the score has this score type
-	_         uint8  // alignement, not used
+	Lock      uint32       // the lock, extra safety
+	Move      moves.Move16 // the best move from the search
+	Score     int16        // the score from the search
+	Age       uint8        // the age of this entry
+	Depth     int8         // the depth that the score is based on
+	ScoreType uint8        // the score has this score type
+	_         uint8        // alignement, not used
 }
 
 // clear one entry
 func (e *TtEntry) Clear() {
-	// Obs entry skall vara 16 bytes
+	// Obs entry skall vara 12 bytes
 	e.Lock = 0
-	e.Move = uint32(moves.NoMove)
+	e.Move = moves.NoMove16
 	// entry.utfyllnad = 0  behövs inte
 	e.Score = 0
 	e.Age = 0
@@ -268,8 +299,9 @@ func (b *BoardStruct) FullKey() uint64 {
 // From the key we get an index to the table.
 // We will try 4 entries in a sequence if a lock is found
 // We always try to replace another age and/or a lower searched depth
+// The move is stored in 16 bits, see BoardStruct.Compact, and expanded on the board again after Retrieve
 
-func (t *TranspStruct) Store(fullKey uint64, mv moves.Move, depth, ply, sc, scoreType int) {
+func (t *TranspStruct) Store(fullKey uint64, mv moves.Move16, depth, ply, sc, scoreType int) {
 	t.CStores++
 	sc = RemoveMatePly(sc, ply)
 
@@ -281,8 +313,6 @@ func (t *TranspStruct) Store(fullKey uint64, mv moves.Move, depth, ply, sc, scor
 
 	for i := uint64(0); i < 4; i++ {
 		idx := (uint64(index) + i) & uint64(t.Mask)
-
-		fmt.Println(t.Tab)
 		entry := &t.Tab[idx]
 
 		if entry.Lock == lock {
@@ -292,8 +322,8 @@ func (t *TranspStruct) Store(fullKey uint64, mv moves.Move, depth, ply, sc, scor
 			}
 
 			if depth >= int(entry.Depth) {
-				if mv != moves.NoMove {
-					entry.Move = uint32(mv.OnlyMv())
+				if mv != moves.NoMove16 {
+					entry.Move = mv
 				}
 				entry.Depth = int8(depth)
 				entry.Score = int16(sc)
@@ -301,8 +331,8 @@ func (t *TranspStruct) Store(fullKey uint64, mv moves.Move, depth, ply, sc, scor
 				return
 			}
 
-			if entry.Move == uint32(moves.NoMove) {
-				entry.Move = uint32(mv.OnlyMv())
+			if entry.Move == moves.NoMove16 {
+				entry.Move = mv
 			}
 
 			return
@@ -326,7 +356,7 @@ func (t *TranspStruct) Store(fullKey uint64, mv moves.Move, depth, ply, sc, scor
 	newEntry.Lock = lock
 	newEntry.Age = uint8(t.Age)
 	newEntry.Depth = int8(depth)
-	newEntry.Move = uint32(mv)
+	newEntry.Move = mv
 	newEntry.Score = int16(sc)
 	newEntry.ScoreType = uint8(scoreType)
 }
@@ -337,9 +367,9 @@ func (t *TranspStruct) Store(fullKey uint64, mv moves.Move, depth, ply, sc, scor
 func (t *TranspStruct) Retrieve(
 	fullKey uint64,
 	depth, ply int,
-) (mv moves.Move, sc, scoreType int, ok bool) {
+) (mv moves.Move16, sc, scoreType int, ok bool) {
 	t.CTried++
-	mv = moves.NoMove
+	mv = moves.NoMove16
 	ok = false
 	sc = NoScore
 	scoreType = 0
@@ -359,7 +389,7 @@ func (t *TranspStruct) Retrieve(
 				entry.Age = uint8(t.Age)
 				t.CntUsed++
 			}
-			mv = moves.Move(entry.Move)
+			mv = entry.Move
 			sc = AddMatePly(int(entry.Score), ply)
 			scoreType = int(entry.ScoreType)
 			ok = true
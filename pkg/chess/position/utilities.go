@@ -13,188 +13,460 @@ import (
 	"github.com/Tecu23/go-game/pkg/chess/moves"
 )
 
-// ParseFEN should parse a FEN string and retrieve the board
-// rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq -
-func ParseFEN(FEN string) {
-	Board.Clear()
-
-	fenIdx := 0
-	sq := 0
-
-	// parsing the FEN from the start and setting the from top to bottom
-	for row := 7; row >= 0; row-- {
-		for sq = row * 8; sq < row*8+8; {
-
-			char := string(FEN[fenIdx])
-			fenIdx++
+// ParseFEN parses a FEN string into a new board, e.g.
+// rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1
+// The castling rights can be in X-FEN or Shredder-FEN (HAha) for Chess960 positions.
+// The fields after the side to move can be left out (EPD), they get the values - - 0 1.
+// Besides the syntax it checks that the position is legal: one king each, no pawns on
+// the back ranks, castling rights and ep square that fit the pieces and the side not
+// to move not in check
+func ParseFEN(fen string) (*BoardStruct, error) {
+	return ParseFENVariant(fen, Standard, false)
+}
 
-			if char == "/" {
-				continue
-			}
+// ParseFENVariant parses a FEN string into a new board for variant v. Three-check has a 7th
+// field with the checks given like lichess, +1+0 after one check by white. Antichess
+// positions can have any number of kings and the castling rights are ignored. Crazyhouse has
+// the pieces in hand after the board like [Qn], and a ~ after a piece that was a pawn.
+// With chess960 (UCI_Chess960) KQkq can be the rights of kings and rooks that didn't start
+// on their standard squares
+func ParseFENVariant(fen string, v Variant, chess960 bool) (*BoardStruct, error) {
+	b := &BoardStruct{Variant: v}
+	b.Clear()
+
+	fields := strings.Fields(fen)
+	maxFields := 6
+	if v == ThreeCheck {
+		maxFields = 7
+	}
+	if len(fields) < 2 || len(fields) > maxFields {
+		return nil, fenError(fen, "%v fields instead of 2 to %v", len(fields), maxFields)
+	}
 
-			// if we find a number we should skip that many squares from our current board
-			if i, err := strconv.Atoi(char); err == nil {
-				for j := 0; j < i; j++ {
-					Board.SetSq(Empty, sq)
-					sq++
+	// the pieces, from the 8th rank down
+	board := fields[0]
+	if v.Drops() && strings.HasSuffix(board, "]") {
+		ix := strings.IndexByte(board, '[')
+		if ix < 0 {
+			return nil, fenError(fen, "invalid pocket in %v", board)
+		}
+		if err := parsePockets(b, board[ix+1:len(board)-1]); err != nil {
+			return nil, fenError(fen, "%v", err)
+		}
+		board = board[:ix]
+	}
+	ranks := strings.Split(board, "/")
+	if len(ranks) != 8 {
+		return nil, fenError(fen, "%v ranks instead of 8", len(ranks))
+	}
+	for ix, rank := range ranks {
+		row := 7 - ix
+		file := 0
+		for _, c := range rank {
+			switch {
+			case c >= '1' && c <= '8':
+				file += int(c - '0')
+			case strings.ContainsRune("PNBRQKpnbrqk", c):
+				if file < 8 {
+					b.SetSq(Fen2pc(string(c)), row*8+file)
 				}
-				continue
-			}
-
-			// if we find an invalid piece we skip
-			if strings.IndexAny(PcFen, char) == -1 {
-				log.Errorf("error string invalid piece %s try next one", char)
-				continue
+				file++
+			case c == '~' && v.Drops() && file > 0 && file <= 8 && b.Squares[row*8+file-1] != Empty:
+				b.Promoted.SetBit(row*8 + file - 1)
+			default:
+				return nil, fenError(fen, "invalid character %q on rank %v", c, row+1)
 			}
-
-			Board.SetSq(Fen2pc(char), sq)
-
-			sq++
+		}
+		if file != 8 {
+			return nil, fenError(fen, "rank %v has %v files", row+1, file)
 		}
 	}
 
-	remaining := strings.Split(strings.TrimSpace(FEN[fenIdx:]), " ")
-
 	// Setting the Side to Move
-	if len(remaining) > 0 {
-		if remaining[0] == "w" {
-			Board.Stm = WHITE
-		} else if remaining[0] == "b" {
-			Board.Stm = BLACK
-		} else {
-			log.Errorf("info string remaining=%v; sq=%v;  fenIx=%v;", strings.Join(remaining, " "), sq, fenIdx)
-			log.Errorf("info string %s invalid stm color", remaining[0])
-			Board.Stm = WHITE
-		}
+	switch fields[1] {
+	case "w":
+		b.Stm = WHITE
+	case "b":
+		b.Stm = BLACK
+		b.Key = ^b.Key
+	default:
+		return nil, fenError(fen, "invalid side to move %v", fields[1])
 	}
 
-	if Board.Stm == BLACK {
-		Board.Key = ^Board.Key
+	if err := checkPieces(b); err != nil {
+		return nil, fenError(fen, "%v", err)
 	}
 
 	// Checking for castling
-	Board.Castlings = 0
-	if len(remaining) > 1 {
-		Board.Castlings = castlings.ParseCastlings(remaining[1])
+	if len(fields) > 2 && fields[2] != "-" && v.Royal() {
+		if err := parseCastlings(b, fields[2], chess960); err != nil {
+			return nil, fenError(fen, "%v", err)
+		}
 	}
 
 	// En Passant
-	Board.Ep = 0
-	if len(remaining) > 2 {
-		if remaining[2] != "-" {
-			Board.Ep = Fen2Sq[remaining[2]]
+	if len(fields) > 3 && fields[3] != "-" {
+		ep, ok := Fen2Sq[fields[3]]
+		if !ok {
+			return nil, fenError(fen, "invalid ep square %v", fields[3])
 		}
+		if !epPossible(b, ep) {
+			return nil, fenError(fen, "ep square %v doesn't fit the last move", fields[3])
+		}
+		b.Ep = ep
 	}
 
 	// Cheking for 50 move rule
-	Board.Rule50 = 0
-	if len(remaining) > 3 {
-		Board.Rule50 = parse50(remaining[3])
+	if len(fields) > 4 {
+		r50, err := strconv.Atoi(fields[4])
+		if err != nil || r50 < 0 {
+			return nil, fenError(fen, "invalid halfmove clock %v", fields[4])
+		}
+		b.Rule50 = r50
+	}
+
+	// Full move counter
+	if len(fields) > 5 {
+		n, err := strconv.Atoi(fields[5])
+		if err != nil || n < 1 {
+			return nil, fenError(fen, "invalid full move counter %v", fields[5])
+		}
+		b.FullMove = n
+	}
+
+	// the checks given in Three-check
+	if len(fields) > 6 {
+		var w, bl int
+		if _, err := fmt.Sscanf(fields[6], "+%d+%d", &w, &bl); err != nil || w < 0 || w > 3 || bl < 0 || bl > 3 {
+			return nil, fenError(fen, "invalid checks %v", fields[6])
+		}
+		b.Checks = [2]int{w, bl}
+		b.Key ^= ChecksKey(WHITE, w) ^ ChecksKey(BLACK, bl)
 	}
+
+	return b, nil
 }
 
-// ParseMvs should parse and make the moves retrieved from the position command
-func ParseMvs(mvstr string) error {
-	mvs := strings.Fields(strings.ToLower(mvstr))
+var sideNames = [2]string{"white", "black"}
 
-	for _, mv := range mvs {
-		mv = strings.TrimSpace(mv)
+func fenError(fen, format string, a ...any) error {
+	return fmt.Errorf("invalid fen %q: %v", fen, fmt.Sprintf(format, a...))
+}
 
-		if len(mv) < 4 || len(mv) > 5 {
-			e := fmt.Sprintf("error string %s in the position command is not a correct move", mv)
-			log.Error(e)
-			return errors.New(e)
+// parsePockets reads the pieces in hand of Crazyhouse, like Qn or - for none
+func parsePockets(b *BoardStruct, pockets string) error {
+	if pockets == "-" {
+		return nil
+	}
+	for _, c := range pockets {
+		if !strings.ContainsRune("PNBRQpnbrq", c) {
+			return fmt.Errorf("invalid piece %q in the pocket", c)
+		}
+		pc := Fen2pc(string(c))
+		b.setPocket(pc, b.Pockets[pc]+1)
+	}
+	return nil
+}
 
+// checkPieces checks the kings, the pawns and the number of pieces. With drops a side can
+// have more pawns and pieces on the board than it started with
+func checkPieces(b *BoardStruct) error {
+	for sd := WHITE; sd <= BLACK; sd++ {
+		if n := b.Count[Pt2pc(King, sd)]; n != 1 && b.Variant.Royal() {
+			return fmt.Errorf("%v has %v kings", sideNames[sd], n)
+		}
+		if b.Variant.Drops() {
+			continue
+		}
+		if n := b.Count[Pt2pc(Pawn, sd)]; n > 8 {
+			return fmt.Errorf("%v has %v pawns", sideNames[sd], n)
+		}
+		if n := b.WbBB[sd].Count(); n > 16 {
+			return fmt.Errorf("%v has %v pieces", sideNames[sd], n)
 		}
+	}
 
-		// does the from square exists
-		fmt.Println(mv, mv[:2])
-		fr, ok := Fen2Sq[mv[:2]]
-		fmt.Println(fr, ok, Fen2Sq)
-		if !ok {
-			e := fmt.Sprintf(
-				"error string %s in the position command is not a correct from square",
-				mv,
-			)
-			log.Error(e)
-			return errors.New(e)
+	if b.PieceBB[Pawn]&(Row1|Row8) != 0 {
+		return errors.New("pawn on the first or last rank")
+	}
+
+	if b.Variant.Royal() && b.IsAttacked(b.King[b.Stm.Opposite()], b.Stm) {
+		return errors.New("the side not to move is in check")
+	}
+	return nil
+}
+
+// parseCastlings reads the castling rights of FEN (KQkq), X-FEN or Shredder-FEN (the files of
+// the rooks, HAha) and sets up where the kings and rooks castle from. K and Q are the
+// outermost rooks on each side of the king, another rook needs its file. Without chess960
+// KQkq are the rights of standard chess, with the king on the e-file and the rook in the corner
+func parseCastlings(b *BoardStruct, field string, chess960 bool) error {
+	standard := !chess960 && strings.Trim(field, "KQkq") == ""
+	rooks := [2][2]int{{-1, -1}, {-1, -1}} // by side, the rooks of the short and long castling
+	for ix, c := range field {
+		sd, up := WHITE, c
+		if c >= 'a' && c <= 'z' {
+			sd, up = BLACK, c-'a'+'A'
+		}
+		if !strings.ContainsRune("KQABCDEFGH", up) || strings.ContainsRune(field[:ix], c) {
+			return fmt.Errorf("invalid castling rights %v", field)
 		}
 
-		pc := Board.Squares[fr]
-		if pc == Empty {
-			e := fmt.Sprintf(
-				"error string %s in the position command.The from square is an empty square",
-				mv,
-			)
-			log.Error(e)
-			return errors.New(e)
+		king, first := b.King[sd], A1
+		if sd == BLACK {
+			first = A8
+		}
+		wing, rook := 0, -1 // short, long
+		switch up {
+		case 'K':
+			rook = b.outerRook(sd, true)
+		case 'Q':
+			wing, rook = 1, b.outerRook(sd, false)
+		default:
+			rook = first + int(up-'A')
+			if rook < king {
+				wing = 1
+			}
 		}
 
-		pcCol := PcColor(pc)
-		if pcCol != Board.Stm {
-			e := fmt.Sprintf(
-				"error string %s in the position command.The from piece has the wrong color",
-				mv,
-			)
-			log.Error(e)
-			return errors.New(e)
+		name := [2]string{"short", "long"}[wing]
+		if king/8 != first/8 {
+			return fmt.Errorf("%v can't castle %v with the king on %v", sideNames[sd], name, Sq2Fen[king])
+		}
+		if rook < 0 || b.Squares[rook] != Pt2pc(Rook, sd) {
+			return fmt.Errorf("%v can't castle %v without a rook on that side of the king", sideNames[sd], name)
+		}
+		if standard && (king != first+4 || rook != first+7*(1-wing)) {
+			return fmt.Errorf("%v can't castle %v from %v and %v without UCI_Chess960", sideNames[sd], name,
+				Sq2Fen[king], Sq2Fen[rook])
+		}
+		if rooks[sd][wing] >= 0 {
+			return fmt.Errorf("invalid castling rights %v", field)
+		}
+		rooks[sd][wing] = rook
+	}
 
+	for sd := WHITE; sd <= BLACK; sd++ {
+		if rooks[sd][0] < 0 && rooks[sd][1] < 0 {
+			continue
+		}
+		opt := castlings.Castl[sd]
+		if rooks[sd][0] >= 0 {
+			b.Castlings.On(opt.Short)
+			opt.RookSh = rooks[sd][0]
 		}
+		if rooks[sd][1] >= 0 {
+			b.Castlings.On(opt.Long)
+			opt.RookL = rooks[sd][1]
+		}
+		b.Castl[sd] = castlings.NewOptions(sd, b.King[sd], opt.RookSh, opt.RookL)
+	}
+	return nil
+}
 
-		// does the to square exists
-		to, ok := Fen2Sq[mv[2:4]]
-		if !ok {
-			e := fmt.Sprintf(
-				"error string %s in the position command has an incorrect from square",
-				mv,
-			)
-			log.Error(e)
-			return errors.New(e)
+// outerRook returns the outermost rook of sd on the rank of its king, on the short (h-file)
+// or long (a-file) side of the king, or -1 if there is none
+func (b *BoardStruct) outerRook(sd Color, short bool) int {
+	king := b.King[sd]
+	sq, step := king-king%8+7, -1
+	if !short {
+		sq, step = king-king%8, 1
+	}
+	for ; sq != king; sq += step {
+		if b.Squares[sq] == Pt2pc(Rook, sd) {
+			return sq
 		}
+	}
+	return -1
+}
 
-		// does the promotion piece exists?
-		pr := Empty
-		if len(mv) == 5 {
-			if !strings.ContainsAny(mv[4:5], "QRNBqrnb") {
-				e := fmt.Sprintf(
-					"error string promotion piece in %s in the position command is not correct",
-					mv,
-				)
-				log.Error(e)
-				return errors.New(e)
+// castlingField returns the castling rights in X-FEN: KQkq for the outermost rooks like FEN,
+// the file of the rook like Shredder-FEN for another rook (Chess960 only)
+func (b *BoardStruct) castlingField() string {
+	field := ""
+	for sd := WHITE; sd <= BLACK; sd++ {
+		opt := &b.Castl[sd]
+		for _, c := range []struct {
+			flag   uint
+			rook   int
+			short  bool
+			letter byte
+		}{{opt.Short, opt.RookSh, true, 'K'}, {opt.Long, opt.RookL, false, 'Q'}} {
+			if uint(b.Castlings)&c.flag == 0 {
+				continue
+			}
+			letter := c.letter
+			if b.outerRook(sd, c.short) != c.rook {
+				letter = 'A' + byte(c.rook%8)
 			}
+			if sd == BLACK {
+				letter += 'a' - 'A'
+			}
+			field += string(letter)
+		}
+	}
+	if field == "" {
+		return "-"
+	}
+	return field
+}
+
+// epPossible returns true if the last move can have been a double pawn push over the ep square
+func epPossible(b *BoardStruct, ep int) bool {
+	pawnSq, fromSq, pawn := ep-8, ep+8, BP // white to move, a black pawn moved
+	if b.Stm == BLACK {
+		pawnSq, fromSq, pawn = ep+8, ep-8, WP
+	}
+	if b.Stm == WHITE && ep/8 != 5 || b.Stm == BLACK && ep/8 != 2 {
+		return false
+	}
+	return b.Squares[pawnSq] == pawn && b.Squares[ep] == Empty && b.Squares[fromSq] == Empty
+}
 
-			pr = Fen2pc(mv[4:5])
-			pt := Pc2pt(pr)
-			pr = Pt2pc(pt, Board.Stm)
+// FEN returns the position in Forsyth-Edwards Notation, in Three-check with the checks given
+// and in Crazyhouse with the pieces in hand
+func (b *BoardStruct) FEN() string {
+	var fen strings.Builder
+	for row := 7; row >= 0; row-- {
+		empty := 0
+		for sq := row * 8; sq < row*8+8; sq++ {
+			if b.Squares[sq] == Empty {
+				empty++
+				continue
+			}
+			if empty > 0 {
+				fen.WriteString(strconv.Itoa(empty))
+				empty = 0
+			}
+			fen.WriteByte(PcFen[b.Squares[sq]])
+			if b.Promoted.IsBitSet(sq) {
+				fen.WriteByte('~')
+			}
 		}
+		if empty > 0 {
+			fen.WriteString(strconv.Itoa(empty))
+		}
+		if row > 0 {
+			fen.WriteByte('/')
+		}
+	}
 
-		cp := Board.Squares[to]
+	if b.Variant.Drops() {
+		fen.WriteByte('[')
+		for _, pc := range []int{WQ, WR, WB, WN, WP, BQ, BR, BB, BN, BP} {
+			fen.WriteString(strings.Repeat(string(PcFen[pc]), b.Pockets[pc]))
+		}
+		fen.WriteByte(']')
+	}
+
+	stm := "w"
+	if b.Stm == BLACK {
+		stm = "b"
+	}
+	ep := "-"
+	if b.Ep != 0 {
+		ep = Sq2Fen[b.Ep]
+	}
+
+	s := fmt.Sprintf("%v %v %v %v %v %v", fen.String(), stm, b.castlingField(), ep, b.Rule50, b.FullMove)
+	if b.Variant == ThreeCheck {
+		s += fmt.Sprintf(" +%v+%v", b.Checks[WHITE], b.Checks[BLACK])
+	}
+	return s
+}
 
-		var intMv moves.Move // external move format
-		intMv.PackMove(fr, to, pc, cp, pr, Board.Ep, Board.Castlings)
+// ParseMvs should parse and make the moves retrieved from the position command.
+// The moves can be in long algebraic notation (e2e4, e7e8q) or in SAN (e4, e8=Q)
+func ParseMvs(mvstr string) error {
+	return Board.ParseMvs(mvstr)
+}
 
-		if !Board.Move(intMv) {
-			e := fmt.Sprintf(
-				"error string %v-%v is an illegal move",
-				Sq2Fen[fr], Sq2Fen[to],
-			)
+// ParseMvs makes the moves in mvstr on b, see ParseMvs. It stops at the first invalid move,
+// the moves before it are made
+func (b *BoardStruct) ParseMvs(mvstr string) error {
+	for _, s := range strings.Fields(mvstr) {
+		var mv moves.Move
+		var err error
+		if isLongAlgebraic(s) {
+			mv, err = b.ParseUCI(s)
+		} else {
+			mv, err = b.ParseSAN(s)
+		}
+		if err != nil {
+			e := fmt.Sprintf("%v in the position command", err)
 			log.Error(e)
 			return errors.New(e)
 		}
+		b.Move(mv)
 	}
 	return nil
 }
 
-// parse the 50 move rule in remaining portion of the fenstring
-func parse50(fen50 string) int {
-	r50, err := strconv.Atoi(fen50)
-	if err != nil || r50 < 0 {
-		log.Errorf("error string 50 move rule in fenstring %s is not valid", fen50)
-		return 0
+// Chess960 is the UCI_Chess960 option. Castlings are then written as the king taking its
+// own rook (e1h1) instead of the king moving two squares (e1g1). The position command
+// passes it on to ParseFENVariant
+var Chess960 bool
+
+// UCI returns mv, a move on b, in the long algebraic notation of UCI like e2e4 or e7e8q
+func (b *BoardStruct) UCI(mv moves.Move) string {
+	return b.uci(mv, Chess960)
+}
+
+// uci writes a castling as the king taking its rook if kingTakesRook, else as the king
+// moving to the g or c file
+func (b *BoardStruct) uci(mv moves.Move, kingTakesRook bool) string {
+	to := mv.To()
+	if !kingTakesRook && b.IsCastling(mv) {
+		opt := &b.Castl[PcColor(mv.Pc())]
+		to = opt.KingToSh
+		if mv.To() == opt.RookL {
+			to = opt.KingToL
+		}
+	}
+	if mv.IsDrop() {
+		return mv.String()
+	}
+	s := Sq2Fen[mv.Fr()] + Sq2Fen[to]
+	if mv.Pr() != Empty {
+		s += strings.ToLower(Pc2Fen(mv.Pr()))
+	}
+	return s
+}
+
+// ParseUCI returns the legal move on b written in long algebraic notation. A castling can be
+// written as the king taking its own rook and, without Chess960, as the king moving to the
+// g or c file. A drop is written like N@f3
+func (b *BoardStruct) ParseUCI(s string) (moves.Move, error) {
+	var ml moves.MoveList
+	b.GenAllLegals(&ml)
+
+	for _, mv := range ml { // the other moves first, f1g1 is a king move if it can be
+		if !b.IsCastling(mv) && strings.EqualFold(b.uci(mv, true), s) {
+			return mv, nil
+		}
+	}
+	for _, mv := range ml {
+		if b.IsCastling(mv) && (strings.EqualFold(b.uci(mv, true), s) || !Chess960 && strings.EqualFold(b.uci(mv, false), s)) {
+			return mv, nil
+		}
 	}
+	return moves.NoMove, fmt.Errorf("%v is not a legal move", s)
+}
 
-	return r50
+// isLongAlgebraic returns true for moves like e2e4, e7e8q and the drop N@f3
+func isLongAlgebraic(mv string) bool {
+	if len(mv) == 4 && mv[1] == '@' {
+		return strings.ContainsRune("PNBRQpnbrq", rune(mv[0])) && mv[2] >= 'a' && mv[2] <= 'h' && mv[3] >= '1' && mv[3] <= '8'
+	}
+	if len(mv) != 4 && len(mv) != 5 {
+		return false
+	}
+	for ix := 0; ix < 4; ix += 2 {
+		if mv[ix] < 'a' || mv[ix] > 'h' || mv[ix+1] < '1' || mv[ix+1] > '8' {
+			return false
+		}
+	}
+	return len(mv) == 4 || strings.ContainsAny(mv[4:], "QRNBKqrnbk")
 }
 
 // Fen2pc convert pieceString to pc int
@@ -0,0 +1,240 @@
+package position
+
+import (
+	"github.com/Tecu23/go-game/pkg/chess/bitboard"
+	. "github.com/Tecu23/go-game/pkg/chess/constants"
+	"github.com/Tecu23/go-game/pkg/chess/moves"
+)
+
+// the results of a game from the side to move's point of view
+const (
+	Loss = -1
+	Draw = 0
+	Win  = 1
+)
+
+// Variant are the rules of the game played on a board. Standard is chess, the other variants
+// embed it and override what they change
+type Variant interface {
+	// Name is the name of the variant in the UCI_Variant option
+	Name() string
+	// Royal tells if the king can be checked and castles. In variants where it is a common
+	// piece it can be captured and a pawn can promote to it
+	Royal() bool
+	// Drops tells if the captured pieces go to the pocket of the capturer, who can drop them
+	// back on the board as a move
+	Drops() bool
+	// AfterMove is called by Move with the move made and the side to move switched. If it
+	// returns false the move is illegal and Move takes it back
+	AfterMove(b *BoardStruct) bool
+	// Result tells if the game is over before the side to move moves, and how it ended
+	Result(b *BoardStruct) (int, bool)
+	// NoMoves is the result when the side to move has no legal moves
+	NoMoves(b *BoardStruct) int
+	// Evaluate returns the score of b from white's pov
+	Evaluate(b *BoardStruct) int
+}
+
+// MoveFilter is implemented by the variants that allow only some of the legal moves, like
+// the forced captures of antichess. The move generators of the engine that work in stages
+// can't tell which moves are allowed, so the engine generates all legal moves for them
+type MoveFilter interface {
+	// Filter removes the moves that are not allowed from ml, the legal moves of b
+	Filter(b *BoardStruct, ml *moves.MoveList)
+}
+
+var (
+	Standard      Variant = standard{}
+	KingOfTheHill Variant = kingOfTheHill{}
+	ThreeCheck    Variant = threeCheck{}
+	Antichess     Variant = antichess{}
+	Crazyhouse    Variant = crazyhouse{}
+)
+
+// Variants are all variants by their UCI_Variant name
+var Variants = map[string]Variant{
+	Standard.Name():      Standard,
+	KingOfTheHill.Name(): KingOfTheHill,
+	ThreeCheck.Name():    ThreeCheck,
+	Antichess.Name():     Antichess,
+	Crazyhouse.Name():    Crazyhouse,
+}
+
+// InCheck tells if the side to move is in check, never when the king is a common piece
+func (b *BoardStruct) InCheck() bool {
+	return b.Variant.Royal() && b.IsAttacked(b.King[b.Stm], b.Stm.Opposite())
+}
+
+// standard is chess
+type standard struct{}
+
+func (standard) Name() string { return "chess" }
+
+func (standard) Royal() bool { return true }
+
+func (standard) Drops() bool { return false }
+
+// AfterMove doesn't allow the side that moved to leave its king in check
+func (standard) AfterMove(b *BoardStruct) bool {
+	return !b.IsAttacked(b.King[b.Stm.Opposite()], b.Stm)
+}
+
+// Result is never over before the side to move is out of moves. Repetitions and the 50
+// move rule are left to the search
+func (standard) Result(b *BoardStruct) (int, bool) {
+	return Draw, false
+}
+
+// NoMoves is a mate or a stalemate
+func (standard) NoMoves(b *BoardStruct) int {
+	if b.InCheck() {
+		return Loss
+	}
+	return Draw
+}
+
+func (standard) Evaluate(b *BoardStruct) int {
+	return evaluate(b)
+}
+
+// kingOfTheHill is chess where a king that reaches the center wins
+type kingOfTheHill struct{ standard }
+
+// hill are the center squares of King of the Hill
+const hill = bitboard.BitBoard(1<<D4 | 1<<E4 | 1<<D5 | 1<<E5)
+
+// kothDist is the bonus for a king by its distance to the hill
+var kothDist = [8]int{0, 120, 50, 20}
+
+func (kingOfTheHill) Name() string { return "kingofthehill" }
+
+// Result is a loss if the side that just moved got its king to the hill
+func (kingOfTheHill) Result(b *BoardStruct) (int, bool) {
+	switch {
+	case onHill(b.King[b.Stm.Opposite()]):
+		return Loss, true
+	case onHill(b.King[b.Stm]):
+		return Win, true
+	}
+	return Draw, false
+}
+
+func (kingOfTheHill) Evaluate(b *BoardStruct) int {
+	return evaluate(b) + kothDist[hillDistance(b.King[WHITE])] - kothDist[hillDistance(b.King[BLACK])]
+}
+
+func onHill(sq int) bool {
+	return hill.IsBitSet(sq)
+}
+
+// hillDistance is the number of king moves from sq to the nearest center square
+func hillDistance(sq int) int {
+	dist := 7
+	for _, c := range []int{D4, E4, D5, E5} {
+		dist = Min(dist, SqDistance(sq, c))
+	}
+	return dist
+}
+
+// threeCheck is chess where the third check wins. The checks given are counted in
+// BoardStruct.Checks and are part of the key
+type threeCheck struct{ standard }
+
+// checkBonus is the bonus for the checks given
+var checkBonus = [4]int{0, 80, 250, 0}
+
+func (threeCheck) Name() string { return "3check" }
+
+// AfterMove counts the check if the move gives one
+func (v threeCheck) AfterMove(b *BoardStruct) bool {
+	if !v.standard.AfterMove(b) {
+		return false
+	}
+	if b.InCheck() {
+		sd := b.Stm.Opposite()
+		b.Key ^= ChecksKey(sd, b.Checks[sd]) ^ ChecksKey(sd, b.Checks[sd]+1)
+		b.Checks[sd]++
+	}
+	return true
+}
+
+// Result is a loss after the third check
+func (threeCheck) Result(b *BoardStruct) (int, bool) {
+	switch {
+	case b.Checks[b.Stm.Opposite()] >= 3:
+		return Loss, true
+	case b.Checks[b.Stm] >= 3:
+		return Win, true
+	}
+	return Draw, false
+}
+
+func (threeCheck) Evaluate(b *BoardStruct) int {
+	return evaluate(b) + checkBonus[Min(b.Checks[WHITE], 3)] - checkBonus[Min(b.Checks[BLACK], 3)]
+}
+
+// antichess is the variant where a side that has lost all its pieces or can't move wins.
+// Captures are forced and the king is a common piece, it can't castle
+type antichess struct{ standard }
+
+func (antichess) Name() string { return "antichess" }
+
+func (antichess) Royal() bool { return false }
+
+// AfterMove allows any move, there are no checks
+func (antichess) AfterMove(b *BoardStruct) bool {
+	return true
+}
+
+// NoMoves is a win, also when all pieces are lost
+func (antichess) NoMoves(b *BoardStruct) int {
+	return Win
+}
+
+// Filter only keeps the captures if there are any
+func (antichess) Filter(b *BoardStruct, ml *moves.MoveList) {
+	capture := false
+	for _, mv := range *ml {
+		if mv.Cp() != Empty {
+			capture = true
+			break
+		}
+	}
+	if !capture {
+		return
+	}
+	for ix := len(*ml) - 1; ix >= 0; ix-- {
+		if (*ml)[ix].Cp() == Empty {
+			ml.Remove(ix)
+		}
+	}
+}
+
+// Evaluate counts the pieces, the fewer the better
+func (antichess) Evaluate(b *BoardStruct) int {
+	return 100 * (b.WbBB[BLACK].Count() - b.WbBB[WHITE].Count())
+}
+
+// crazyhouse is chess where the captured pieces can be dropped back on the board. The pieces
+// in hand are kept in BoardStruct.Pockets and are part of the key
+type crazyhouse struct{ standard }
+
+// pocketVal is the value of a piece in hand by pt. It is worth more than on the board
+var pocketVal = [NoPiecesT]int{130, 350, 350, 500, 950, 0}
+
+func (crazyhouse) Name() string { return "crazyhouse" }
+
+func (crazyhouse) Drops() bool { return true }
+
+// Evaluate is the board and the pockets. The endgame recognizers don't know about drops
+func (crazyhouse) Evaluate(b *BoardStruct) int {
+	ev := evalBoard(b)
+	for pc, n := range b.Pockets {
+		if PcColor(pc) == WHITE {
+			ev += n * pocketVal[Pc2pt(pc)]
+		} else {
+			ev -= n * pocketVal[Pc2pt(pc)]
+		}
+	}
+	return ev
+}
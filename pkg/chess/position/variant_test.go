@@ -0,0 +1,197 @@
+package position
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/Tecu23/go-game/pkg/chess/constants"
+	"github.com/Tecu23/go-game/pkg/chess/moves"
+)
+
+func TestVariantFEN(t *testing.T) {
+	tests := []struct {
+		variant Variant
+		fen     string
+		want    string // the fen written back, or the error
+	}{
+		{ThreeCheck, "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1 +1+2", ""},
+		{ThreeCheck, "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1 +0+0"},
+		{ThreeCheck, "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1 +4+0", "invalid checks"},
+		{Standard, "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1 +0+0", "7 fields"},
+		{Antichess, "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w - - 0 1"},
+		{Antichess, "8/8/8/8/8/8/1p6/8 w - - 0 1", ""},            // no kings
+		{Antichess, "8/2k5/8/8/4K3/8/1p6/2K5 b - - 0 1", ""},      // two kings, one attacked
+		{KingOfTheHill, "8/2k5/8/8/8/8/1p6/8 b - - 0 1", "kings"}, // chess with a center
+		{Crazyhouse, "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR[] w KQkq - 0 1"},
+		{Crazyhouse, "r1Q~1k3/8/8/8/8/8/8/4K3[QNPPbp] b - - 0 1", ""},
+		{Crazyhouse, "4k3/8/8/8/8/8/PPPPPPPP/PPP1K3[] w - - 1 1", "pawn on the first"},
+		{Crazyhouse, "4k3/8/8/8/8/8/8/4K3[K] w - - 0 1", "invalid piece"},
+	}
+
+	for _, tt := range tests {
+		b, err := ParseFENVariant(tt.fen, tt.variant, false)
+		want := tt.want
+		if want == "" {
+			want = tt.fen
+		}
+		switch {
+		case err != nil && !strings.Contains(err.Error(), want):
+			t.Errorf("%v %v: got error %v, want %v", tt.variant.Name(), tt.fen, err, want)
+		case err == nil && b.FEN() != want:
+			t.Errorf("%v %v: got %v, want %v", tt.variant.Name(), tt.fen, b.FEN(), want)
+		}
+	}
+}
+
+func TestVariantMoves(t *testing.T) {
+	tests := []struct {
+		variant Variant
+		fen     string
+		moves   string // to play first
+		legals  int
+		result  int
+		over    bool
+	}{
+		// King of the Hill
+		{KingOfTheHill, "8/8/4k3/8/8/2K5/8/8 w - - 0 1", "", 8, Draw, false},
+		{KingOfTheHill, "8/8/4k3/8/8/2K5/8/8 w - - 0 1", "c3d4", 0, Loss, true},
+		{KingOfTheHill, "8/8/4k3/8/8/1K6/8/8 b - - 0 1", "e6d5", 0, Loss, true},
+		// Three-check, the third check wins even if it could be parried
+		{ThreeCheck, "rnbqkbnr/ppp1pppp/8/3p4/4P3/8/PPPP1PPP/RNBQKBNR w KQkq - 0 2 +1+0", "f1b5 c7c6", 34, Draw, false},
+		{ThreeCheck, "rnbqkbnr/ppp1pppp/8/3p4/4P3/8/PPPP1PPP/RNBQKBNR w KQkq - 0 2 +2+0", "f1b5", 0, Loss, true},
+		{ThreeCheck, "rnbqkbnr/pppp1ppp/8/4p3/4P3/8/PPPP1PPP/RNBQKBNR w KQkq - 0 2 +2+0", "d1h5 g8f6 h5f7", 0, Loss, true},
+		{ThreeCheck, "rnbqkbnr/ppppp1pp/8/5p2/4P3/8/PPPP1PPP/RNBQKBNR w KQkq - 0 2 +0+0", "d1h5", 1, Draw, false}, // g6
+		// antichess, captures are forced and a side without moves wins
+		{Antichess, "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w - - 0 1", "e2e4 b7b5", 1, Draw, false},
+		{Antichess, "8/8/8/8/8/8/1p6/8 w - - 0 1", "", 0, Win, false},
+		{Antichess, "8/8/8/8/8/1p6/P7/8 w - - 0 1", "a2b3", 0, Win, false},
+	}
+
+	for _, tt := range tests {
+		b, err := ParseFENVariant(tt.fen, tt.variant, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, s := range strings.Fields(tt.moves) {
+			mv := findMove(t, b, s)
+			if !b.Move(mv) {
+				t.Fatalf("%v %v: %v is not legal", tt.variant.Name(), tt.fen, s)
+			}
+		}
+
+		var ml moves.MoveList
+		b.GenAllLegals(&ml)
+		res, over := b.Variant.Result(b)
+		if !over && len(ml) == 0 {
+			res = b.Variant.NoMoves(b)
+		}
+		if len(ml) != tt.legals || res != tt.result || over != tt.over {
+			t.Errorf("%v %v %v: got %v moves, result %v %v, want %v moves, result %v %v",
+				tt.variant.Name(), tt.fen, tt.moves, len(ml), res, over, tt.legals, tt.result, tt.over)
+		}
+	}
+}
+
+func TestThreeCheckCounter(t *testing.T) {
+	fen := "rnbqkbnr/ppp1pppp/8/3p4/4P3/8/PPPP1PPP/RNBQKBNR w KQkq - 0 2 +0+0"
+	b, err := ParseFENVariant(fen, ThreeCheck, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	std, err := ParseFEN(strings.TrimSuffix(fen, " +0+0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mv := findMove(t, b, "f1b5")
+	key := b.FullKey()
+	b.Move(mv)
+	std.Move(mv)
+	if b.Checks != [2]int{1, 0} {
+		t.Errorf("got checks %v after Bb5+, want [1 0]", b.Checks)
+	}
+	if b.FullKey() == std.FullKey() {
+		t.Errorf("the check given is not in the key")
+	}
+	if !CheckKey(b) {
+		t.Errorf("the key %016x doesn't fit the board", b.Key)
+	}
+	if got := b.FEN(); !strings.HasSuffix(got, " +1+0") {
+		t.Errorf("got %v after Bb5+, want the checks +1+0", got)
+	}
+
+	b.Unmove(mv)
+	if b.Checks != [2]int{} || b.FullKey() != key || b.FEN() != fen {
+		t.Errorf("got %v %v after Unmove, want %v", b.FEN(), b.Checks, fen)
+	}
+}
+
+func TestAntichessPromotion(t *testing.T) {
+	b, err := ParseFENVariant("8/2P5/8/8/8/8/5p2/8 w - - 0 1", Antichess, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mv := findMove(t, b, "c7c8k")
+	if got := b.SAN(mv); got != "c8=K" {
+		t.Errorf("got %v, want c8=K", got)
+	}
+	if got, err := b.ParseSAN("c8=K"); err != nil || got != mv {
+		t.Errorf("ParseSAN(c8=K) = %v %v, want %v", got, err, mv)
+	}
+	b.Move(mv)
+	if got := b.FEN(); got != "2K5/8/8/8/8/8/5p2/8 b - - 0 1" {
+		t.Errorf("got %v after c8=K", got)
+	}
+
+	// no promotion to a king in chess
+	b, _ = ParseFEN("8/2P5/8/8/8/8/5p2/k6K w - - 0 1")
+	if _, err := b.ParseUCI("c7c8k"); err == nil {
+		t.Errorf("c7c8k is legal in chess")
+	}
+}
+
+func TestCrazyhouseDrops(t *testing.T) {
+	fen := "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR[Np] w KQkq - 0 1"
+	b, err := ParseFENVariant(fen, Crazyhouse, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mv := findMove(t, b, "n@f3")
+	if !mv.IsDrop() || b.UCI(mv) != "N@f3" || b.SAN(mv) != "N@f3" {
+		t.Errorf("got %v %v, want the drop N@f3", b.UCI(mv), b.SAN(mv))
+	}
+	if got, err := b.ParseSAN("N@f3"); err != nil || got != mv {
+		t.Errorf("ParseSAN(N@f3) = %v %v, want %v", got, err, mv)
+	}
+	for _, s := range []string{"P@e1", "B@f3", "N@e2", "p@e3"} {
+		if _, err := b.ParseUCI(s); err == nil {
+			t.Errorf("%v is legal in %v", s, fen)
+		}
+	}
+
+	key := b.FullKey()
+	b.Move(mv)
+	if got := b.FEN(); got != "rnbqkbnr/pppppppp/8/8/8/5N2/PPPPPPPP/RNBQKBNR[p] b KQkq - 1 1" {
+		t.Errorf("got %v after N@f3", got)
+	}
+	if !CheckKey(b) {
+		t.Errorf("the key %016x doesn't fit the board", b.Key)
+	}
+	b.Unmove(mv)
+	if b.FullKey() != key || b.FEN() != fen {
+		t.Errorf("got %v after Unmove, want %v", b.FEN(), fen)
+	}
+
+	// a promoted piece goes to the pocket as a pawn
+	fen = "r3k3/1P6/8/8/8/8/8/4K3[] w - - 0 1"
+	b, _ = ParseFENVariant(fen, Crazyhouse, false)
+	for _, s := range []string{"b7b8q", "a8b8"} {
+		b.Move(findMove(t, b, s))
+	}
+	if got := b.FEN(); got != "1r2k3/8/8/8/8/8/8/4K3[p] w - - 0 2" {
+		t.Errorf("got %v after b8=Q Rxb8", got)
+	}
+	if b.Pockets[BP] != 1 || b.Pockets[BQ] != 0 || !CheckKey(b) {
+		t.Errorf("got pockets %v, want a black pawn", b.Pockets)
+	}
+}
@@ -0,0 +1,138 @@
+package render
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+
+	. "github.com/Tecu23/go-game/pkg/chess/constants"
+	"github.com/Tecu23/go-game/pkg/chess/position"
+)
+
+// samples is the number of samples per pixel in each direction when filling a polygon
+const samples = 3
+
+// Image returns b as an image with the highlights and arrows of opt, nil for none
+func Image(b *position.BoardStruct, opt *Options) *image.RGBA {
+	if opt == nil {
+		opt = &Options{}
+	}
+	g := newGeometry(opt)
+	size := int(g.size)
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+
+	for sq := A1; sq <= H8; sq++ {
+		fillSquare(img, g, sq, squareColor(sq))
+	}
+	sqs, cols := highlights(b, opt)
+	for i, sq := range sqs {
+		fillSquare(img, g, sq, cols[i])
+	}
+
+	width := g.sq / 30
+	for sq, pc := range b.Squares {
+		if pc == Empty {
+			continue
+		}
+		fillPolygon(img, g.piece(position.Pc2pt(pc), sq), pieceFill[position.PcColor(pc)], outline, width)
+	}
+	for _, a := range opt.Arrows {
+		if pts := g.arrow(a); pts != nil {
+			fillPolygon(img, pts, arrowCol, rgba{}, 0)
+		}
+	}
+	return img
+}
+
+// PNG writes b as a PNG image to w, see Image
+func PNG(w io.Writer, b *position.BoardStruct, opt *Options) error {
+	return png.Encode(w, Image(b, opt))
+}
+
+// fillSquare blends col over sq
+func fillSquare(img *image.RGBA, g geometry, sq int, col rgba) {
+	c := g.corner(sq)
+	x0, y0 := int(math.Round(c.x)), int(math.Round(c.y))
+	x1, y1 := int(math.Round(c.x+g.sq)), int(math.Round(c.y+g.sq))
+	for y := y0; y < y1; y++ {
+		for x := x0; x < x1; x++ {
+			blend(img, x, y, col, 1)
+		}
+	}
+}
+
+// fillPolygon fills pts with fill and strokes its edges width wide with stroke. The edges of
+// the polygon are smoothed by sampling every pixel samples x samples times
+func fillPolygon(img *image.RGBA, pts []point, fill, stroke rgba, width float64) {
+	minX, minY, maxX, maxY := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	for _, p := range pts {
+		minX, minY = math.Min(minX, p.x), math.Min(minY, p.y)
+		maxX, maxY = math.Max(maxX, p.x), math.Max(maxY, p.y)
+	}
+	half := width / 2
+	bounds := img.Bounds()
+	x0, y0 := max(int(minX-half), bounds.Min.X), max(int(minY-half), bounds.Min.Y)
+	x1, y1 := min(int(maxX+half)+1, bounds.Max.X), min(int(maxY+half)+1, bounds.Max.Y)
+
+	for y := y0; y < y1; y++ {
+		for x := x0; x < x1; x++ {
+			nFill, nStroke := 0, 0
+			for sy := 0; sy < samples; sy++ {
+				for sx := 0; sx < samples; sx++ {
+					p := point{float64(x) + (float64(sx)+0.5)/samples, float64(y) + (float64(sy)+0.5)/samples}
+					switch {
+					case width > 0 && edgeDistance(pts, p) <= half:
+						nStroke++
+					case inside(pts, p):
+						nFill++
+					}
+				}
+			}
+			if nFill > 0 {
+				blend(img, x, y, fill, float64(nFill)/(samples*samples))
+			}
+			if nStroke > 0 {
+				blend(img, x, y, stroke, float64(nStroke)/(samples*samples))
+			}
+		}
+	}
+}
+
+// inside tells if p is inside the polygon pts by the even-odd rule
+func inside(pts []point, p point) bool {
+	in := false
+	for i, j := 0, len(pts)-1; i < len(pts); j, i = i, i+1 {
+		a, b := pts[i], pts[j]
+		if (a.y > p.y) != (b.y > p.y) && p.x < (b.x-a.x)*(p.y-a.y)/(b.y-a.y)+a.x {
+			in = !in
+		}
+	}
+	return in
+}
+
+// edgeDistance returns the distance from p to the nearest edge of the polygon pts
+func edgeDistance(pts []point, p point) float64 {
+	dist := math.Inf(1)
+	for i, j := 0, len(pts)-1; i < len(pts); j, i = i, i+1 {
+		a, b := pts[j], pts[i]
+		dx, dy := b.x-a.x, b.y-a.y
+		t := 0.0
+		if l := dx*dx + dy*dy; l > 0 {
+			t = math.Max(0, math.Min(1, ((p.x-a.x)*dx+(p.y-a.y)*dy)/l))
+		}
+		dist = math.Min(dist, math.Hypot(p.x-a.x-t*dx, p.y-a.y-t*dy))
+	}
+	return dist
+}
+
+// blend draws col over the pixel x, y with the coverage cov from 0 to 1
+func blend(img *image.RGBA, x, y int, col rgba, cov float64) {
+	a := cov * float64(col.a) / 255
+	dst := img.RGBAAt(x, y)
+	mix := func(s, d uint8) uint8 {
+		return uint8(math.Round(float64(s)*a + float64(d)*(1-a)))
+	}
+	img.SetRGBA(x, y, color.RGBA{mix(col.r, dst.R), mix(col.g, dst.G), mix(col.b, dst.B), 255})
+}
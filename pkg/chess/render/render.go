@@ -0,0 +1,224 @@
+// Package render draws a board as a Unicode text diagram, an SVG image or a PNG image.
+//
+// SVG and PNG draw the same piece shapes and highlights: the squares of the last move, the
+// king of the side to move in check and arrows. PNG is rendered in pure Go and, without a
+// font, has no coordinates.
+package render
+
+import (
+	"math"
+	"strings"
+
+	. "github.com/Tecu23/go-game/pkg/chess/constants"
+	"github.com/Tecu23/go-game/pkg/chess/moves"
+	"github.com/Tecu23/go-game/pkg/chess/position"
+)
+
+// DefaultSize is the width and height of an image in pixels when Options.Size is 0
+const DefaultSize = 360
+
+// Options are what SVG and PNG draw besides the pieces. The zero value draws the bare board
+type Options struct {
+	Size     int        // the width and height in pixels
+	Flip     bool       // black at the bottom
+	LastMove moves.Move // its from and to squares are highlighted unless NoMove
+	Check    bool       // highlight the king of the side to move if it is in check
+	Arrows   []Arrow
+}
+
+// Arrow is an arrow from one square to another, like a move
+type Arrow struct {
+	Fr, To int
+}
+
+// the colors of the board, the pieces and the highlights
+var (
+	lightSq   = rgba{240, 217, 181, 255}
+	darkSq    = rgba{181, 136, 99, 255}
+	lastMvSq  = rgba{155, 199, 0, 105}
+	checkSq   = rgba{255, 0, 0, 128}
+	arrowCol  = rgba{21, 120, 27, 170}
+	pieceFill = [2]rgba{{255, 255, 255, 255}, {40, 40, 40, 255}}
+	outline   = rgba{0, 0, 0, 255}
+)
+
+type rgba struct {
+	r, g, b, a uint8
+}
+
+type point struct {
+	x, y float64
+}
+
+// shapes are the outlines of the pieces by pt in a unit square, y down
+var shapes = [NoPiecesT][]point{
+	Pawn: append(append([]point{{0.25, 0.85}, {0.75, 0.85}, {0.72, 0.78}, {0.6, 0.72}, {0.58, 0.55}},
+		arc(0.5, 0.4, 0.13, -50, 230, 12)...),
+		point{0.42, 0.55}, point{0.4, 0.72}, point{0.28, 0.78}),
+	Knight: {{0.24, 0.85}, {0.78, 0.85}, {0.76, 0.6}, {0.72, 0.4}, {0.62, 0.26}, {0.5, 0.2}, {0.46, 0.13},
+		{0.4, 0.22}, {0.3, 0.3}, {0.2, 0.48}, {0.24, 0.56}, {0.32, 0.54}, {0.42, 0.48}, {0.44, 0.55},
+		{0.32, 0.7}, {0.28, 0.77}},
+	Bishop: {{0.22, 0.85}, {0.78, 0.85}, {0.78, 0.77}, {0.6, 0.77}, {0.6, 0.7}, {0.66, 0.62}, {0.68, 0.5},
+		{0.62, 0.36}, {0.5, 0.2}, {0.38, 0.36}, {0.32, 0.5}, {0.34, 0.62}, {0.4, 0.7}, {0.4, 0.77},
+		{0.22, 0.77}},
+	Rook: {{0.22, 0.85}, {0.78, 0.85}, {0.78, 0.77}, {0.7, 0.77}, {0.66, 0.42}, {0.74, 0.35}, {0.74, 0.2},
+		{0.65, 0.2}, {0.65, 0.27}, {0.56, 0.27}, {0.56, 0.2}, {0.44, 0.2}, {0.44, 0.27}, {0.35, 0.27},
+		{0.35, 0.2}, {0.26, 0.2}, {0.26, 0.35}, {0.34, 0.42}, {0.3, 0.77}, {0.22, 0.77}},
+	Queen: {{0.22, 0.85}, {0.78, 0.85}, {0.78, 0.77}, {0.72, 0.77}, {0.84, 0.3}, {0.68, 0.55}, {0.64, 0.22},
+		{0.56, 0.52}, {0.5, 0.18}, {0.44, 0.52}, {0.36, 0.22}, {0.32, 0.55}, {0.16, 0.3}, {0.28, 0.77},
+		{0.22, 0.77}},
+	King: {{0.22, 0.85}, {0.78, 0.85}, {0.78, 0.77}, {0.7, 0.77}, {0.76, 0.45}, {0.6, 0.4}, {0.54, 0.4},
+		{0.54, 0.3}, {0.62, 0.3}, {0.62, 0.23}, {0.54, 0.23}, {0.54, 0.14}, {0.46, 0.14}, {0.46, 0.23},
+		{0.38, 0.23}, {0.38, 0.3}, {0.46, 0.3}, {0.46, 0.4}, {0.4, 0.4}, {0.24, 0.45}, {0.3, 0.77},
+		{0.22, 0.77}},
+}
+
+// arc returns n+1 points on the circle around cx, cy from angle a0 to a1 in degrees,
+// counterclockwise on the screen
+func arc(cx, cy, r, a0, a1 float64, n int) []point {
+	pts := make([]point, 0, n+1)
+	for i := 0; i <= n; i++ {
+		a := (a0 + (a1-a0)*float64(i)/float64(n)) * math.Pi / 180
+		pts = append(pts, point{cx + r*math.Cos(a), cy - r*math.Sin(a)})
+	}
+	return pts
+}
+
+var sideNames = [2]string{"white", "black"}
+
+// unicodePieces are the chess symbols by pc
+var unicodePieces = [NoPiecesC]string{"♙", "♟", "♘", "♞", "♗", "♝", "♖", "♜", "♕", "♛", "♔", "♚"}
+
+// Text returns b as a diagram of Unicode chess symbols with the coordinates, the side to
+// move and, in Crazyhouse, the pieces in hand. Each line ends with a newline
+func Text(b *position.BoardStruct, flip bool) string {
+	var sb strings.Builder
+	for ix := 0; ix < 8; ix++ {
+		rank := 7 - ix
+		if flip {
+			rank = ix
+		}
+		sb.WriteString(Sq2Fen[rank*8][1:])
+		for jx := 0; jx < 8; jx++ {
+			file := jx
+			if flip {
+				file = 7 - jx
+			}
+			sb.WriteString(" ")
+			if pc := b.Squares[rank*8+file]; pc != Empty {
+				sb.WriteString(unicodePieces[pc])
+			} else {
+				sb.WriteString("·")
+			}
+		}
+		sb.WriteString("\n")
+	}
+	files := "a b c d e f g h"
+	if flip {
+		files = "h g f e d c b a"
+	}
+	sb.WriteString("  " + files + "\n")
+
+	if b.Variant != nil && b.Variant.Drops() {
+		for sd := WHITE; sd <= BLACK; sd++ {
+			sb.WriteString(sideNames[sd] + " in hand:")
+			for pt := Queen; pt >= Pawn; pt-- {
+				pc := position.Pt2pc(pt, sd)
+				for n := 0; n < b.Pockets[pc]; n++ {
+					sb.WriteString(" " + unicodePieces[pc])
+				}
+			}
+			sb.WriteString("\n")
+		}
+	}
+	sb.WriteString(sideNames[b.Stm] + " to move\n")
+	return sb.String()
+}
+
+// geometry places the squares of a board in an image
+type geometry struct {
+	size float64 // of the image
+	sq   float64 // the size of a square
+	flip bool
+}
+
+func newGeometry(opt *Options) geometry {
+	size := DefaultSize
+	if opt.Size > 0 {
+		size = opt.Size
+	}
+	return geometry{float64(size), float64(size) / 8, opt.Flip}
+}
+
+// corner returns the top left corner of sq
+func (g geometry) corner(sq int) point {
+	file, rank := sq%8, 7-sq/8
+	if g.flip {
+		file, rank = 7-file, sq/8
+	}
+	return point{float64(file) * g.sq, float64(rank) * g.sq}
+}
+
+// center returns the center of sq
+func (g geometry) center(sq int) point {
+	c := g.corner(sq)
+	return point{c.x + g.sq/2, c.y + g.sq/2}
+}
+
+// piece returns the outline of a piece of type pt on sq
+func (g geometry) piece(pt, sq int) []point {
+	c := g.corner(sq)
+	pts := make([]point, len(shapes[pt]))
+	for i, p := range shapes[pt] {
+		pts[i] = point{c.x + p.x*g.sq, c.y + p.y*g.sq}
+	}
+	return pts
+}
+
+// arrow returns the outline of an arrow from the center of fr to the center of to
+func (g geometry) arrow(a Arrow) []point {
+	p0, p1 := g.center(a.Fr), g.center(a.To)
+	dx, dy := p1.x-p0.x, p1.y-p0.y
+	l := math.Hypot(dx, dy)
+	if l == 0 {
+		return nil
+	}
+	ux, uy := dx/l, dy/l // along the arrow
+	nx, ny := -uy, ux    // across it
+	shaft, head, headLen := g.sq*0.08, g.sq*0.22, math.Min(g.sq*0.45, l)
+	base := point{p1.x - ux*headLen, p1.y - uy*headLen}
+	return []point{
+		{p0.x + nx*shaft, p0.y + ny*shaft},
+		{base.x + nx*shaft, base.y + ny*shaft},
+		{base.x + nx*head, base.y + ny*head},
+		p1,
+		{base.x - nx*head, base.y - ny*head},
+		{base.x - nx*shaft, base.y - ny*shaft},
+		{p0.x - nx*shaft, p0.y - ny*shaft},
+	}
+}
+
+// highlights returns the highlighted squares with their colors, in drawing order
+func highlights(b *position.BoardStruct, opt *Options) (sqs []int, cols []rgba) {
+	if opt.LastMove != moves.NoMove {
+		sqs = append(sqs, opt.LastMove.Fr())
+		cols = append(cols, lastMvSq)
+		if opt.LastMove.To() != opt.LastMove.Fr() {
+			sqs = append(sqs, opt.LastMove.To())
+			cols = append(cols, lastMvSq)
+		}
+	}
+	if opt.Check && b.Variant != nil && b.InCheck() {
+		sqs = append(sqs, b.King[b.Stm])
+		cols = append(cols, checkSq)
+	}
+	return sqs, cols
+}
+
+// squareColor returns the color of sq on the board
+func squareColor(sq int) rgba {
+	if (sq/8+sq%8)%2 == 0 {
+		return darkSq
+	}
+	return lightSq
+}
@@ -0,0 +1,163 @@
+package render
+
+import (
+	"bytes"
+	"encoding/xml"
+	"image/color"
+	"image/png"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/Tecu23/go-game/pkg/chess/castlings"
+	. "github.com/Tecu23/go-game/pkg/chess/constants"
+	"github.com/Tecu23/go-game/pkg/chess/magic"
+	"github.com/Tecu23/go-game/pkg/chess/moves"
+	"github.com/Tecu23/go-game/pkg/chess/position"
+)
+
+func TestMain(m *testing.M) {
+	InitFen2Sq()
+	magic.InitMagic()
+	position.InitKeys()
+	position.InitAtksKings()
+	position.InitAtksKnights()
+	castlings.InitCastlings()
+	position.PcSqInit()
+
+	os.Exit(m.Run())
+}
+
+// checkFEN is after 1.f3 e5 2.g4, black mates with Qh4
+const checkFEN = "rnb1kbnr/pppp1ppp/8/4p3/6Pq/5P2/PPPPP2P/RNBQKBNR w KQkq - 1 3"
+
+func parse(t *testing.T, fen string, v position.Variant) *position.BoardStruct {
+	t.Helper()
+	b, err := position.ParseFENVariant(fen, v, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestText(t *testing.T) {
+	b := parse(t, Startpos, position.Standard)
+	want := `8 ♜ ♞ ♝ ♛ ♚ ♝ ♞ ♜
+7 ♟ ♟ ♟ ♟ ♟ ♟ ♟ ♟
+6 · · · · · · · ·
+5 · · · · · · · ·
+4 · · · · · · · ·
+3 · · · · · · · ·
+2 ♙ ♙ ♙ ♙ ♙ ♙ ♙ ♙
+1 ♖ ♘ ♗ ♕ ♔ ♗ ♘ ♖
+  a b c d e f g h
+white to move
+`
+	if got := Text(b, false); got != want {
+		t.Errorf("got\n%v\nwant\n%v", got, want)
+	}
+
+	got := strings.Split(Text(b, true), "\n")
+	if got[0] != "1 ♖ ♘ ♗ ♔ ♕ ♗ ♘ ♖" || got[8] != "  h g f e d c b a" {
+		t.Errorf("flipped: got %q and %q", got[0], got[8])
+	}
+
+	b = parse(t, "4k3/8/8/8/8/8/8/4K3[QNPPr] b - - 0 1", position.Crazyhouse)
+	if got := Text(b, false); !strings.HasSuffix(got, "white in hand: ♕ ♘ ♙ ♙\nblack in hand: ♜\nblack to move\n") {
+		t.Errorf("got\n%v\nwithout the pockets", got)
+	}
+}
+
+// svgElements counts the elements of an SVG image by name and checks that it is well formed
+func svgElements(t *testing.T, svg string) map[string]int {
+	t.Helper()
+	cnt := map[string]int{}
+	dec := xml.NewDecoder(strings.NewReader(svg))
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return cnt
+		}
+		if err != nil {
+			t.Fatalf("invalid svg: %v", err)
+		}
+		if el, ok := tok.(xml.StartElement); ok {
+			cnt[el.Name.Local]++
+		}
+	}
+}
+
+func TestSVG(t *testing.T) {
+	b := parse(t, checkFEN, position.Standard)
+	var mv moves.Move
+	mv.PackMove(D8, H4, BQ, Empty, Empty, 0, 0)
+
+	tests := []struct {
+		name  string
+		opt   *Options
+		rects int
+		polys int
+		check bool
+	}{
+		{"bare", nil, 64, 32, false},
+		{"last move", &Options{LastMove: mv}, 66, 32, false},
+		{"check and arrows", &Options{Check: true, Arrows: []Arrow{{E1, F2}, {G1, F3}}}, 65, 34, true},
+	}
+	for _, tt := range tests {
+		svg := SVG(b, tt.opt)
+		cnt := svgElements(t, svg)
+		if cnt["svg"] != 1 || cnt["rect"] != tt.rects || cnt["polygon"] != tt.polys || cnt["text"] != 16 {
+			t.Errorf("%v: got %v elements, want %v rects and %v polygons", tt.name, cnt, tt.rects, tt.polys)
+		}
+		if got := strings.Contains(svg, svgColor(checkSq)); got != tt.check {
+			t.Errorf("%v: the check highlight is %v, want %v", tt.name, got, tt.check)
+		}
+	}
+
+	if svg := SVG(b, &Options{Size: 200}); !strings.HasPrefix(svg, `<svg xmlns="http://www.w3.org/2000/svg" width="200" height="200"`) {
+		t.Errorf("got %.90v, want a 200 pixel image", svg)
+	}
+}
+
+func TestPNG(t *testing.T) {
+	b := parse(t, checkFEN, position.Standard)
+	var mv moves.Move
+	mv.PackMove(D8, H4, BQ, Empty, Empty, 0, 0)
+
+	var buf bytes.Buffer
+	opt := &Options{Size: 160, LastMove: mv, Check: true, Arrows: []Arrow{{B1, C3}}}
+	if err := PNG(&buf, b, opt); err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := img.Bounds().Dx(); got != 160 {
+		t.Fatalf("got a %v pixel image, want 160", got)
+	}
+
+	// the top left pixel of a square, 20 pixels each
+	at := func(sq int) color.RGBA {
+		return color.RGBAModel.Convert(img.At(sq%8*20, (7-sq/8)*20)).(color.RGBA)
+	}
+	rgb := func(c rgba) color.RGBA { return color.RGBA{c.r, c.g, c.b, 255} }
+	if at(A1) != rgb(darkSq) || at(B1) != rgb(lightSq) || at(A8) != rgb(lightSq) {
+		t.Errorf("got %v %v %v for a1, b1 and a8, want dark, light and light", at(A1), at(B1), at(A8))
+	}
+	for _, sq := range []int{D8, H4, E1} {
+		if c := at(sq); c == rgb(squareColor(sq)) {
+			t.Errorf("%v is not highlighted", Sq2Fen[sq])
+		}
+	}
+	if c := at(E1); c.R <= c.G {
+		t.Errorf("got %v for e1, want the red of the check", c)
+	}
+
+	// the arrow runs through the center of the b2-c2 edge, the pawns are lower
+	c := color.RGBAModel.Convert(img.At(40, 130)).(color.RGBA)
+	if c.G <= c.R || c.G <= c.B {
+		t.Errorf("got %v on the arrow, want green", c)
+	}
+}
@@ -0,0 +1,92 @@
+package render
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	. "github.com/Tecu23/go-game/pkg/chess/constants"
+	"github.com/Tecu23/go-game/pkg/chess/position"
+)
+
+// SVG returns b as an SVG image with the highlights and arrows of opt, nil for none
+func SVG(b *position.BoardStruct, opt *Options) string {
+	var sb strings.Builder
+	WriteSVG(&sb, b, opt)
+	return sb.String()
+}
+
+// WriteSVG writes b as an SVG image to w, see SVG
+func WriteSVG(w io.Writer, b *position.BoardStruct, opt *Options) error {
+	if opt == nil {
+		opt = &Options{}
+	}
+	g := newGeometry(opt)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, `<svg xmlns="http://www.w3.org/2000/svg" width="%v" height="%v" viewBox="0 0 %v %v">`+"\n",
+		g.size, g.size, g.size, g.size)
+
+	for sq := A1; sq <= H8; sq++ {
+		sb.WriteString(svgRect(g.corner(sq), g.sq, squareColor(sq)))
+	}
+	sqs, cols := highlights(b, opt)
+	for i, sq := range sqs {
+		sb.WriteString(svgRect(g.corner(sq), g.sq, cols[i]))
+	}
+
+	// the coordinates in the squares of the bottom rank and the left file
+	fontSize := g.sq / 5
+	for sq := A1; sq <= H8; sq++ {
+		c := g.corner(sq)
+		txtCol := svgColor(squareColor(sq ^ 1))
+		if c.y+g.sq >= g.size-1 {
+			fmt.Fprintf(&sb, `<text x="%.1f" y="%.1f" font-size="%.1f" font-family="sans-serif" fill="%v">%v</text>`+"\n",
+				c.x+g.sq-fontSize*0.8, c.y+g.sq-fontSize*0.3, fontSize, txtCol, Sq2Fen[sq][:1])
+		}
+		if c.x < 1 {
+			fmt.Fprintf(&sb, `<text x="%.1f" y="%.1f" font-size="%.1f" font-family="sans-serif" fill="%v">%v</text>`+"\n",
+				c.x+fontSize*0.3, c.y+fontSize*1.1, fontSize, txtCol, Sq2Fen[sq][1:])
+		}
+	}
+
+	for sq, pc := range b.Squares {
+		if pc == Empty {
+			continue
+		}
+		fmt.Fprintf(&sb, `<polygon points="%v" fill="%v" stroke="%v" stroke-width="%.1f" stroke-linejoin="round"/>`+"\n",
+			svgPoints(g.piece(position.Pc2pt(pc), sq)), svgColor(pieceFill[position.PcColor(pc)]),
+			svgColor(outline), g.sq/30)
+	}
+
+	for _, a := range opt.Arrows {
+		if pts := g.arrow(a); pts != nil {
+			fmt.Fprintf(&sb, `<polygon points="%v" fill="%v"/>`+"\n", svgPoints(pts), svgColor(arrowCol))
+		}
+	}
+
+	sb.WriteString("</svg>\n")
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func svgRect(p point, size float64, col rgba) string {
+	return fmt.Sprintf(`<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%v"/>`+"\n",
+		p.x, p.y, size, size, svgColor(col))
+}
+
+// svgColor writes col as rgb or rgba
+func svgColor(col rgba) string {
+	if col.a == 255 {
+		return fmt.Sprintf("#%02x%02x%02x", col.r, col.g, col.b)
+	}
+	return fmt.Sprintf("rgba(%v,%v,%v,%.2f)", col.r, col.g, col.b, float64(col.a)/255)
+}
+
+func svgPoints(pts []point) string {
+	s := make([]string, len(pts))
+	for i, p := range pts {
+		s[i] = fmt.Sprintf("%.1f,%.1f", p.x, p.y)
+	}
+	return strings.Join(s, " ")
+}
@@ -0,0 +1,666 @@
+package syzygy
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+
+	"github.com/Tecu23/go-game/pkg/chess/bitboard"
+	. "github.com/Tecu23/go-game/pkg/chess/constants"
+	"github.com/Tecu23/go-game/pkg/chess/moves"
+	"github.com/Tecu23/go-game/pkg/chess/position"
+)
+
+// index tables used to encode a position
+var (
+	mapB1H1H7     [64]int     // squares below the a1-h8 diagonal to 0..27
+	mapA1D1D4     [64]int     // squares in the a1-d1-d4 triangle to 0..9, the diagonal last
+	mapKK         [10][64]int // the 462 legal king pairs with the first king in the triangle
+	mapPawns      [64]int     // pawn squares to 0..47, the highest is the leading pawn
+	binomial      [tbPieces][64]uint64
+	leadPawnIdx   [tbPieces][64]int
+	leadPawnsSize [tbPieces][4]int
+	indexesDone   bool
+)
+
+// result of a probe besides the value
+type probeState int
+
+const (
+	probeFail           probeState = 0
+	probeOK             probeState = 1
+	probeChangeStm      probeState = -1 // the dtz table only has the other side to move
+	probeZeroingBestMov probeState = 2  // the best move is a capture or a pawn move
+)
+
+// offA1H8 is > 0 above the a1-h8 diagonal, 0 on it and < 0 below it
+func offA1H8(sq int) int {
+	return sq/8 - sq%8
+}
+
+func initIndexes() {
+	if indexesDone {
+		return
+	}
+	indexesDone = true
+
+	code := 0
+	for sq := A1; sq <= H8; sq++ {
+		if offA1H8(sq) < 0 {
+			mapB1H1H7[sq] = code
+			code++
+		}
+	}
+
+	code = 0
+	var diagonal []int
+	for sq := A1; sq <= D4; sq++ {
+		if offA1H8(sq) < 0 && sq%8 <= 3 {
+			mapA1D1D4[sq] = code
+			code++
+		} else if offA1H8(sq) == 0 && sq%8 <= 3 {
+			diagonal = append(diagonal, sq)
+		}
+	}
+	for _, sq := range diagonal {
+		mapA1D1D4[sq] = code
+		code++
+	}
+
+	inTriangle := func(sq int) bool {
+		return sq <= D4 && sq%8 <= 3 && offA1H8(sq) <= 0
+	}
+	type pair struct{ idx, sq int }
+	var bothOnDiagonal []pair
+	code = 0
+	for idx := 0; idx < 10; idx++ {
+		for s1 := A1; s1 <= D4; s1++ {
+			if !inTriangle(s1) || mapA1D1D4[s1] != idx {
+				continue
+			}
+			for s2 := A1; s2 <= H8; s2++ {
+				switch {
+				case position.SqDistance(s1, s2) <= 1:
+					continue // illegal
+				case offA1H8(s1) == 0 && offA1H8(s2) > 0:
+					continue // first king on the diagonal, second above it
+				case offA1H8(s1) == 0 && offA1H8(s2) == 0:
+					bothOnDiagonal = append(bothOnDiagonal, pair{idx, s2})
+				default:
+					mapKK[idx][s2] = code
+					code++
+				}
+			}
+		}
+	}
+	for _, p := range bothOnDiagonal {
+		mapKK[p.idx][p.sq] = code
+		code++
+	}
+
+	binomial[0][0] = 1
+	for n := 1; n < 64; n++ {
+		for k := 0; k < tbPieces && k <= n; k++ {
+			if k > 0 {
+				binomial[k][n] += binomial[k-1][n-1]
+			}
+			if k < n {
+				binomial[k][n] += binomial[k][n-1]
+			}
+		}
+	}
+
+	// the leading pawn is the one nearest the edge and with the lowest rank.
+	// The other leading pawns can't be on the squares before it
+	available := 47
+	for cnt := 1; cnt < tbPieces-1; cnt++ {
+		for f := 0; f < 4; f++ {
+			idx := 0
+			for r := 1; r <= 6; r++ {
+				sq := r*8 + f
+				if cnt == 1 {
+					mapPawns[sq] = available
+					available--
+					mapPawns[sq^7] = available
+					available--
+				}
+				leadPawnIdx[cnt][sq] = idx
+				idx += int(binomial[cnt-1][mapPawns[sq]])
+			}
+			leadPawnsSize[cnt][f] = idx
+		}
+	}
+}
+
+// tbPiece converts our piece to the piece code in the tables
+func tbPiece(pc int) int {
+	return position.Pc2pt(pc) + 1 + 8*int(position.PcColor(pc))
+}
+
+// probeTable looks up the position in the WDL or the DTZ table.
+// wdl is the known result of the position and is only used for dtz
+func probeTable(b *position.BoardStruct, dtz bool, wdl WDL) (val int, st probeState) {
+	if b.AllBB().Count() == 2 {
+		return int(Draw), probeOK
+	}
+
+	tables := wdlTables
+	if dtz {
+		tables = dtzTables
+	}
+	t := tables[materialKey(&b.Count)]
+	if t == nil || t.load() != nil {
+		return 0, probeFail
+	}
+
+	// a file that is cut short can still be read out of range
+	defer func() {
+		if r := recover(); r != nil {
+			val, st = 0, probeFail
+		}
+	}()
+	return t.probe(b, wdl)
+}
+
+// probe computes the index of the position in the table and decompresses the value
+func (t *table) probe(b *position.BoardStruct, wdl WDL) (int, probeState) {
+	stm, tbFile, idx := t.index(b)
+	if t.dtz && !t.checkDTZStm(stm, tbFile) {
+		return 0, probeChangeStm
+	}
+
+	value := t.get(stm, tbFile).decompress(idx)
+	if !t.dtz {
+		return value - 2, probeOK
+	}
+	return t.mapScore(tbFile, value, wdl), probeOK
+}
+
+// index returns the side to move and the leading pawn file the position is stored
+// under, and its index there
+func (t *table) index(b *position.BoardStruct) (stm, tbFile int, idx uint64) {
+	var squares, pieces [tbPieces]int
+
+	// the tables are stored with the first side of the file name as white. Symmetric
+	// tables only store white to move. In both cases we flip colors and squares
+	symmetricBlackToMove := t.key == t.key2 && b.Stm == BLACK
+	blackStronger := materialKey(&b.Count) != t.key
+	flipColor, flipSquares := 0, 0
+	stm = int(b.Stm)
+	if symmetricBlackToMove || blackStronger {
+		flipColor, flipSquares, stm = 8, 56, stm^1
+	}
+
+	size, leadPawnsCnt := 0, 0
+	var leadPawns bitboard.BitBoard
+	if t.hasPawns {
+		// the leading pawns are the first pieces in all four tables
+		color := Color((t.items[0][0].pieces[0] ^ flipColor) >> 3)
+		leadPawns = b.PieceBB[Pawn] & b.WbBB[color]
+		bb := leadPawns
+		for sq := bb.FirstOne(); sq != 64; sq = bb.FirstOne() {
+			squares[size] = sq ^ flipSquares
+			size++
+		}
+		leadPawnsCnt = size
+
+		mx := 0
+		for i := 1; i < leadPawnsCnt; i++ {
+			if mapPawns[squares[i]] > mapPawns[squares[mx]] {
+				mx = i
+			}
+		}
+		squares[0], squares[mx] = squares[mx], squares[0]
+		tbFile = Min(squares[0]%8, 7-squares[0]%8)
+	}
+
+	bb := b.AllBB() &^ leadPawns
+	for sq := bb.FirstOne(); sq != 64; sq = bb.FirstOne() {
+		squares[size] = sq ^ flipSquares
+		pieces[size] = tbPiece(b.Squares[sq]) ^ flipColor
+		size++
+	}
+
+	// put the pieces in the order of the table
+	d := t.get(stm, tbFile)
+	for i := leadPawnsCnt; i < size-1; i++ {
+		for j := i + 1; j < size; j++ {
+			if d.pieces[i] == pieces[j] {
+				pieces[i], pieces[j] = pieces[j], pieces[i]
+				squares[i], squares[j] = squares[j], squares[i]
+				break
+			}
+		}
+	}
+
+	// the leading piece goes to the a-d files
+	if squares[0]%8 > 3 {
+		for i := 0; i < size; i++ {
+			squares[i] ^= 7
+		}
+	}
+
+	if t.hasPawns {
+		idx = uint64(leadPawnIdx[leadPawnsCnt][squares[0]])
+		others := squares[1:leadPawnsCnt]
+		sort.SliceStable(others, func(i, j int) bool { return mapPawns[others[i]] < mapPawns[others[j]] })
+		for i := 1; i < leadPawnsCnt; i++ {
+			idx += binomial[i][mapPawns[squares[i]]]
+		}
+	} else {
+		idx = t.leadingIndex(d, squares[:size])
+	}
+
+	// the remaining groups, each sorted by square
+	idx *= d.groupIdx[0]
+	start := d.groupLen[0]
+	remainingPawns := t.hasPawns && t.pawnCount[1] > 0
+	for next := 1; d.groupLen[next] != 0; next++ {
+		group := squares[start : start+d.groupLen[next]]
+		sort.Ints(group)
+
+		n := uint64(0)
+		for i, sq := range group {
+			// squares taken by the earlier groups are skipped
+			adjust := 0
+			for _, s := range squares[:start] {
+				if sq > s {
+					adjust++
+				}
+			}
+			if remainingPawns {
+				adjust += 8
+			}
+			n += binomial[i+1][sq-adjust]
+		}
+
+		remainingPawns = false
+		idx += n * d.groupIdx[next]
+		start += d.groupLen[next]
+	}
+	return stm, tbFile, idx
+}
+
+// leadingIndex encodes the leading group of a table without pawns.
+// The first piece is mapped to the a1-d1-d4 triangle
+func (t *table) leadingIndex(d *pairsData, squares []int) uint64 {
+	if squares[0]/8 > 3 {
+		for i := range squares {
+			squares[i] ^= 56
+		}
+	}
+
+	// the first piece of the leading group that is not on the diagonal goes below it
+	for i := 0; i < d.groupLen[0]; i++ {
+		if offA1H8(squares[i]) == 0 {
+			continue
+		}
+		if offA1H8(squares[i]) > 0 {
+			for j := i; j < len(squares); j++ {
+				squares[j] = ((squares[j] >> 3) | (squares[j] << 3)) & 63
+			}
+		}
+		break
+	}
+
+	if !t.hasUniquePieces {
+		return uint64(mapKK[mapA1D1D4[squares[0]]][squares[1]])
+	}
+
+	adjust1, adjust2 := 0, 0
+	if squares[1] > squares[0] {
+		adjust1++
+	}
+	if squares[2] > squares[0] {
+		adjust2++
+	}
+	if squares[2] > squares[1] {
+		adjust2++
+	}
+
+	switch {
+	case offA1H8(squares[0]) != 0:
+		return uint64((mapA1D1D4[squares[0]]*63+squares[1]-adjust1)*62 + squares[2] - adjust2)
+	case offA1H8(squares[1]) != 0:
+		return uint64((6*63+(squares[0]/8)*28+mapB1H1H7[squares[1]])*62 + squares[2] - adjust2)
+	case offA1H8(squares[2]) != 0:
+		return uint64(6*63*62 + 4*28*62 + (squares[0]/8)*7*28 + (squares[1]/8-adjust1)*28 + mapB1H1H7[squares[2]])
+	}
+	return uint64(6*63*62 + 4*28*62 + 4*7*28 + (squares[0]/8)*7*6 + (squares[1]/8-adjust1)*6 + squares[2]/8 - adjust2)
+}
+
+// checkDTZStm returns true if the dtz table stores the positions with stm to move
+func (t *table) checkDTZStm(stm, f int) bool {
+	flags := int(t.get(stm, f).flags)
+	return flags&flagStm == stm || (t.key == t.key2 && !t.hasPawns)
+}
+
+// mapScore converts a stored dtz value to plies
+func (t *table) mapScore(f, value int, wdl WDL) int {
+	wdlMap := [5]int{1, 3, 0, 2, 0}
+
+	d := t.get(0, f)
+	if d.flags&flagMapped != 0 {
+		ix := d.mapIdx[wdlMap[wdl+2]] + value
+		if d.flags&flagWide != 0 {
+			value = int(binary.LittleEndian.Uint16(t.dtzMap[2*ix:]))
+		} else {
+			value = int(t.dtzMap[ix])
+		}
+	}
+
+	if (wdl == Win && d.flags&flagWinPlies == 0) || (wdl == Loss && d.flags&flagLossPlies == 0) ||
+		wdl == CursedWin || wdl == BlessedLoss {
+		value *= 2
+	}
+	return value + 1
+}
+
+// decompress finds the value with index idx
+func (d *pairsData) decompress(idx uint64) int {
+	if d.flags&flagSingleValue != 0 {
+		return d.minSymLen
+	}
+
+	// the sparse index points to the block and the offset of the value in the middle of each span
+	k := idx / d.span
+	block := int(binary.LittleEndian.Uint32(d.sparseIndex[6*k:]))
+	offset := int(binary.LittleEndian.Uint16(d.sparseIndex[6*k+4:]))
+	offset += int(idx%d.span) - int(d.span/2)
+
+	for offset < 0 {
+		block--
+		offset += d.blockLen(block) + 1
+	}
+	for offset > d.blockLen(block) {
+		offset -= d.blockLen(block) + 1
+		block++
+	}
+
+	// read symbols until we get to the one that contains our value
+	ptr := uint64(block) * d.sizeofBlock
+	buf64 := binary.BigEndian.Uint64(d.data[ptr:])
+	ptr += 8
+	buf64Size := 64
+	sym := 0
+	for {
+		l := 0
+		for buf64 < d.base64[l] {
+			l++
+		}
+		sym = int((buf64-d.base64[l])>>uint(64-l-d.minSymLen)) + d.lowest(l)
+
+		if offset < int(d.symlen[sym])+1 {
+			break
+		}
+		offset -= int(d.symlen[sym]) + 1
+
+		l += d.minSymLen
+		buf64 <<= uint(l)
+		buf64Size -= l
+		if buf64Size <= 32 {
+			buf64Size += 32
+			buf64 |= uint64(binary.BigEndian.Uint32(d.data[ptr:])) << uint(64-buf64Size)
+			ptr += 4
+		}
+	}
+
+	// expand the pairs until we get to a single value
+	for d.symlen[sym] != 0 {
+		left := d.left(sym)
+		if offset < int(d.symlen[left])+1 {
+			sym = left
+		} else {
+			offset -= int(d.symlen[left]) + 1
+			sym = d.right(sym)
+		}
+	}
+
+	return d.left(sym)
+}
+
+// isCapture is true for captures and en passant
+func isCapture(b *position.BoardStruct, mv moves.Move) bool {
+	return b.Squares[mv.To()] != Empty ||
+		(position.Pc2pt(b.Squares[mv.Fr()]) == Pawn && b.Ep != 0 && int(mv.To()) == b.Ep)
+}
+
+// isZeroing is true for moves that reset the 50 move counter
+func isZeroing(b *position.BoardStruct, mv moves.Move) bool {
+	return isCapture(b, mv) || position.Pc2pt(b.Squares[mv.Fr()]) == Pawn
+}
+
+// search tries the captures (and the pawn moves if zeroing is set) before probing.
+// The tables don't know about en passant and store any value when the best move is a capture
+func search(b *position.BoardStruct, zeroing bool) (WDL, probeState) {
+	var ml moves.MoveList
+	b.GenAllLegals(&ml)
+
+	best := Loss
+	cnt := 0
+	for _, mv := range ml {
+		if !isCapture(b, mv) && (!zeroing || !isZeroing(b, mv)) {
+			continue
+		}
+		cnt++
+
+		b.Move(mv)
+		v, st := search(b, false)
+		b.Unmove(mv)
+		if st == probeFail {
+			return Draw, probeFail
+		}
+
+		if -v > best {
+			best = -v
+			if best >= Win {
+				return best, probeZeroingBestMov
+			}
+		}
+	}
+
+	// if all moves were searched the stored value can be wrong
+	noMoreMoves := cnt > 0 && cnt == len(ml)
+	value := best
+	if !noMoreMoves {
+		v, st := probeTable(b, false, Draw)
+		if st == probeFail {
+			return Draw, probeFail
+		}
+		value = WDL(v)
+	}
+
+	if best >= value {
+		if best > Draw || noMoreMoves {
+			return best, probeZeroingBestMov
+		}
+		return best, probeOK
+	}
+	return value, probeOK
+}
+
+// dtzBeforeZeroing is the dtz of a position where the best move resets the 50 move counter
+func dtzBeforeZeroing(wdl WDL) int {
+	switch wdl {
+	case Win:
+		return 1
+	case CursedWin:
+		return 101
+	case BlessedLoss:
+		return -101
+	case Loss:
+		return -1
+	}
+	return 0
+}
+
+func sign(x int) int {
+	switch {
+	case x > 0:
+		return 1
+	case x < 0:
+		return -1
+	}
+	return 0
+}
+
+// canProbe is false for positions that can't be in the tables
+func canProbe(b *position.BoardStruct) bool {
+	return b.Castlings == 0 && b.AllBB().Count() <= MaxPieces
+}
+
+// ProbeWDL returns the result of the position from the side to move pov.
+// ok is false if the position is not in the tables
+func ProbeWDL(b *position.BoardStruct) (wdl WDL, ok bool) {
+	if !canProbe(b) {
+		return Draw, false
+	}
+
+	wdl, st := search(b, false)
+	return wdl, st != probeFail
+}
+
+// ProbeDTZ returns the number of plies to the next capture or pawn move on the way to the
+// result. Positive for wins and negative for losses, 0 for draws
+func ProbeDTZ(b *position.BoardStruct) (dtz int, ok bool) {
+	if !canProbe(b) {
+		return 0, false
+	}
+
+	dtz, st := probeDTZ(b)
+	return dtz, st != probeFail
+}
+
+func probeDTZ(b *position.BoardStruct) (int, probeState) {
+	wdl, st := search(b, true)
+	if st == probeFail || wdl == Draw {
+		return 0, st
+	}
+	if st == probeZeroingBestMov {
+		return dtzBeforeZeroing(wdl), probeOK
+	}
+
+	dtz, st := probeTable(b, true, wdl)
+	if st == probeFail {
+		return 0, probeFail
+	}
+	if st != probeChangeStm {
+		extra := 0
+		if wdl == BlessedLoss || wdl == CursedWin {
+			extra = 100
+		}
+		return (dtz + extra) * sign(int(wdl)), probeOK
+	}
+
+	// the table has the other side to move, so we search one ply for the best dtz
+	var ml moves.MoveList
+	b.GenAllLegals(&ml)
+	minDTZ := 0xFFFF
+	for _, mv := range ml {
+		zeroing := isZeroing(b, mv)
+
+		b.Move(mv)
+		if zeroing {
+			// the dtz before the move, with the sign of the position after it
+			v, st2 := search(b, false)
+			dtz, st = -dtzBeforeZeroing(v), st2
+		} else {
+			dtz, st = probeDTZ(b)
+			dtz = -dtz
+		}
+
+		if dtz == 1 && b.IsAttacked(b.King[b.Stm], b.Stm.Opposite()) && noLegals(b) {
+			minDTZ = 1 // mate
+		}
+		b.Unmove(mv)
+
+		if !zeroing {
+			dtz += sign(dtz)
+		}
+		if dtz < minDTZ && sign(dtz) == sign(int(wdl)) {
+			minDTZ = dtz
+		}
+		if st == probeFail {
+			return 0, probeFail
+		}
+	}
+
+	if minDTZ == 0xFFFF {
+		return -1, probeOK // mate
+	}
+	return minDTZ, probeOK
+}
+
+func noLegals(b *position.BoardStruct) bool {
+	var ml moves.MoveList
+	b.GenAllLegals(&ml)
+	return len(ml) == 0
+}
+
+// RootProbe keeps only the moves in ml with the best dtz. Winning moves are ranked by the
+// shortest dtz so that the engine makes progress, losing moves by the longest. A win or a
+// loss that doesn't come before the 50 move rule (dtz + Rule50 > 100) is ranked as a draw.
+// It returns false, and leaves ml as it was, if the position is not in the tables
+func RootProbe(b *position.BoardStruct, ml *moves.MoveList) bool {
+	if !canProbe(b) || len(*ml) == 0 {
+		return false
+	}
+
+	ranks := make([]int, len(*ml))
+	best := -0xFFFF
+	for ix, mv := range *ml {
+		zeroing := isZeroing(b, mv)
+
+		b.Move(mv)
+		var dtz int
+		var st probeState
+		if zeroing {
+			var wdl WDL
+			wdl, st = search(b, false)
+			dtz = dtzBeforeZeroing(-wdl)
+		} else {
+			dtz, st = probeDTZ(b)
+			dtz = -dtz
+			dtz += sign(dtz)
+		}
+		if dtz == 2 && b.IsAttacked(b.King[b.Stm], b.Stm.Opposite()) && noLegals(b) {
+			dtz = 1 // mate
+		}
+		b.Unmove(mv)
+
+		if st == probeFail {
+			return false
+		}
+
+		// b.Rule50 plies are already used of the 50 moves, a zeroing move starts them again.
+		// The wins and losses the 50 move rule turns into draws are ranked as draws
+		r50 := b.Rule50
+		if zeroing {
+			r50 = 0
+		}
+		switch {
+		case dtz > 0 && dtz+r50 <= 100:
+			ranks[ix] = 1000 - dtz
+		case dtz < 0 && -dtz+r50 <= 100:
+			ranks[ix] = -1000 - dtz
+		}
+		if ranks[ix] > best {
+			best = ranks[ix]
+		}
+	}
+
+	kept := (*ml)[:0]
+	for ix, mv := range *ml {
+		if ranks[ix] == best {
+			kept = append(kept, mv)
+		}
+	}
+	*ml = kept
+	return true
+}
+
+// String shows the tables found, for the uci info string
+func String() string {
+	wdl, dtz := Count()
+	return fmt.Sprintf("found %v wdl and %v dtz tables, up to %v pieces", wdl, dtz, MaxPieces)
+}
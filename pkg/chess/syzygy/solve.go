@@ -0,0 +1,215 @@
+package syzygy
+
+import (
+	"fmt"
+
+	. "github.com/Tecu23/go-game/pkg/chess/constants"
+	"github.com/Tecu23/go-game/pkg/chess/moves"
+	"github.com/Tecu23/go-game/pkg/chess/position"
+)
+
+// solveSize is the number of positions of an ending with the two kings and one white piece,
+// see solveIndex
+const solveSize = 2 * 64 * 64 * 64
+
+// solution is the result of every position of the ending king and pc against king
+type solution struct {
+	pc    int
+	valid []bool
+	wdl   []WDL
+	dtz   []int16 // plies to the next capture, pawn move or mate. Negative for losses, 0 for draws
+}
+
+// solveIndex numbers the position with stm to move, the kings on wk and bk and the white piece on sq
+func solveIndex(stm Color, wk, bk, sq int) int {
+	return ((int(stm)*64+wk)*64+bk)*64 + sq
+}
+
+// setup puts position ix of the ending on b. It returns false if ix is not a legal position
+func (s *solution) setup(b *position.BoardStruct, ix int) bool {
+	sq, bk, wk, stm := ix%64, ix/64%64, ix/4096%64, Color(ix/(64*64*64))
+	if sq == wk || sq == bk || position.SqDistance(wk, bk) <= 1 {
+		return false
+	}
+	if position.Pc2pt(s.pc) == Pawn && (sq < A2 || sq > H7) {
+		return false
+	}
+
+	b.Variant = position.Standard
+	b.Clear()
+	b.SetSq(WK, wk)
+	b.SetSq(BK, bk)
+	b.SetSq(s.pc, sq)
+	b.Stm = stm
+	return !b.IsAttacked(b.King[stm.Opposite()], stm)
+}
+
+// the children of a position. A child in the ending has its index, the others are zeroing
+// moves out of it with their result
+type child struct {
+	ix      int32
+	zeroing bool
+	wdl     int8 // WDL from the child's side to move pov, for ix < 0
+}
+
+// solutions are the endings solved so far by their white piece
+var solutions = map[int]*solution{}
+
+// solve solves the ending king and pc against king by retrograde analysis, the endings a
+// promotion leads to first. The results are kept for the next call
+func solve(pc int) *solution {
+	if s := solutions[pc]; s != nil {
+		return s
+	}
+
+	s := &solution{
+		pc:    pc,
+		valid: make([]bool, solveSize),
+		wdl:   make([]WDL, solveSize),
+		dtz:   make([]int16, solveSize),
+	}
+	var promos map[int]*solution
+	if position.Pc2pt(pc) == Pawn {
+		promos = make(map[int]*solution)
+		for pt := Knight; pt <= Queen; pt++ {
+			promos[position.Pt2pc(pt, WHITE)] = solve(position.Pt2pc(pt, WHITE))
+		}
+	}
+
+	var b position.BoardStruct
+	var ml moves.MoveList
+	first := make([]int32, solveSize+1) // the children of ix are children[first[ix]:first[ix+1]]
+	var children []child
+	mated := make([]bool, solveSize)
+	for ix := 0; ix < solveSize; ix++ {
+		first[ix] = int32(len(children))
+		if !s.setup(&b, ix) {
+			continue
+		}
+		s.valid[ix] = true
+
+		ml = ml[:0]
+		b.GenAllLegals(&ml)
+		if len(ml) == 0 {
+			mated[ix] = b.IsAttacked(b.King[b.Stm], b.Stm.Opposite())
+			continue
+		}
+
+		for _, mv := range ml {
+			pawnMove := position.Pc2pt(b.Squares[mv.Fr()]) == Pawn
+			b.Move(mv)
+			c := child{ix: -1, zeroing: true, wdl: int8(Draw)} // the piece is taken
+			if b.AllBB().Count() == 3 {
+				piece := b.AllBB() &^ b.PieceBB[King]
+				sq := piece.FirstOne()
+				cix := solveIndex(b.Stm, b.King[WHITE], b.King[BLACK], sq)
+				if pc := b.Squares[sq]; pc != s.pc {
+					c.wdl = int8(promos[pc].wdl[cix])
+				} else {
+					c = child{ix: int32(cix), zeroing: pawnMove}
+				}
+			}
+			children = append(children, c)
+			b.Unmove(mv)
+		}
+	}
+	first[solveSize] = int32(len(children))
+
+	// the results, a position is won if a move leads to a lost position and lost if all
+	// moves lead to won positions
+	for ix := range s.wdl {
+		if mated[ix] {
+			s.wdl[ix] = Loss
+		}
+	}
+	known := func(c child) (WDL, bool) {
+		if c.ix < 0 {
+			return WDL(c.wdl), true
+		}
+		w := s.wdl[c.ix]
+		return w, w != Draw
+	}
+	for changed := true; changed; {
+		changed = false
+		for ix := range s.wdl {
+			if !s.valid[ix] || s.wdl[ix] != Draw || first[ix] == first[ix+1] {
+				continue
+			}
+			lost := true
+			for _, c := range children[first[ix]:first[ix+1]] {
+				w, ok := known(c)
+				if ok && w == Loss {
+					s.wdl[ix], changed = Win, true
+					break
+				}
+				if !ok || w != Win {
+					lost = false
+				}
+			}
+			if lost && s.wdl[ix] == Draw {
+				s.wdl[ix], changed = Loss, true
+			}
+		}
+	}
+
+	// the distances to zeroing in passes of increasing distance. A mate counts as zeroing
+	for ix := range s.dtz {
+		if mated[ix] {
+			s.dtz[ix] = -1
+		}
+	}
+	for n := int16(1); ; n++ {
+		var done []int
+		var dtzs []int16
+		open := 0
+		for ix := range s.dtz {
+			if s.wdl[ix] == Draw || s.dtz[ix] != 0 {
+				continue
+			}
+			open++
+			cs := children[first[ix]:first[ix+1]]
+
+			if s.wdl[ix] == Win {
+				for _, c := range cs {
+					if w, _ := known(c); w != Loss {
+						continue
+					}
+					if c.zeroing || mated[c.ix] || (s.dtz[c.ix] != 0 && -s.dtz[c.ix] < n) {
+						done, dtzs = append(done, ix), append(dtzs, n)
+						break
+					}
+				}
+				continue
+			}
+
+			longest := int16(0)
+			for _, c := range cs {
+				d := int16(1)
+				if !c.zeroing {
+					if s.dtz[c.ix] == 0 || s.dtz[c.ix] >= n {
+						longest = -1
+						break
+					}
+					d = 1 + s.dtz[c.ix]
+				}
+				longest = max(longest, d)
+			}
+			if longest > 0 {
+				done, dtzs = append(done, ix), append(dtzs, -longest)
+			}
+		}
+
+		if open == 0 {
+			break
+		}
+		if len(done) == 0 {
+			panic(fmt.Sprintf("%v positions without a distance to zeroing", open))
+		}
+		for i, ix := range done {
+			s.dtz[ix] = dtzs[i]
+		}
+	}
+
+	solutions[pc] = s
+	return s
+}
@@ -0,0 +1,518 @@
+// Package syzygy probes Syzygy endgame tablebases.
+//
+// The .rtbw files give win/draw/loss for every position and the .rtbz files give the
+// distance to zeroing, the number of plies to the next capture or pawn move on the
+// way to the result. The tables are found with Init and each file is read into memory
+// the first time it is probed.
+package syzygy
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	. "github.com/Tecu23/go-game/pkg/chess/constants"
+	"github.com/Tecu23/go-game/pkg/chess/position"
+)
+
+// WDL is the result of a position from the side to move pov.
+// A cursed win is a win that is a draw with the 50 move rule, a blessed loss the opposite
+type WDL int
+
+const (
+	Loss        WDL = -2
+	BlessedLoss WDL = -1
+	Draw        WDL = 0
+	CursedWin   WDL = 1
+	Win         WDL = 2
+)
+
+const (
+	wdlSuffix = ".rtbw"
+	dtzSuffix = ".rtbz"
+	tbPieces  = 7 // the largest tables there are
+)
+
+var (
+	wdlMagic = []byte{0x71, 0xE8, 0x23, 0x5D}
+	dtzMagic = []byte{0xD7, 0x66, 0x0C, 0xA5}
+)
+
+// flags for each pairsData
+const (
+	flagStm         = 1
+	flagMapped      = 2
+	flagWinPlies    = 4
+	flagLossPlies   = 8
+	flagWide        = 16
+	flagSingleValue = 128
+)
+
+// MaxPieces is the largest number of pieces (kings included) with a WDL table. 0 if there are no tables
+var MaxPieces int
+
+var (
+	wdlTables = make(map[uint64]*table)
+	dtzTables = make(map[uint64]*table)
+)
+
+// Init finds all tables in path. Several directories are separated as in the PATH environment
+// variable. An empty path or "<empty>" removes all tables
+func Init(path string) error {
+	initIndexes()
+
+	wdlTables = make(map[uint64]*table)
+	dtzTables = make(map[uint64]*table)
+	MaxPieces = 0
+
+	path = strings.TrimSpace(path)
+	if path == "" || strings.ToLower(path) == "<empty>" {
+		return nil
+	}
+
+	for _, dir := range filepath.SplitList(path) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return err
+		}
+
+		for _, e := range entries {
+			name := e.Name()
+			switch {
+			case strings.HasSuffix(name, wdlSuffix):
+				addTable(wdlTables, filepath.Join(dir, name), strings.TrimSuffix(name, wdlSuffix), false)
+			case strings.HasSuffix(name, dtzSuffix):
+				addTable(dtzTables, filepath.Join(dir, name), strings.TrimSuffix(name, dtzSuffix), true)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Count returns the number of WDL and DTZ tables found by Init
+func Count() (wdl, dtz int) {
+	// each table is stored under both its keys unless they are the same
+	cnt := func(tables map[uint64]*table) int {
+		seen := make(map[*table]bool)
+		for _, t := range tables {
+			seen[t] = true
+		}
+		return len(seen)
+	}
+	return cnt(wdlTables), cnt(dtzTables)
+}
+
+// addTable registers the table in path if code is a valid material signature like KRPvKR
+func addTable(tables map[uint64]*table, path, code string, dtz bool) {
+	sides := strings.Split(code, "v")
+	if len(sides) != 2 {
+		return
+	}
+
+	var count [NoPiecesC]int
+	for sd, side := range sides {
+		if !strings.HasPrefix(side, "K") {
+			return
+		}
+		for _, c := range side[1:] {
+			pt := strings.IndexRune(PtFen, c)
+			if pt < 0 || pt >= King {
+				return
+			}
+			count[position.Pt2pc(pt, Color(sd))]++
+		}
+	}
+
+	t := newTable(path, &count, dtz)
+	if t.pieceCount > tbPieces {
+		return
+	}
+	tables[t.key] = t
+	tables[t.key2] = t
+
+	if !dtz && t.pieceCount > MaxPieces {
+		MaxPieces = t.pieceCount
+	}
+}
+
+// materialKey packs the number of pawns, knights, bishops, rooks and queens of both sides
+func materialKey(count *[NoPiecesC]int) uint64 {
+	key := uint64(0)
+	for pc := WP; pc < WK; pc++ {
+		key |= uint64(count[pc]) << uint(4*pc)
+	}
+	return key
+}
+
+// pairsData describes the compressed values for one side to move and one leading pawn file
+type pairsData struct {
+	flags           uint8
+	sizeofBlock     uint64
+	span            uint64
+	sparseIndexSize uint64
+	blocksNum       uint64
+	blockLengthSize uint64
+	maxSymLen       int
+	minSymLen       int // the value of all positions if flagSingleValue is set
+	lowestSym       []byte
+	base64          []uint64
+	symlen          []uint8
+	btree           []byte // 3 bytes per symbol, 12 bits left and 12 bits right
+	sparseIndex     []byte // 6 bytes per entry, 4 bytes block and 2 bytes offset
+	blockLength     []byte
+	data            []byte
+
+	pieces   [tbPieces]int        // the piece order the positions are encoded in
+	groupLen [tbPieces + 1]int    // number of pieces in each group, zero terminated
+	groupIdx [tbPieces + 1]uint64 // the factor each group is multiplied with in the index
+	mapIdx   [4]int               // dtz only: start of the value map for each wdl
+}
+
+// table is one .rtbw or .rtbz file. key is the material with the pieces of the
+// file name's first side as white and key2 with them as black
+type table struct {
+	path            string
+	dtz             bool
+	key, key2       uint64
+	pieceCount      int
+	hasPawns        bool
+	hasUniquePieces bool
+	pawnCount       [2]int // the leading color first
+
+	mu     sync.Mutex
+	loaded bool
+	err    error
+	items  [2][4]pairsData // [stm][file]
+	dtzMap []byte          // dtz only
+}
+
+func newTable(path string, count *[NoPiecesC]int, dtz bool) *table {
+	t := &table{path: path, dtz: dtz}
+	t.key = materialKey(count)
+
+	var count2 [NoPiecesC]int
+	for pc := WP; pc < NoPiecesC; pc++ {
+		count2[pc^1] = count[pc]
+	}
+	t.key2 = materialKey(&count2)
+
+	t.pieceCount = 2
+	for pc := WP; pc < WK; pc++ {
+		t.pieceCount += count[pc]
+		if count[pc] == 1 {
+			t.hasUniquePieces = true
+		}
+	}
+	t.hasPawns = count[WP]+count[BP] > 0
+
+	// the leading color is the one with fewer pawns, white if they have the same
+	if count[BP] == 0 || (count[WP] > 0 && count[BP] >= count[WP]) {
+		t.pawnCount = [2]int{count[WP], count[BP]}
+	} else {
+		t.pawnCount = [2]int{count[BP], count[WP]}
+	}
+
+	return t
+}
+
+// sides is the number of sides to move stored in the file
+func (t *table) sides() int {
+	if !t.dtz && t.key != t.key2 {
+		return 2
+	}
+	return 1
+}
+
+func (t *table) get(stm, f int) *pairsData {
+	if !t.hasPawns {
+		f = 0
+	}
+	return &t.items[stm%t.sides()][f]
+}
+
+// load reads the file the first time the table is probed
+func (t *table) load() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.loaded {
+		t.loaded = true
+		t.err = t.read()
+	}
+	return t.err
+}
+
+func (t *table) read() (err error) {
+	data, err := os.ReadFile(t.path)
+	if err != nil {
+		return err
+	}
+
+	magic := wdlMagic
+	if t.dtz {
+		magic = dtzMagic
+	}
+	if len(data) < 5 || string(data[:4]) != string(magic) {
+		return fmt.Errorf("%v is not a syzygy table", t.path)
+	}
+
+	// a short or broken file makes the parsing index out of range
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v is corrupt: %v", t.path, r)
+		}
+	}()
+
+	t.set(data, 4)
+	return nil
+}
+
+// set parses the header and the index data of the file. p is the offset after the magic bytes
+func (t *table) set(data []byte, p int) {
+	const hasPawns = 2
+	if (data[p]&hasPawns != 0) != t.hasPawns {
+		panic("pawn flag doesn't match the file name")
+	}
+	p++
+
+	sides := t.sides()
+	maxFile := 0
+	if t.hasPawns {
+		maxFile = 3
+	}
+	pp := t.hasPawns && t.pawnCount[1] > 0 // pawns on both sides
+
+	for f := 0; f <= maxFile; f++ {
+		order := [2][2]int{{int(data[p] & 0xF), 0xF}, {int(data[p] >> 4), 0xF}}
+		if pp {
+			order[0][1], order[1][1] = int(data[p+1]&0xF), int(data[p+1]>>4)
+			p++
+		}
+		p++
+
+		for k := 0; k < t.pieceCount; k++ {
+			t.items[0][f].pieces[k] = int(data[p] & 0xF)
+			t.items[1][f].pieces[k] = int(data[p] >> 4)
+			p++
+		}
+
+		for i := 0; i < sides; i++ {
+			t.setGroups(&t.items[i][f], order[i], f)
+		}
+	}
+	p += p & 1
+
+	for f := 0; f <= maxFile; f++ {
+		for i := 0; i < sides; i++ {
+			p = t.items[i][f].setSizes(data, p)
+		}
+	}
+
+	if t.dtz {
+		p = t.setDTZMap(data, p, maxFile)
+	}
+
+	for f := 0; f <= maxFile; f++ {
+		for i := 0; i < sides; i++ {
+			d := &t.items[i][f]
+			d.sparseIndex = data[p : p+int(d.sparseIndexSize)*6]
+			p += int(d.sparseIndexSize) * 6
+		}
+	}
+
+	for f := 0; f <= maxFile; f++ {
+		for i := 0; i < sides; i++ {
+			d := &t.items[i][f]
+			d.blockLength = data[p : p+int(d.blockLengthSize)*2]
+			p += int(d.blockLengthSize) * 2
+		}
+	}
+
+	for f := 0; f <= maxFile; f++ {
+		for i := 0; i < sides; i++ {
+			d := &t.items[i][f]
+			p = (p + 0x3F) &^ 0x3F // 64 byte alignment
+			d.data = data[p:]
+			p += int(d.blocksNum * d.sizeofBlock)
+		}
+	}
+}
+
+// setGroups splits the pieces in groups of equal pieces, the first group is the leading
+// pawns or the kings and maybe one more unique piece. order tells in which order the
+// groups are multiplied together in the index
+func (t *table) setGroups(d *pairsData, order [2]int, f int) {
+	n := 0
+	firstLen := 2
+	if t.hasPawns {
+		firstLen = 0
+	} else if t.hasUniquePieces {
+		firstLen = 3
+	}
+
+	d.groupLen[n] = 1
+	for i := 1; i < t.pieceCount; i++ {
+		firstLen--
+		if firstLen > 0 || d.pieces[i] == d.pieces[i-1] {
+			d.groupLen[n]++
+		} else {
+			n++
+			d.groupLen[n] = 1
+		}
+	}
+	n++
+	d.groupLen[n] = 0
+
+	pp := t.hasPawns && t.pawnCount[1] > 0
+	next := 1
+	freeSquares := 64 - d.groupLen[0]
+	if pp {
+		next = 2
+		freeSquares -= d.groupLen[1]
+	}
+
+	idx := uint64(1)
+	for k := 0; next < n || k == order[0] || k == order[1]; k++ {
+		switch {
+		case k == order[0]: // leading pawns or pieces
+			d.groupIdx[0] = idx
+			switch {
+			case t.hasPawns:
+				idx *= uint64(leadPawnsSize[d.groupLen[0]][f])
+			case t.hasUniquePieces:
+				idx *= 31332
+			default:
+				idx *= 462
+			}
+		case k == order[1]: // remaining pawns
+			d.groupIdx[1] = idx
+			idx *= binomial[d.groupLen[1]][48-d.groupLen[0]]
+		default: // remaining pieces
+			d.groupIdx[next] = idx
+			idx *= binomial[d.groupLen[next]][freeSquares]
+			freeSquares -= d.groupLen[next]
+			next++
+		}
+	}
+	d.groupIdx[n] = idx
+}
+
+// setSizes reads the block sizes and the huffman code. It returns the offset after it
+func (d *pairsData) setSizes(data []byte, p int) int {
+	d.flags = data[p]
+	p++
+
+	if d.flags&flagSingleValue != 0 {
+		d.minSymLen = int(data[p])
+		return p + 1
+	}
+
+	n := 0
+	for d.groupLen[n] != 0 {
+		n++
+	}
+	tbSize := d.groupIdx[n]
+
+	d.sizeofBlock = 1 << data[p]
+	d.span = 1 << data[p+1]
+	d.sparseIndexSize = (tbSize + d.span - 1) / d.span
+	padding := uint64(data[p+2])
+	d.blocksNum = uint64(binary.LittleEndian.Uint32(data[p+3:]))
+	d.blockLengthSize = d.blocksNum + padding
+	d.maxSymLen = int(data[p+7])
+	d.minSymLen = int(data[p+8])
+	p += 9
+
+	// canonical huffman code: longer symbols have lower values. base64[l] is the
+	// lowest code of length l+minSymLen, left aligned in 64 bits
+	lens := d.maxSymLen - d.minSymLen + 1
+	d.lowestSym = data[p : p+2*lens]
+	d.base64 = make([]uint64, lens)
+	for i := lens - 2; i >= 0; i-- {
+		d.base64[i] = (d.base64[i+1] + uint64(d.lowest(i)) - uint64(d.lowest(i+1))) / 2
+	}
+	for i := range d.base64 {
+		d.base64[i] <<= uint(64 - i - d.minSymLen)
+	}
+	p += 2 * lens
+
+	// each symbol is a value or a pair of symbols (recursive pairing)
+	syms := int(binary.LittleEndian.Uint16(data[p:]))
+	p += 2
+	d.btree = data[p : p+3*syms]
+	d.symlen = make([]uint8, syms)
+	visited := make([]bool, syms)
+	for s := 0; s < syms; s++ {
+		if !visited[s] {
+			d.symlen[s] = d.setSymlen(s, visited)
+		}
+	}
+
+	return p + 3*syms + syms&1
+}
+
+// setSymlen computes how many values minus one the symbol s expands to
+func (d *pairsData) setSymlen(s int, visited []bool) uint8 {
+	visited[s] = true
+	sr := d.right(s)
+	if sr == 0xFFF {
+		return 0
+	}
+
+	sl := d.left(s)
+	if !visited[sl] {
+		d.symlen[sl] = d.setSymlen(sl, visited)
+	}
+	if !visited[sr] {
+		d.symlen[sr] = d.setSymlen(sr, visited)
+	}
+	return d.symlen[sl] + d.symlen[sr] + 1
+}
+
+func (d *pairsData) lowest(l int) int {
+	return int(binary.LittleEndian.Uint16(d.lowestSym[2*l:]))
+}
+
+func (d *pairsData) left(s int) int {
+	return int(d.btree[3*s+1]&0xF)<<8 | int(d.btree[3*s])
+}
+
+func (d *pairsData) right(s int) int {
+	return int(d.btree[3*s+2])<<4 | int(d.btree[3*s+1]>>4)
+}
+
+func (d *pairsData) blockLen(block int) int {
+	return int(binary.LittleEndian.Uint16(d.blockLength[2*block:]))
+}
+
+// setDTZMap reads the maps from stored values to dtz values, one map per wdl result
+func (t *table) setDTZMap(data []byte, p, maxFile int) int {
+	t.dtzMap = data[p:]
+	start := p
+
+	for f := 0; f <= maxFile; f++ {
+		d := &t.items[0][f]
+		if d.flags&flagMapped == 0 {
+			continue
+		}
+
+		if d.flags&flagWide != 0 {
+			p += p & 1
+			for i := 0; i < 4; i++ {
+				d.mapIdx[i] = (p-start)/2 + 1
+				p += 2*int(binary.LittleEndian.Uint16(data[p:])) + 2
+			}
+		} else {
+			for i := 0; i < 4; i++ {
+				d.mapIdx[i] = p - start + 1
+				p += int(data[p]) + 1
+			}
+		}
+	}
+
+	return p + p&1
+}
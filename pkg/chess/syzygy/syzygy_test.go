@@ -0,0 +1,277 @@
+package syzygy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Tecu23/go-game/pkg/chess/castlings"
+	. "github.com/Tecu23/go-game/pkg/chess/constants"
+	"github.com/Tecu23/go-game/pkg/chess/magic"
+	"github.com/Tecu23/go-game/pkg/chess/moves"
+	"github.com/Tecu23/go-game/pkg/chess/position"
+)
+
+const tablesDir = "testdata"
+
+func TestMain(m *testing.M) {
+	InitFen2Sq()
+	magic.InitMagic()
+	position.InitKeys()
+	position.InitAtksKings()
+	position.InitAtksKnights()
+	castlings.InitCastlings()
+	position.PcSqInit()
+	position.InitEndgames()
+
+	os.Exit(m.Run())
+}
+
+func TestIndexes(t *testing.T) {
+	initIndexes()
+
+	mx := 0
+	for idx := range mapKK {
+		for _, code := range mapKK[idx] {
+			if code > mx {
+				mx = code
+			}
+		}
+	}
+	if mx != 461 {
+		t.Errorf("the highest king pair code is %v, want 461", mx)
+	}
+
+	for f := 0; f < 4; f++ {
+		if leadPawnsSize[1][f] != 6 {
+			t.Errorf("one leading pawn on file %v has %v squares, want 6", f, leadPawnsSize[1][f])
+		}
+	}
+	if mapPawns[A2] != 47 || mapPawns[H2] != 46 || mapPawns[E7] != 0 {
+		t.Errorf("wrong pawn map a2=%v h2=%v e7=%v", mapPawns[A2], mapPawns[H2], mapPawns[E7])
+	}
+}
+
+func TestInit(t *testing.T) {
+	if err := Init(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	if MaxPieces != 0 {
+		t.Errorf("an empty directory gives MaxPieces %v", MaxPieces)
+	}
+
+	if err := Init(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("a missing directory should give an error")
+	}
+}
+
+// initTables uses the 3 piece tables in testdata, see testdata/README.md
+func initTables(t *testing.T) {
+	if err := Init(tablesDir); err != nil {
+		t.Fatal(err)
+	}
+	if wdl, dtz := Count(); wdl != 5 || dtz != 5 {
+		t.Fatalf("found %v WDL and %v DTZ tables in %v, want 5 and 5", wdl, dtz, tablesDir)
+	}
+	t.Cleanup(func() { Init("") })
+}
+
+func TestProbeWDL(t *testing.T) {
+	initTables(t)
+
+	tests := []struct {
+		fen string
+		wdl WDL
+	}{
+		{"7k/8/8/8/8/8/8/KQ6 w - - 0 1", Win},
+		{"8/8/8/8/8/8/8/KQ5k b - - 0 1", Loss},
+		{"8/8/8/8/8/8/2k5/KN6 w - - 0 1", Draw},
+		{"8/8/8/8/8/8/8/KB5k b - - 0 1", Draw},
+		{"8/8/8/8/8/8/1k6/1Q5K b - - 0 1", Draw}, // the queen is taken
+		{"k7/8/8/8/8/8/8/KR6 b - - 0 1", Loss},
+		{"8/8/8/8/8/8/8/kr5K w - - 0 1", Loss},
+		{"4k3/8/4K3/4P3/8/8/8/8 w - - 0 1", Win},
+		{"8/4k3/8/8/8/8/4P3/4K3 w - - 0 1", Draw},
+		{"k7/8/K7/P7/8/8/8/8 b - - 0 1", Draw},
+	}
+
+	for _, tt := range tests {
+		b, err := position.ParseFEN(tt.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wdl, ok := ProbeWDL(b)
+		if !ok {
+			t.Errorf("%v: probe failed", tt.fen)
+			continue
+		}
+		if wdl != tt.wdl {
+			t.Errorf("%v: got %v, want %v", tt.fen, wdl, tt.wdl)
+		}
+	}
+}
+
+// TestKPK compares the tables with the bitbase from the position package for all KPK positions
+func TestKPK(t *testing.T) {
+	initTables(t)
+
+	b := &position.Board
+	for psq := A2; psq <= H7; psq++ {
+		for wk := A1; wk <= H8; wk++ {
+			for bk := A1; bk <= H8; bk++ {
+				if wk == psq || bk == psq || position.SqDistance(wk, bk) <= 1 {
+					continue
+				}
+
+				b.Clear()
+				b.SetSq(WK, wk)
+				b.SetSq(BK, bk)
+				b.SetSq(WP, psq)
+				if b.IsAttacked(bk, WHITE) {
+					continue // white to move and black in check
+				}
+
+				wdl, ok := ProbeWDL(b)
+				if !ok {
+					t.Fatalf("probe failed for wk=%v bk=%v p=%v", wk, bk, psq)
+				}
+				if win := position.Evaluate(b) > 0; win != (wdl == Win) {
+					t.Fatalf("wk=%v bk=%v p=%v: tables say %v, the bitbase win=%v", wk, bk, psq, wdl, win)
+				}
+			}
+		}
+	}
+}
+
+func TestProbeDTZ(t *testing.T) {
+	initTables(t)
+
+	tests := []struct {
+		fen string
+		dtz int
+	}{
+		{"k7/8/1K6/8/8/8/8/6Q1 w - - 0 1", 1},  // mate in one
+		{"k7/8/1K6/8/8/8/8/7R w - - 0 1", 1},   // mate in one
+		{"k7/8/1Q6/8/8/8/8/2K5 b - - 0 1", 0},  // stalemate
+		{"k7/1Q6/1K6/8/8/8/8/8 b - - 0 1", -1}, // mated
+		{"k7/8/1K6/8/8/8/8/7R b - - 0 1", -2},  // Kb8 Rh8
+		{"4k3/8/4K3/4P3/8/8/8/8 w - - 0 1", 3}, // Kd6 Kd8 e6
+		{"8/4P3/8/8/8/8/8/K1k5 w - - 0 1", 1},  // e8=Q
+		{"8/4k3/8/8/8/8/4P3/4K3 w - - 0 1", 0},
+	}
+
+	for _, tt := range tests {
+		b, err := position.ParseFEN(tt.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dtz, ok := ProbeDTZ(b)
+		if !ok {
+			t.Errorf("%v: probe failed", tt.fen)
+			continue
+		}
+		if dtz != tt.dtz {
+			t.Errorf("%v: got dtz %v, want %v", tt.fen, dtz, tt.dtz)
+		}
+	}
+}
+
+func TestRootProbe(t *testing.T) {
+	initTables(t)
+
+	const fen = "k7/8/2K5/8/8/8/1R6/8 w - - 0 1" // Kc7 Ka7 Ra2 mates, dtz 3
+	tests := []struct {
+		rule50 int
+		want   int // moves kept
+	}{
+		{0, 1},
+		{97, 1},  // the mate comes at ply 100
+		{99, 21}, // a draw by the 50 move rule, all moves are kept
+	}
+
+	for _, tt := range tests {
+		b, err := position.ParseFEN(fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b.Rule50 = tt.rule50
+
+		var ml moves.MoveList
+		b.GenAllLegals(&ml)
+		if !RootProbe(b, &ml) {
+			t.Fatalf("%v: probe failed", fen)
+		}
+		if len(ml) != tt.want {
+			t.Errorf("%v with Rule50 %v: kept %v, want %v moves", fen, tt.rule50, ml.String(), tt.want)
+		}
+		if tt.want == 1 && len(ml) == 1 && ml[0].String() != "c6c7" {
+			t.Errorf("%v with Rule50 %v: kept %v, want c6c7", fen, tt.rule50, ml.String())
+		}
+	}
+}
+
+// TestLongestDTZ checks the tables against the published longest wins, KQvK mates in 10
+// moves and KRvK in 16
+func TestLongestDTZ(t *testing.T) {
+	if testing.Short() {
+		t.Skip("probing every position takes a few seconds")
+	}
+	initTables(t)
+
+	var b position.BoardStruct
+	for _, tt := range []struct {
+		name string
+		pc   int
+		want int
+	}{{"KQvK", WQ, 19}, {"KRvK", WR, 31}} {
+		s := &solution{pc: tt.pc}
+		longest := 0
+		for ix := 0; ix < solveSize; ix++ {
+			if !s.setup(&b, ix) {
+				continue
+			}
+			if dtz, ok := ProbeDTZ(&b); ok && dtz > longest {
+				longest = dtz
+			}
+		}
+		if longest != tt.want {
+			t.Errorf("the longest dtz of %v is %v, want %v", tt.name, longest, tt.want)
+		}
+	}
+}
+
+// TestSolved compares the tables with the solver for every position of the 3 piece endings,
+// with the piece for white and for black
+func TestSolved(t *testing.T) {
+	if testing.Short() {
+		t.Skip("solving the endings takes a few seconds")
+	}
+	initTables(t)
+
+	var b, flipped position.BoardStruct
+	for _, pc := range []int{WQ, WR, WB, WN, WP} {
+		s := solve(pc)
+		for ix := 0; ix < solveSize; ix++ {
+			if !s.setup(&b, ix) {
+				continue
+			}
+
+			flipped.Clear()
+			flipped.SetSq(BK, b.King[WHITE]^56)
+			flipped.SetSq(WK, b.King[BLACK]^56)
+			flipped.SetSq(pc^1, ix%64^56)
+			flipped.Stm = b.Stm.Opposite()
+
+			for _, b := range []*position.BoardStruct{&b, &flipped} {
+				wdl, ok := ProbeWDL(b)
+				if !ok || wdl != s.wdl[ix] {
+					t.Fatalf("%v: got %v (ok %v), want %v", b.FEN(), wdl, ok, s.wdl[ix])
+				}
+				dtz, ok := ProbeDTZ(b)
+				if !ok || dtz != int(s.dtz[ix]) {
+					t.Fatalf("%v: got dtz %v (ok %v), want %v", b.FEN(), dtz, ok, s.dtz[ix])
+				}
+			}
+		}
+	}
+}
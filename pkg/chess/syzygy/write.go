@@ -0,0 +1,490 @@
+package syzygy
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	. "github.com/Tecu23/go-game/pkg/chess/constants"
+	"github.com/Tecu23/go-game/pkg/chess/position"
+)
+
+// the layout of the tables written by Generate
+const (
+	blockLog     = 6  // 64 bytes per block
+	spanLog      = 10 // a sparse index entry for every 1024 values
+	maxBlockVals = 1 << 15
+	maxSymLen    = 32 // the decoder keeps at least 32 bits in its buffer
+	maxSyms      = 0xFFF
+	maxPairLen   = 256 // symlen is a byte
+)
+
+// Generate solves the ending code, like KQvK, and writes its WDL and DTZ tables to dir.
+// Only endings with one piece besides the kings can be generated. The tables are in the
+// Syzygy format, but compressed less than the published ones
+func Generate(dir, code string) error {
+	initIndexes()
+
+	pc := -1
+	if len(code) == 4 && strings.HasPrefix(code, "K") && strings.HasSuffix(code, "vK") {
+		if pt := strings.IndexByte(PtFen, code[1]); pt >= 0 && pt < King {
+			pc = position.Pt2pc(pt, WHITE)
+		}
+	}
+	if pc < 0 {
+		return fmt.Errorf("can't generate %v, only endings like KQvK", code)
+	}
+
+	s := solve(pc)
+	for _, dtz := range []bool{false, true} {
+		suffix := wdlSuffix
+		if dtz {
+			suffix = dtzSuffix
+		}
+		path := filepath.Join(dir, code+suffix)
+
+		var count [NoPiecesC]int
+		count[pc] = 1
+		t := newTable(path, &count, dtz)
+		data, err := t.encode(s)
+		if err != nil {
+			return fmt.Errorf("%v: %v", path, err)
+		}
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// noValue marks the indexes no position is stored under, they get the value before them
+const noValue = -1
+
+// encode writes the table of the solved ending s. The WDL table has both sides to move
+// and the DTZ table white to move, the side with the piece
+func (t *table) encode(s *solution) ([]byte, error) {
+	maxFile := 0
+	if t.hasPawns {
+		maxFile = 3
+	}
+
+	// the pieces in the order of the index, the leading pawn or the white piece first
+	order := []int{tbPiece(s.pc), tbPiece(WK), tbPiece(BK)}
+	var vals [2][4][]int
+	for f := 0; f <= maxFile; f++ {
+		for i := 0; i < 2; i++ {
+			d := &t.items[i][f]
+			copy(d.pieces[:], order)
+			t.setGroups(d, [2]int{0, 0xF}, f)
+
+			n := 0
+			for d.groupLen[n] != 0 {
+				n++
+			}
+			vals[i][f] = make([]int, d.groupIdx[n])
+			for ix := range vals[i][f] {
+				vals[i][f][ix] = noValue
+			}
+		}
+	}
+
+	var b position.BoardStruct
+	for ix := 0; ix < solveSize; ix++ {
+		if !s.valid[ix] {
+			continue
+		}
+		v := int(s.wdl[ix]) + 2
+		if t.dtz {
+			if s.wdl[ix] == Draw || Color(ix/(64*64*64)) != WHITE {
+				continue
+			}
+			v = int(max(s.dtz[ix], -s.dtz[ix])) - 1 // in plies, see flagWinPlies
+		}
+
+		s.setup(&b, ix)
+		stm, f, idx := t.index(&b)
+		if old := vals[stm][f][idx]; old != noValue && old != v {
+			return nil, fmt.Errorf("%v has the value %v and %v at index %v", b.FEN(), old, v, idx)
+		}
+		vals[stm][f][idx] = v
+	}
+
+	var enc [2][4]*pairsEncoding
+	for f := 0; f <= maxFile; f++ {
+		for i := 0; i < t.sides(); i++ {
+			flags := uint8(0)
+			if t.dtz {
+				flags = flagWinPlies | flagLossPlies // white to move
+			}
+			enc[i][f] = encodePairs(vals[i][f], flags)
+		}
+	}
+
+	return t.write(enc, maxFile), nil
+}
+
+// write lays the table out in the order table.set reads it
+func (t *table) write(enc [2][4]*pairsEncoding, maxFile int) []byte {
+	data := wdlMagic
+	if t.dtz {
+		data = dtzMagic
+	}
+	data = append([]byte(nil), data...)
+
+	flags := byte(0)
+	if t.sides() == 2 {
+		flags |= 1
+	}
+	if t.hasPawns {
+		flags |= 2
+	}
+	data = append(data, flags)
+
+	sides := t.sides()
+	for f := 0; f <= maxFile; f++ {
+		data = append(data, 0) // the leading group first for both sides to move
+		for k := 0; k < t.pieceCount; k++ {
+			data = append(data, byte(t.items[0][f].pieces[k]|t.items[sides-1][f].pieces[k]<<4))
+		}
+	}
+	data = pad(data, 2)
+
+	for f := 0; f <= maxFile; f++ {
+		for i := 0; i < sides; i++ {
+			data = append(data, enc[i][f].header()...)
+		}
+	}
+	if t.dtz {
+		data = pad(data, 2) // no value maps
+	}
+
+	for f := 0; f <= maxFile; f++ {
+		for i := 0; i < sides; i++ {
+			for _, e := range enc[i][f].sparse {
+				data = binary.LittleEndian.AppendUint32(data, e.block)
+				data = binary.LittleEndian.AppendUint16(data, e.offset)
+			}
+		}
+	}
+	for f := 0; f <= maxFile; f++ {
+		for i := 0; i < sides; i++ {
+			for _, l := range enc[i][f].blockLens {
+				data = binary.LittleEndian.AppendUint16(data, l)
+			}
+		}
+	}
+	for f := 0; f <= maxFile; f++ {
+		for i := 0; i < sides; i++ {
+			data = pad(data, 64)
+			data = append(data, enc[i][f].blocks...)
+		}
+	}
+
+	// the published tables end with a 16 byte checksum. The decoder can read a few bytes
+	// past the last block into it
+	return append(data, make([]byte, 16)...)
+}
+
+// pad appends zeros up to a multiple of n
+func pad(data []byte, n int) []byte {
+	for len(data)%n != 0 {
+		data = append(data, 0)
+	}
+	return data
+}
+
+type sparseEntry struct {
+	block  uint32
+	offset uint16
+}
+
+// pairsEncoding is one compressed sequence of values, see pairsData for the fields
+type pairsEncoding struct {
+	flags     uint8
+	single    int
+	lens      []int // the code length of each symbol, 0 for the symbols only used in pairs
+	minLen    int
+	maxLen    int
+	lowest    []uint16
+	btree     [][3]byte
+	blocks    []byte
+	blockLens []uint16
+	sparse    []sparseEntry
+}
+
+// header is the part of the encoding that table.setSizes reads
+func (e *pairsEncoding) header() []byte {
+	if e.flags&flagSingleValue != 0 {
+		return []byte{e.flags, byte(e.single)}
+	}
+
+	h := []byte{e.flags, blockLog, spanLog, 0}
+	h = binary.LittleEndian.AppendUint32(h, uint32(len(e.blockLens)))
+	h = append(h, byte(e.maxLen), byte(e.minLen))
+	for _, l := range e.lowest {
+		h = binary.LittleEndian.AppendUint16(h, l)
+	}
+	h = binary.LittleEndian.AppendUint16(h, uint16(len(e.btree)))
+	for _, lr := range e.btree {
+		h = append(h, lr[:]...)
+	}
+	if len(e.btree)%2 == 1 {
+		h = append(h, 0)
+	}
+	return h
+}
+
+// symbol is a value, or a pair of symbols that stands for the values of both
+type symbol struct {
+	left, right int // right is -1 for a value, left is the value then
+	n           int // number of values
+}
+
+// encodePairs compresses vals: runs of values are replaced by pairs of symbols, the most
+// frequent adjacent pair first, and the symbols are written with a canonical huffman code
+func encodePairs(vals []int, flags uint8) *pairsEncoding {
+	// the positions that are not stored get the value before them
+	last := noValue
+	for _, v := range vals {
+		if v != noValue {
+			last = v
+			break
+		}
+	}
+	if last == noValue {
+		last = 0
+	}
+	seqVals := make([]int, len(vals))
+	for ix, v := range vals {
+		if v != noValue {
+			last = v
+		}
+		seqVals[ix] = last
+	}
+
+	e := &pairsEncoding{flags: flags}
+	single := true
+	for _, v := range seqVals {
+		single = single && v == seqVals[0]
+	}
+	if single {
+		e.flags |= flagSingleValue
+		e.single = seqVals[0]
+		return e
+	}
+
+	var syms []symbol
+	symOf := make(map[int]int)
+	seq := make([]int, len(seqVals))
+	for ix, v := range seqVals {
+		s, ok := symOf[v]
+		if !ok {
+			s = len(syms)
+			symOf[v] = s
+			syms = append(syms, symbol{left: v, right: -1, n: 1})
+		}
+		seq[ix] = s
+	}
+
+	// replace the most frequent pair until no pair is worth a new symbol
+	for len(syms) < maxSyms {
+		counts := make(map[[2]int]int)
+		for ix := 1; ix < len(seq); ix++ {
+			counts[[2]int{seq[ix-1], seq[ix]}]++
+		}
+		best, bestCnt := [2]int{}, 3
+		for p, cnt := range counts {
+			if syms[p[0]].n+syms[p[1]].n > maxPairLen {
+				continue
+			}
+			if cnt > bestCnt || (cnt == bestCnt && (p[0] < best[0] || (p[0] == best[0] && p[1] < best[1]))) {
+				best, bestCnt = p, cnt
+			}
+		}
+		if bestCnt == 3 {
+			break
+		}
+
+		s := len(syms)
+		syms = append(syms, symbol{left: best[0], right: best[1], n: syms[best[0]].n + syms[best[1]].n})
+		out := seq[:0]
+		for ix := 0; ix < len(seq); ix++ {
+			if ix+1 < len(seq) && seq[ix] == best[0] && seq[ix+1] == best[1] {
+				out = append(out, s)
+				ix++
+				continue
+			}
+			out = append(out, seq[ix])
+		}
+		seq = out
+	}
+
+	freq := make([]int, len(syms))
+	for _, s := range seq {
+		freq[s]++
+	}
+	e.lens = codeLengths(freq)
+
+	// the symbols with the longest codes get the lowest numbers, the symbols without a
+	// code the highest
+	ids := make([]int, len(syms))
+	for s := range ids {
+		ids[s] = s
+	}
+	sort.SliceStable(ids, func(i, j int) bool {
+		li, lj := e.lens[ids[i]], e.lens[ids[j]]
+		if li == 0 || lj == 0 {
+			return lj == 0 && li != 0
+		}
+		return li > lj
+	})
+	renum := make([]int, len(syms))
+	for id, s := range ids {
+		renum[s] = id
+	}
+
+	e.minLen, e.maxLen = maxSymLen, 0
+	for _, l := range e.lens {
+		if l > 0 {
+			e.minLen, e.maxLen = min(e.minLen, l), max(e.maxLen, l)
+		}
+	}
+	e.btree = make([][3]byte, len(syms))
+	lens := make([]int, len(syms))
+	for s, sym := range syms {
+		left, right := sym.left, 0xFFF
+		if sym.right >= 0 {
+			left, right = renum[sym.left], renum[sym.right]
+		}
+		e.btree[renum[s]] = [3]byte{byte(left), byte(left>>8) | byte(right<<4), byte(right >> 4)}
+		lens[renum[s]] = e.lens[s]
+	}
+	e.lens = lens
+
+	// canonical code: lowest[l] is the first symbol with a code of length l+minLen
+	cnt := make([]int, e.maxLen+2)
+	for _, l := range e.lens {
+		cnt[l]++
+	}
+	e.lowest = make([]uint16, e.maxLen-e.minLen+1)
+	base := make([]uint64, e.maxLen+2)
+	first := 0
+	for l := e.maxLen; l >= e.minLen; l-- {
+		e.lowest[l-e.minLen] = uint16(first)
+		first += cnt[l]
+		if l < e.maxLen {
+			base[l] = (base[l+1] + uint64(cnt[l+1])) / 2
+		}
+	}
+	code := func(id int) (uint64, int) {
+		l := e.lens[id]
+		return base[l] + uint64(id-int(e.lowest[l-e.minLen])), l
+	}
+
+	// the blocks, each starts with a new symbol
+	var w bitWriter
+	vals0 := 0
+	for ix := 0; ix < len(seq); ix++ {
+		id := renum[seq[ix]]
+		c, l := code(id)
+		n := syms[seq[ix]].n
+		if w.bits+l > 8<<blockLog || vals0+n > maxBlockVals {
+			e.blocks = append(e.blocks, w.block()...)
+			e.blockLens = append(e.blockLens, uint16(vals0-1))
+			w, vals0 = bitWriter{}, 0
+		}
+		w.write(c, l)
+		vals0 += n
+	}
+	e.blocks = append(e.blocks, w.block()...)
+	e.blockLens = append(e.blockLens, uint16(vals0-1))
+
+	// the sparse index points to the value in the middle of each span
+	span := 1 << spanLog
+	block, start := 0, 0
+	for k := 0; k*span < len(vals); k++ {
+		idx := k*span + span/2
+		for block+1 < len(e.blockLens) && idx >= start+int(e.blockLens[block])+1 {
+			start += int(e.blockLens[block]) + 1
+			block++
+		}
+		e.sparse = append(e.sparse, sparseEntry{uint32(block), uint16(idx - start)})
+	}
+	return e
+}
+
+// codeLengths returns the huffman code length of each symbol, at most maxSymLen bits
+func codeLengths(freq []int) []int {
+	for {
+		type node struct{ w, sym, left, right int }
+		var nodes, queue []node
+		for s, f := range freq {
+			if f > 0 {
+				queue = append(queue, node{f, s, -1, -1})
+			}
+		}
+		lens := make([]int, len(freq))
+		if len(queue) == 1 {
+			lens[queue[0].sym] = 1
+			return lens
+		}
+
+		// merge the two lightest nodes until one is left
+		for len(queue) > 1 {
+			sort.SliceStable(queue, func(i, j int) bool { return queue[i].w < queue[j].w })
+			a, b := queue[0], queue[1]
+			nodes = append(nodes, a, b)
+			queue = append(queue[2:], node{a.w + b.w, -1, len(nodes) - 2, len(nodes) - 1})
+		}
+
+		var depth func(n node, d int)
+		depth = func(n node, d int) {
+			if n.sym >= 0 {
+				lens[n.sym] = d
+				return
+			}
+			depth(nodes[n.left], d+1)
+			depth(nodes[n.right], d+1)
+		}
+		depth(queue[0], 0)
+
+		longest := 0
+		for _, l := range lens {
+			longest = max(longest, l)
+		}
+		if longest <= maxSymLen {
+			return lens
+		}
+		for s := range freq {
+			if freq[s] > 0 {
+				freq[s] = (freq[s] + 1) / 2
+			}
+		}
+	}
+}
+
+// bitWriter packs codes into a block, the first bit in the highest bit of the first byte
+type bitWriter struct {
+	buf  []byte
+	bits int
+}
+
+func (w *bitWriter) write(c uint64, l int) {
+	for i := l - 1; i >= 0; i-- {
+		if w.bits%8 == 0 {
+			w.buf = append(w.buf, 0)
+		}
+		if c>>uint(i)&1 != 0 {
+			w.buf[w.bits/8] |= 0x80 >> uint(w.bits%8)
+		}
+		w.bits++
+	}
+}
+
+// block returns the codes padded to a full block
+func (w *bitWriter) block() []byte {
+	return append(w.buf, make([]byte, 1<<blockLog-len(w.buf))...)
+}
@@ -0,0 +1,84 @@
+package websocket
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	. "github.com/Tecu23/go-game/pkg/chess/constants"
+	"github.com/Tecu23/go-game/pkg/chess/position"
+	"github.com/Tecu23/go-game/pkg/chess/render"
+)
+
+// boardHandler serves /board.svg?fen=..., the position as an SVG image. The other parameters
+// are optional: variant (a UCI_Variant name), lastmove (e2e4 or N@f3), arrows (e2e4,g1f3),
+// flip, size in pixels and check=false to not highlight a king in check
+func (srv *Server) boardHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	fen := q.Get("fen")
+	if fen == "" {
+		fen = Startpos
+	}
+	v := position.Standard
+	if name := q.Get("variant"); name != "" {
+		var ok bool
+		if v, ok = position.Variants[strings.ToLower(name)]; !ok {
+			http.Error(w, "unknown variant "+name, http.StatusBadRequest)
+			return
+		}
+	}
+	b, err := position.ParseFENVariant(fen, v, true) // any castling rights, an image has no UCI_Chess960
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	opt := render.Options{Check: q.Get("check") != "false" && q.Get("check") != "0"}
+	opt.Flip, _ = strconv.ParseBool(q.Get("flip"))
+	if s := q.Get("size"); s != "" {
+		if opt.Size, err = strconv.Atoi(s); err != nil || opt.Size < 16 || opt.Size > 2048 {
+			http.Error(w, "size must be 16 to 2048 pixels", http.StatusBadRequest)
+			return
+		}
+	}
+	if s := q.Get("lastmove"); s != "" {
+		fr, to, ok := moveSquares(s)
+		if !ok {
+			http.Error(w, "invalid lastmove "+s, http.StatusBadRequest)
+			return
+		}
+		opt.LastMove.PackMove(fr, to, b.Squares[to], Empty, Empty, 0, 0)
+	}
+	for _, s := range strings.FieldsFunc(q.Get("arrows"), func(c rune) bool { return c == ',' || c == ' ' }) {
+		fr, to, ok := moveSquares(s)
+		if !ok || fr == to {
+			http.Error(w, "invalid arrow "+s, http.StatusBadRequest)
+			return
+		}
+		opt.Arrows = append(opt.Arrows, render.Arrow{Fr: fr, To: to})
+	}
+
+	w.Header().Set("Content-Type", "image/svg+xml")
+	if err := render.WriteSVG(w, b, &opt); err != nil {
+		log.Error("board.svg: ", err)
+	}
+}
+
+// moveSquares returns the from and to squares of a move in UCI like e2e4, e7e8q or N@f3,
+// where a drop has the same from and to square
+func moveSquares(s string) (fr, to int, ok bool) {
+	switch {
+	case len(s) == 4 && s[1] == '@':
+		to, ok = Fen2Sq[s[2:]]
+		return to, to, ok
+	case len(s) == 4 || len(s) == 5:
+		var ok2 bool
+		fr, ok = Fen2Sq[s[:2]]
+		to, ok2 = Fen2Sq[s[2:4]]
+		return fr, to, ok && ok2
+	}
+	return 0, 0, false
+}
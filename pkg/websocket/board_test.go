@@ -0,0 +1,67 @@
+package websocket
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/Tecu23/go-game/pkg/chess/castlings"
+	. "github.com/Tecu23/go-game/pkg/chess/constants"
+	"github.com/Tecu23/go-game/pkg/chess/magic"
+	"github.com/Tecu23/go-game/pkg/chess/position"
+)
+
+func TestMain(m *testing.M) {
+	InitFen2Sq()
+	magic.InitMagic()
+	position.InitKeys()
+	position.InitAtksKings()
+	position.InitAtksKnights()
+	castlings.InitCastlings()
+	position.PcSqInit()
+	position.InitEndgames()
+
+	os.Exit(m.Run())
+}
+
+func TestBoardHandler(t *testing.T) {
+	tests := []struct {
+		query  url.Values
+		status int
+	}{
+		{url.Values{}, http.StatusOK},
+		{url.Values{"fen": {Startpos}, "lastmove": {"e2e4"}, "arrows": {"g1f3,b1c3"}, "flip": {"true"}}, http.StatusOK},
+		// KQkq for rooks that didn't start in the corners, whatever UCI_Chess960 is
+		{url.Values{"fen": {"1r3kr1/pppppppp/8/8/8/8/PPPPPPPP/R1RK3R w KQkq - 0 1"}}, http.StatusOK},
+		{url.Values{"fen": {"bqnb1rkr/pp3ppp/3ppn2/2p5/5P2/P2P4/NPP1P1PP/BQ1BNRKR w HFhf - 2 9"}}, http.StatusOK},
+		{url.Values{"fen": {"r1bqk2r/pppp1ppp/2n1p3/4P3/1b1Pn3/2NB1N2/PPP2PPP/R1BQK2R[Pn] b KQkq - 0 1"}, "variant": {"crazyhouse"}, "lastmove": {"N@e4"}}, http.StatusOK},
+		{url.Values{"fen": {"8/8/8/8/8/8/8/8 w - - 0 1"}}, http.StatusBadRequest},
+		{url.Values{"variant": {"bughouse"}}, http.StatusBadRequest},
+		{url.Values{"size": {"8"}}, http.StatusBadRequest},
+		{url.Values{"lastmove": {"e2"}}, http.StatusBadRequest},
+		{url.Values{"arrows": {"e2e2"}}, http.StatusBadRequest},
+	}
+
+	srv := &Server{}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		srv.boardHandler(w, httptest.NewRequest(http.MethodGet, "/board.svg?"+tt.query.Encode(), nil))
+
+		if w.Code != tt.status {
+			t.Errorf("%v: got status %v, want %v: %v", tt.query, w.Code, tt.status, w.Body.String())
+			continue
+		}
+		if tt.status != http.StatusOK {
+			continue
+		}
+		if ct := w.Header().Get("Content-Type"); ct != "image/svg+xml" {
+			t.Errorf("%v: got content type %v", tt.query, ct)
+		}
+		if !strings.Contains(w.Body.String(), "<svg") {
+			t.Errorf("%v: the body is not an SVG image", tt.query)
+		}
+	}
+}
@@ -0,0 +1,255 @@
+package websocket
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/Tecu23/go-game/pkg/chess/book"
+	"github.com/Tecu23/go-game/pkg/chess/engine"
+	"github.com/Tecu23/go-game/pkg/chess/nnue"
+	"github.com/Tecu23/go-game/pkg/chess/position"
+	"github.com/Tecu23/go-game/pkg/chess/syzygy"
+)
+
+// the UCI option types
+const (
+	optSpin   = "spin"
+	optCheck  = "check"
+	optCombo  = "combo"
+	optButton = "button"
+	optString = "string"
+)
+
+// defaultHash is the size of the transposition table in MB until the GUI sets Hash
+const defaultHash = 16
+
+// uciOption is an option advertised in the uci response and set by setoption. The handler
+// that goes with typ is called with the value parsed and validated
+type uciOption struct {
+	name     string
+	typ      string
+	def      string
+	min, max int      // spin
+	vars     []string // combo
+
+	spin   func(conn *websocket.Conn, val int) error
+	check  func(conn *websocket.Conn, val bool) error
+	str    func(conn *websocket.Conn, val string) error // string and combo
+	button func(conn *websocket.Conn) error
+}
+
+func spinOption(name string, def, min, max int, fn func(*websocket.Conn, int) error) *uciOption {
+	return &uciOption{name: name, typ: optSpin, def: strconv.Itoa(def), min: min, max: max, spin: fn}
+}
+
+func checkOption(name string, def bool, fn func(*websocket.Conn, bool) error) *uciOption {
+	return &uciOption{name: name, typ: optCheck, def: strconv.FormatBool(def), check: fn}
+}
+
+func comboOption(name, def string, vars []string, fn func(*websocket.Conn, string) error) *uciOption {
+	return &uciOption{name: name, typ: optCombo, def: def, vars: vars, str: fn}
+}
+
+func stringOption(name, def string, fn func(*websocket.Conn, string) error) *uciOption {
+	return &uciOption{name: name, typ: optString, def: def, str: fn}
+}
+
+func buttonOption(name string, fn func(*websocket.Conn) error) *uciOption {
+	return &uciOption{name: name, typ: optButton, button: fn}
+}
+
+// String returns the option line of the uci response
+func (o *uciOption) String() string {
+	s := fmt.Sprintf("option name %s type %s", o.name, o.typ)
+	switch o.typ {
+	case optSpin:
+		s += fmt.Sprintf(" default %s min %v max %v", o.def, o.min, o.max)
+	case optCheck:
+		s += " default " + o.def
+	case optCombo:
+		s += " default " + o.def + " var " + strings.Join(o.vars, " var ")
+	case optString:
+		def := o.def
+		if def == "" {
+			def = "<empty>"
+		}
+		s += " default " + def
+	}
+	return s
+}
+
+// set validates value against the type and range of the option and calls its handler
+func (o *uciOption) set(conn *websocket.Conn, value string, hasValue bool) error {
+	if o.typ == optButton {
+		return o.button(conn)
+	}
+	if !hasValue {
+		return fmt.Errorf("'value' is missing for the option %s", o.name)
+	}
+
+	switch o.typ {
+	case optSpin:
+		val, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("the %s value %q is not numeric", o.name, value)
+		}
+		if val < o.min || val > o.max {
+			return fmt.Errorf("the %s value %v is not between %v and %v", o.name, val, o.min, o.max)
+		}
+		return o.spin(conn, val)
+	case optCheck:
+		switch strings.ToLower(value) {
+		case "true":
+			return o.check(conn, true)
+		case "false":
+			return o.check(conn, false)
+		}
+		return fmt.Errorf("the %s value %q is not true or false", o.name, value)
+	case optCombo:
+		for _, v := range o.vars {
+			if strings.EqualFold(v, value) {
+				return o.str(conn, v)
+			}
+		}
+		return fmt.Errorf("unknown %s %s", o.name, value)
+	}
+
+	if strings.EqualFold(value, "<empty>") {
+		value = ""
+	}
+	return o.str(conn, value)
+}
+
+// options are the UCI options in the order of the uci response
+var options = []*uciOption{
+	spinOption("Hash", defaultHash, 1, 4000, func(conn *websocket.Conn, mB int) error {
+		return position.Trans.New(mB)
+	}),
+	buttonOption("Clear Hash", func(conn *websocket.Conn) error {
+		position.Trans.Clear()
+		return nil
+	}),
+	spinOption("Threads", 1, 1, 1, func(conn *websocket.Conn, n int) error {
+		engine.Threads = n
+		return nil
+	}),
+	spinOption("MultiPV", 1, 1, 1, func(conn *websocket.Conn, n int) error {
+		engine.MultiPV = n
+		return nil
+	}),
+	checkOption("Ponder", false, func(conn *websocket.Conn, b bool) error {
+		engine.Ponder = b
+		return nil
+	}),
+	spinOption("Move Overhead", engine.MoveOverhead, 0, 5000, func(conn *websocket.Conn, ms int) error {
+		engine.MoveOverhead = ms
+		return nil
+	}),
+	spinOption("Contempt", 0, -100, 100, func(conn *websocket.Conn, cp int) error {
+		engine.Contempt = cp
+		return nil
+	}),
+	checkOption("OwnBook", false, func(conn *websocket.Conn, b bool) error {
+		book.OwnBook = b
+		return nil
+	}),
+	stringOption("BookFile", "", func(conn *websocket.Conn, path string) error {
+		if path == "" {
+			book.Unload()
+			return nil
+		}
+		if err := book.Load(path); err != nil {
+			return err
+		}
+		Write(conn, fmt.Sprintf("info string using book %s with %v entries", path, book.Active.Len()))
+		return nil
+	}),
+	stringOption("EvalFile", "", func(conn *websocket.Conn, path string) error {
+		if path == "" {
+			nnue.Unload()
+			return nil
+		}
+		if err := nnue.Load(path); err != nil {
+			return err
+		}
+		position.Board.RefreshAcc()
+		Write(conn, fmt.Sprintf("info string using network %s", path))
+		return nil
+	}),
+	stringOption("EvalParams", "", func(conn *websocket.Conn, path string) error {
+		return position.LoadParamsFile(path)
+	}),
+	stringOption("SyzygyPath", "", func(conn *websocket.Conn, path string) error {
+		if err := syzygy.Init(path); err != nil {
+			return err
+		}
+		Write(conn, fmt.Sprintf("info string %s", syzygy.String()))
+		return nil
+	}),
+	checkOption("UCI_Chess960", false, func(conn *websocket.Conn, b bool) error {
+		position.Chess960 = b
+		return nil
+	}),
+	comboOption("UCI_Variant", position.Standard.Name(), variantNames(), func(conn *websocket.Conn, name string) error {
+		if v := position.Variants[name]; v != variant {
+			variant = v
+			position.Trans.Clear() // the scores of the other variant are no good
+			position.Board.Variant = v
+			position.Board.NewGame()
+		}
+		return nil
+	}),
+}
+
+// variantNames returns the UCI_Variant names sorted
+func variantNames() []string {
+	names := make([]string, 0, len(position.Variants))
+	for name := range position.Variants {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// findOption returns the option called name, the case doesn't matter
+func findOption(name string) *uciOption {
+	for _, o := range options {
+		if strings.EqualFold(o.name, name) {
+			return o
+		}
+	}
+	return nil
+}
+
+// parseSetOption splits "setoption name <id> [value <x>]" in the name and the value. Both
+// can have spaces, the name ends at the word value
+func parseSetOption(words []string) (name, value string, hasValue bool, err error) {
+	ix := 1
+	for ix < len(words) && strings.TrimSpace(words[ix]) == "" {
+		ix++
+	}
+	if ix >= len(words) || strings.ToLower(strings.TrimSpace(words[ix])) != "name" {
+		return "", "", false, fmt.Errorf("'name' is missing in this option %s", strings.Join(words, " "))
+	}
+
+	var nameWords []string
+	for ix++; ix < len(words); ix++ {
+		w := strings.TrimSpace(words[ix])
+		if strings.ToLower(w) == "value" {
+			hasValue = true
+			value = strings.TrimSpace(strings.Join(words[ix+1:], " "))
+			break
+		}
+		if w != "" {
+			nameWords = append(nameWords, w)
+		}
+	}
+	if len(nameWords) == 0 {
+		return "", "", false, fmt.Errorf("the name is missing in this option %s", strings.Join(words, " "))
+	}
+	return strings.Join(nameWords, " "), value, hasValue, nil
+}
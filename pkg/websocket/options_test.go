@@ -0,0 +1,98 @@
+package websocket
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/Tecu23/go-game/pkg/chess/engine"
+)
+
+// dial starts a server that upgrades the connection and hands it to handle, and returns
+// the client side
+func dial(t *testing.T, handle func(conn *websocket.Conn)) *websocket.Conn {
+	t.Helper()
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrade: %v", err)
+			return
+		}
+		handle(conn)
+	}))
+	t.Cleanup(srv.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// readUntil reads the messages on conn up to and including last
+func readUntil(t *testing.T, conn *websocket.Conn, last string) []string {
+	t.Helper()
+	var lines []string
+	for {
+		_, p, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("read after %q: %v", lines, err)
+		}
+		lines = append(lines, string(p))
+		if string(p) == last {
+			return lines
+		}
+	}
+}
+
+func TestUciListsOptions(t *testing.T) {
+	conn := dial(t, handleUci)
+	lines := readUntil(t, conn, "uciok")
+
+	for _, want := range []string{
+		"option name Hash type spin default 16 min 1 max 4000",
+		"option name Threads type spin default 1 min 1 max 1",
+		"option name MultiPV type spin default 1 min 1 max 1",
+		"option name Ponder type check default false",
+		"option name Clear Hash type button",
+	} {
+		found := false
+		for _, l := range lines {
+			found = found || l == want
+		}
+		if !found {
+			t.Errorf("the uci response %q doesn't list %q", lines, want)
+		}
+	}
+}
+
+func TestSetPonder(t *testing.T) {
+	defer func(p bool) { engine.Ponder = p }(engine.Ponder)
+
+	for _, tt := range []struct {
+		cmd  string
+		want bool
+	}{
+		{"setoption name Ponder value true", true},
+		{"setoption name ponder value false", false},
+	} {
+		name, value, hasValue, err := parseSetOption(strings.Split(tt.cmd, " "))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.cmd, err)
+		}
+		if err := findOption(name).set(nil, value, hasValue); err != nil {
+			t.Fatalf("%s: %v", tt.cmd, err)
+		}
+		if engine.Ponder != tt.want {
+			t.Errorf("%s: Ponder is %v, want %v", tt.cmd, engine.Ponder, tt.want)
+		}
+	}
+	if err := findOption("Ponder").set(nil, "maybe", true); err == nil {
+		t.Error("Ponder took the value maybe")
+	}
+}
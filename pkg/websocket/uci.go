@@ -11,6 +11,7 @@ import (
 	log "github.com/sirupsen/logrus"
 
 	"github.com/Tecu23/go-game/pkg/chess/engine"
+	"github.com/Tecu23/go-game/pkg/chess/position"
 )
 
 // Uci should connect the websocket to the actual uci interface
@@ -46,6 +47,11 @@ func (srv *Server) Uci(
 
 func uci(input chan string, conn *websocket.Conn) {
 	toEng, frEng := engine.Engine()
+	if position.Trans.Entries == 0 { // the search needs a table before the GUI sets Hash
+		if err := position.Trans.New(defaultHash); err != nil {
+			log.Error(err)
+		}
+	}
 	var cmd string
 	var bestMove string
 	quit := false
@@ -55,6 +61,7 @@ func uci(input chan string, conn *websocket.Conn) {
 		case cmd = <-input:
 			log.Info(cmd)
 		case bestMove = <-frEng:
+			searching = false
 			handleBestMove(conn, bestMove)
 			continue
 		}
@@ -105,6 +112,8 @@ func uci(input chan string, conn *websocket.Conn) {
 			handleMyMoves(conn, words)
 		case "key":
 			handleKey(conn)
+		case "fen": // Print the FEN of the current position
+			handleFen(conn)
 		case "see":
 			handleSee(conn, words)
 		case "qs":
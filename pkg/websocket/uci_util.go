@@ -2,8 +2,11 @@ package websocket
 
 import (
 	"fmt"
+	"runtime"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gorilla/websocket"
 
@@ -11,10 +14,18 @@ import (
 	"github.com/Tecu23/go-game/pkg/chess/engine"
 	"github.com/Tecu23/go-game/pkg/chess/moves"
 	"github.com/Tecu23/go-game/pkg/chess/position"
+	"github.com/Tecu23/go-game/pkg/chess/render"
 )
 
 var savedBestMove = ""
 
+// searching is set from go until the engine sends its bestmove. The board, the network and
+// the table belong to the search in the meantime
+var searching = false
+
+// variant is the UCI_Variant option, the rules of the positions set up by the position command
+var variant = position.Standard
+
 func handleBestMove(conn *websocket.Conn, bestMove string) {
 	if engine.Limits.Infinite {
 		savedBestMove = bestMove
@@ -27,71 +38,44 @@ func handleBestMove(conn *websocket.Conn, bestMove string) {
 func handleUci(conn *websocket.Conn) {
 	Write(conn, "id name GoEng")
 	Write(conn, "id author Tecu23")
+	for _, o := range options {
+		Write(conn, o.String())
+	}
 
 	Write(conn, "uciok")
 }
 
 func handleSetOption(conn *websocket.Conn, words []string) {
-	if len(words) < 5 {
-		Write(
-			conn,
-			fmt.Sprintf("info string don't have this option %s", strings.Join(words[:], " ")),
-		)
+	name, value, hasValue, err := parseSetOption(words)
+	if err != nil {
+		Write(conn, fmt.Sprintf("info string %s", err.Error()))
+		return
 	}
-
-	if strings.ToLower(strings.TrimSpace(words[1])) != "name" {
-		Write(
-			conn,
-			fmt.Sprintf(
-				"info string 'name' is missing in this option %s",
-				strings.Join(words[:], " "),
-			),
-		)
+	if searching {
+		Write(conn, fmt.Sprintf("info string can't set %s during a search", name))
+		return
 	}
 
-	switch strings.ToLower(strings.TrimSpace(words[2])) {
-	case "hash":
-		if strings.TrimSpace(strings.ToLower(words[3])) != "value" {
-			Write(
-				conn,
-				fmt.Sprintf(
-					"info string 'value' is missing in this option %s",
-					strings.Join(words[:], " "),
-				),
-			)
-		}
-
-		if val, err := strconv.Atoi(strings.TrimSpace(words[4])); err == nil {
-			if err = position.Trans.New(val); err != nil {
-				Write(
-					conn,
-					fmt.Sprintf(
-						"info string %s ",
-						err.Error(),
-					),
-				)
-			}
-		} else {
-			Write(
-				conn,
-				fmt.Sprintf(
-					"info string the Hash value is not numeric %s",
-					strings.Join(words[:], " "),
-				),
-			)
-		}
-	default:
+	o := findOption(name)
+	if o == nil {
 		Write(
 			conn,
-			fmt.Sprintf(
-				"info string don't have this option %s",
-				strings.Join(words[:], " "),
-			),
+			fmt.Sprintf("info string don't have this option %s", strings.Join(words[:], " ")),
 		)
+		return
+	}
 
+	if err := o.set(conn, value, hasValue); err != nil {
+		Write(conn, fmt.Sprintf("info string %s", err.Error()))
 	}
 }
 
+// startSearch starts the engine on position.Board with engine.Limits
+func startSearch(toEng chan bool) {
+	searching = true
+	toEng <- true
+}
+
 func handleIsReady(conn *websocket.Conn) {
 	Write(conn, "readyok")
 }
@@ -105,8 +89,6 @@ func handlePosition(conn *websocket.Conn, cmd string) {
 
 	fen := ""
 
-	position.Board.NewGame()
-
 	cmd = strings.TrimSpace(strings.TrimPrefix(cmd, "position"))
 
 	// dividing the cmd in 2 parts, the position and the moves
@@ -132,12 +114,20 @@ func handlePosition(conn *websocket.Conn, cmd string) {
 	}
 
 	// Now parsing the FEN string
-	position.ParseFEN(fen)
+	b, err := position.ParseFENVariant(fen, variant, position.Chess960)
+	if err != nil {
+		Write(conn, fmt.Sprintf("info string %v", err))
+		return
+	}
 
+	// the moves are made on b, a bad move leaves the old position
 	if len(parts) == 2 {
-		parts[1] = strings.ToLower(strings.TrimSpace(parts[1]))
-		position.ParseMvs(parts[1])
+		if err := b.ParseMvs(parts[1]); err != nil {
+			Write(conn, fmt.Sprintf("info string %v", err))
+			return
+		}
 	}
+	position.Board = *b
 }
 
 func handleDebug(conn *websocket.Conn, words []string) {
@@ -179,9 +169,19 @@ func handleGo(conn *websocket.Conn, toEng chan bool, words []string) {
 				return
 			}
 			engine.Limits.SetDepth(d)
-			toEng <- true
+			startSearch(toEng)
 		case "nodes":
-			Write(conn, "info string go nodes not implemented")
+			n := uint64(0)
+			err := error(nil)
+			if len(words) >= 3 {
+				n, err = strconv.ParseUint(words[2], 10, 64)
+			}
+			if n == 0 || err != nil {
+				Write(conn, "info string nodes not numeric")
+				return
+			}
+			engine.Limits.SetNodes(n)
+			startSearch(toEng)
 		case "movetime":
 			mt, err := strconv.Atoi(words[2])
 			if err != nil {
@@ -189,12 +189,12 @@ func handleGo(conn *websocket.Conn, toEng chan bool, words []string) {
 				return
 			}
 			engine.Limits.SetMoveTime(mt)
-			toEng <- true
+			startSearch(toEng)
 		case "mate": // mate <x>  mate in x moves
 			Write(conn, "info string go mate not implemented")
 		case "infinite":
 			engine.Limits.SetInfinite(true)
-			toEng <- true
+			startSearch(toEng)
 		case "register":
 			Write(conn, "info string go register not implemented")
 		default:
@@ -203,7 +203,7 @@ func handleGo(conn *websocket.Conn, toEng chan bool, words []string) {
 	} else {
 		Write(conn, "info string suppose go infinite")
 		engine.Limits.SetInfinite(true)
-		toEng <- true
+		startSearch(toEng)
 	}
 }
 
@@ -229,19 +229,55 @@ func handleQuit(conn *websocket.Conn) {
 	Write(conn, "info string cmd quit not implement yet")
 }
 
+// handlePerformanveTest runs perft <depth> [threads [hash MB]] on the current position
 func handlePerformanveTest(conn *websocket.Conn, words []string) {
-	if len(words) > 1 {
-		depth, err := strconv.Atoi(words[1])
-		if err != nil {
-			Write(conn, err.Error())
-		} else {
-			engine.StartPerft(depth, &position.Board)
+	if len(words) < 2 {
+		Write(conn, "info string perft needs a depth")
+		return
+	}
+
+	args := []int{0, runtime.NumCPU(), 64} // depth, threads, hash
+	for ix := 1; ix < len(words) && ix <= len(args); ix++ {
+		n, err := strconv.Atoi(strings.TrimSpace(words[ix]))
+		if err != nil || n < 0 {
+			Write(conn, fmt.Sprintf("info string %v not numeric", words[ix]))
+			return
 		}
+		args[ix-1] = n
+	}
+
+	var tt *engine.PerftTT
+	if args[2] > 0 {
+		tt = engine.NewPerftTT(args[2])
+	}
+	start := time.Now()
+	div := engine.DivideParallel(args[0], args[1], tt, &position.Board)
+	elapsed := time.Since(start)
+
+	mvs := make([]string, 0, len(div))
+	for mv := range div {
+		mvs = append(mvs, mv)
 	}
+	sort.Strings(mvs)
+
+	total := uint64(0)
+	for _, mv := range mvs {
+		Write(conn, fmt.Sprintf("%v: %v", mv, div[mv]))
+		total += div[mv]
+	}
+	nps := uint64(0)
+	if elapsed > 0 {
+		nps = uint64(float64(total) / elapsed.Seconds())
+	}
+	Write(conn, fmt.Sprintf("Total: %v", total))
+	Write(conn, fmt.Sprintf("info nodes %v time %v nps %v", total, elapsed.Milliseconds(), nps))
 }
 
 func handlePrintBoard(conn *websocket.Conn) {
-	position.Board.Print()
+	for _, line := range strings.Split(strings.TrimSuffix(render.Text(&position.Board, false), "\n"), "\n") {
+		Write(conn, line)
+	}
+	Write(conn, fmt.Sprintf("fen %v", position.Board.FEN()))
 }
 
 func handlePrintAllBitBoards(conn *websocket.Conn) {
@@ -253,7 +289,7 @@ func handlePrintAllLegalMoves(conn *websocket.Conn) {
 }
 
 func handleEvaluatePosition(conn *websocket.Conn) {
-	Write(conn, fmt.Sprintf("eval = %s", position.Evaluate(&position.Board)))
+	Write(conn, fmt.Sprintf("eval = %v", position.Evaluate(&position.Board)))
 }
 
 func handleMyPositions(conn *websocket.Conn, words []string) {
@@ -308,6 +344,10 @@ func handleMyMoves(conn *websocket.Conn, words []string) {
 	position.ParseMvs(mvString)
 }
 
+func handleFen(conn *websocket.Conn) {
+	Write(conn, fmt.Sprintf("info string %v", position.Board.FEN()))
+}
+
 func handleKey(conn *websocket.Conn) {
 	Write(conn, fmt.Sprintf("key = %x, fullkey=%x\n", position.Board.Key, position.Board.FullKey()))
 	index := position.Board.FullKey() & uint64(position.Trans.Mask)
@@ -341,7 +381,7 @@ func handleHistory(conn *websocket.Conn) {
 func handleMoveValue(conn *websocket.Conn) {
 	// print all legal moves with different values
 	b := &position.Board
-	transMove := moves.NoMove
+	transMove := moves.NoMove16
 	transDepth := 4
 	ply := 1
 
@@ -356,7 +396,7 @@ func handleMoveValue(conn *websocket.Conn) {
 	// bs, score := noScore, noScore
 	// bm := noMove
 
-	genInfo := engine.GenInfoStruct{Sv: 0, Ply: 1, TransMove: transMove}
+	genInfo := engine.GenInfoStruct{Sv: 0, Ply: 1, TransMove: b.Expand(transMove)}
 	engine.Next = engine.NextNormal
 	ix := 0
 	bestSc, bestMv, bestHsc, bestHmv := MinEval, moves.NoMove, MinEval, moves.NoMove
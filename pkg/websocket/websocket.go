@@ -21,6 +21,7 @@ func NewServer(addr *string) *Server {
 	}
 
 	http.HandleFunc("/uci", srv.uciHandler)
+	http.HandleFunc("/board.svg", srv.boardHandler)
 
 	return srv
 }